@@ -5,6 +5,7 @@ import (
 	"log"
 	"net/http"
 	"os"
+	"time"
 
 	"github.com/gin-gonic/gin"
 
@@ -12,6 +13,7 @@ import (
 	"image-service/pkg/ludo"
 	"image-service/pkg/scraper"
 	"image-service/pkg/ttt"
+	"image-service/pkg/utils"
 )
 
 func main() {
@@ -25,6 +27,24 @@ func main() {
 		port = "8080"
 	}
 
+	// Sprite tables come from the manifest; refuse to start with a broken one
+	if err := combat.LoadManifest(utils.GetAssetPath("rpgasset", "manifest.json")); err != nil {
+		log.Fatal("Invalid sprite manifest: ", err)
+	}
+	go combat.WatchManifest(5 * time.Second)
+
+	// Check every sprite, background and UI piece decodes before serving.
+	// STRICT_ASSETS=1 refuses to start instead of rendering with gaps.
+	if err := combat.ValidateAssets(utils.GetAssetPath()); err != nil {
+		if os.Getenv("STRICT_ASSETS") == "1" {
+			log.Fatal("Asset validation failed: ", err)
+		}
+		log.Printf("⚠️ Asset validation: %v", err)
+	}
+	if err := ttt.CheckAssets(); err != nil {
+		log.Printf("⚠️ %v", err)
+	}
+
 	r := gin.Default()
 
 	// Global Middleware
@@ -59,12 +79,29 @@ func main() {
 		// Combat
 		api.POST("/combat", combat.GenerateCombatImage)
 		api.POST("/combat/endscreen", combat.GenerateEndScreen)
+		api.POST("/combat/profile", combat.GenerateProfile)
+		api.POST("/combat/boss-intro", combat.GenerateBossIntro)
+		api.POST("/combat/class-tree", combat.GenerateClassTree)
+		api.POST("/combat/raid", combat.GenerateRaid)
+		api.GET("/assets", combat.ListAssets)
+
+		// Shop
+		api.POST("/shop", combat.GenerateShop)
+		api.POST("/inventory", combat.GenerateInventory)
+
+		// Dungeon
+		api.POST("/dungeon/map", combat.GenerateDungeonMap)
 
 		// Games
 		api.POST("/ludo", ludo.RenderBoard)
 		api.POST("/ttt", ttt.RenderBoard)
 		api.POST("/ttt/leaderboard", ttt.RenderLeaderboard)
 
+		// Admin, only when there's a token to check
+		if os.Getenv("ADMIN_TOKEN") != "" {
+			api.POST("/admin/reload-sprites", combat.ReloadManifest)
+		}
+
 		// Scrapers
 		scrape := api.Group("/scrape")
 		{
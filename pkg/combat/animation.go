@@ -0,0 +1,268 @@
+package combat
+
+import (
+	"math"
+	"strconv"
+	"strings"
+
+	"image-service/pkg/utils"
+
+	"github.com/fogleman/gg"
+)
+
+type unitSide int
+
+const (
+	sidePlayer unitSide = iota
+	sideEnemy
+)
+
+// unitRef identifies a unit by side and index into req.Players / req.Enemies
+type unitRef struct {
+	side  unitSide
+	index int
+}
+
+const (
+	lungeDistance = 40.0
+
+	// GIF frame delays, in 100ths of a second
+	introDelay = 60
+	lungeDelay = 4
+	flashDelay = 5
+	drainDelay = 5
+	fadeDelay  = 6
+	holdDelay  = 250
+
+	// Frames between weather steps in animated turns
+	weatherEvery = 12
+)
+
+// resolveUnit maps an action reference to a unit. It accepts "player:N" and
+// "enemy:N" indexes, then falls back to a case-insensitive name match with
+// players checked before enemies.
+func (req *CombatRequest) resolveUnit(ref string) (unitRef, bool) {
+	ref = strings.TrimSpace(ref)
+	if side, idx, ok := strings.Cut(ref, ":"); ok {
+		i, err := strconv.Atoi(idx)
+		if err == nil && i >= 0 {
+			switch strings.ToLower(side) {
+			case "player":
+				if i < len(req.Players) {
+					return unitRef{sidePlayer, i}, true
+				}
+			case "enemy":
+				if i < len(req.Enemies) {
+					return unitRef{sideEnemy, i}, true
+				}
+			}
+		}
+	}
+
+	for i, p := range req.Players {
+		if strings.EqualFold(p.Name, ref) {
+			return unitRef{sidePlayer, i}, true
+		}
+	}
+	for i, e := range req.Enemies {
+		if strings.EqualFold(e.Name, ref) {
+			return unitRef{sideEnemy, i}, true
+		}
+	}
+	return unitRef{}, false
+}
+
+// unit returns the scene unit for an action reference, or nil
+func (sc *combatScene) unit(ref string) *sceneUnit {
+	r, ok := sc.req.resolveUnit(ref)
+	if !ok {
+		return nil
+	}
+	if r.side == sidePlayer {
+		return sc.players[r.index]
+	}
+	return sc.enemies[r.index]
+}
+
+// rewind restores every unit to its state before the actions were applied,
+// since the request carries the HP values after the turn
+func (sc *combatScene) rewind(actions []CombatAction) {
+	for i := len(actions) - 1; i >= 0; i-- {
+		a := actions[i]
+		target := sc.unit(a.Target)
+		if target == nil || a.Missed {
+			continue
+		}
+		target.hp = clampHP(target.hp+float64(a.Damage)-float64(a.Healed), target.maxHP)
+	}
+
+	for _, units := range [][]*sceneUnit{sc.players, sc.enemies} {
+		for _, u := range units {
+			if u.hp > 0 {
+				u.fade = 0
+			}
+		}
+	}
+}
+
+// animateTurn plays the actions in order (attacker lunge, hit flash, HP drain,
+// death fade) and returns the encoded GIF
+func animateTurn(sc *combatScene, actions []CombatAction) ([]byte, error) {
+	sc.rewind(actions)
+	sc.hideFloaters = true
+
+	gb := utils.NewGIFBuilder()
+	dc := gg.NewContext(sc.theme.Width, sc.theme.Height)
+	// Weather changes the whole canvas, so it only moves every few frames;
+	// in between, frames store just what the action changed
+	frames, elapsed := 0, 0.0
+	capture := func(delay int) {
+		sc.draw(dc)
+		gb.AddFrame(dc.Image(), delay)
+		elapsed += float64(delay) / 100
+		if frames++; frames%weatherEvery == 0 {
+			sc.clock = elapsed
+		}
+	}
+
+	capture(introDelay)
+
+	// Effects play during the action that hits the same target, or right
+	// after the intro when no action matches
+	paired := sc.pairEffects(actions)
+	sc.playEffects(paired[-1], capture)
+
+	for i, a := range actions {
+		attacker, target := sc.unit(a.Attacker), sc.unit(a.Target)
+		if target == nil {
+			continue
+		}
+
+		// Attacker lunge towards the target
+		var dirX, dirY float64
+		if attacker != nil && attacker != target && attacker.sprite != nil {
+			dirX, dirY = sc.lungeDir(attacker, target)
+			attacker.label = a.Skill
+			for f := 1; f <= 3; f++ {
+				t := float64(f) / 3
+				attacker.dx, attacker.dy = dirX*lungeDistance*t, dirY*lungeDistance*t
+				capture(lungeDelay)
+			}
+		}
+		sc.playEffects(paired[i], capture)
+
+		// Hit flash on the target sprite
+		if a.Missed {
+			target.label = "MISS"
+		} else if target.sprite != nil && (a.Damage > 0 || a.Healed > 0 || a.Killed) {
+			target.flashTint = hitTint
+			if a.Healed > a.Damage {
+				target.flashTint = healTint
+			}
+			for _, f := range []float64{1, 0.5} {
+				target.flash = f
+				capture(flashDelay)
+			}
+			target.flash = 0
+		}
+
+		// HP bar drains from old to new value while the attacker steps back
+		from := target.hp
+		to := from
+		if !a.Missed {
+			to = clampHP(from-float64(a.Damage)+float64(a.Healed), target.maxHP)
+			if a.Killed {
+				to = 0
+			}
+		}
+		for f := 1; f <= 4; f++ {
+			t := float64(f) / 4
+			target.hp = from + (to-from)*t
+			if attacker != nil {
+				attacker.dx, attacker.dy = dirX*lungeDistance*(1-t), dirY*lungeDistance*(1-t)
+			}
+			capture(drainDelay)
+		}
+
+		// Death fade into the red tint
+		if a.Killed && target.sprite != nil {
+			for f := 1; f <= 5; f++ {
+				target.fade = float64(f) / 5
+				capture(fadeDelay)
+			}
+		}
+
+		if attacker != nil {
+			attacker.label = ""
+		}
+		target.label = ""
+	}
+
+	sc.hideFloaters = false
+	capture(holdDelay)
+	return gb.Bytes()
+}
+
+// lungeDir is the unit vector from the attacker towards the target, aimed
+// at the target's portrait when it has no battlefield sprite. It's zero when
+// either has nothing drawn.
+func (sc *combatScene) lungeDir(attacker, target *sceneUnit) (float64, float64) {
+	a, ok := sc.anchor(attacker, 0.5)
+	if !ok {
+		return 0, 0
+	}
+	t, ok := sc.anchor(target, 0.5)
+	if !ok {
+		return 0, 0
+	}
+	d := math.Hypot(t.x-a.x, t.y-a.y)
+	if d == 0 {
+		return 0, 0
+	}
+	return (t.x - a.x) / d, (t.y - a.y) / d
+}
+
+// pairEffects hides every effect and assigns each to the first free action
+// with the same target (and source, when the effect names one), keyed by
+// action index. Unmatched effects are keyed -1.
+func (sc *combatScene) pairEffects(actions []CombatAction) map[int][]*sceneEffect {
+	paired := make(map[int][]*sceneEffect)
+	used := make(map[int]bool)
+	for _, e := range sc.effects {
+		e.t = -1
+		slot := -1
+		for i, a := range actions {
+			if used[i] || sc.unit(a.Target) != e.dst || (e.src != nil && sc.unit(a.Attacker) != e.src) {
+				continue
+			}
+			slot = i
+			used[i] = true
+			break
+		}
+		paired[slot] = append(paired[slot], e)
+	}
+	return paired
+}
+
+// playEffects captures the frames of a group of effects playing together
+func (sc *combatScene) playEffects(effects []*sceneEffect, capture func(int)) {
+	if len(effects) == 0 {
+		return
+	}
+	for f := 0; f < fxFrames; f++ {
+		for _, e := range effects {
+			e.t = (float64(f) + 0.5) / fxFrames
+		}
+		capture(fxDelay)
+	}
+	for _, e := range effects {
+		e.t = -1
+	}
+}
+
+func clampHP(hp, maxHP float64) float64 {
+	if maxHP > 0 && hp > maxHP {
+		hp = maxHP
+	}
+	return math.Max(0, hp)
+}
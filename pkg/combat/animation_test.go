@@ -0,0 +1,103 @@
+package combat
+
+import (
+	"image"
+	"math"
+	"testing"
+)
+
+func TestResolveUnit(t *testing.T) {
+	req := &CombatRequest{
+		Players: []Player{{Name: "Aria"}, {Name: "Slime"}},
+		Enemies: []Enemy{{Name: "Slime"}, {Name: "Goblin"}},
+	}
+	tests := []struct {
+		ref  string
+		want unitRef
+		ok   bool
+	}{
+		{"player:0", unitRef{sidePlayer, 0}, true},
+		{"enemy:1", unitRef{sideEnemy, 1}, true},
+		{" Enemy:0 ", unitRef{sideEnemy, 0}, true},
+		{"player:2", unitRef{}, false},
+		{"enemy:-1", unitRef{}, false},
+		{"goblin", unitRef{sideEnemy, 1}, true},
+		{"ARIA", unitRef{sidePlayer, 0}, true},
+		{"slime", unitRef{sidePlayer, 1}, true}, // Players win a shared name
+		{"dragon", unitRef{}, false},
+		{"", unitRef{}, false},
+	}
+	for _, tt := range tests {
+		got, ok := req.resolveUnit(tt.ref)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("resolveUnit(%q) = %v, %v; want %v, %v", tt.ref, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestRewind(t *testing.T) {
+	tests := []struct {
+		name    string
+		actions []CombatAction
+		hp      float64 // Goblin's HP after the turn
+		want    float64 // and before it
+		fade    float64
+	}{
+		{"damage", []CombatAction{{Attacker: "player:0", Target: "enemy:0", Damage: 30}}, 50, 80, 0},
+		{"heal", []CombatAction{{Attacker: "enemy:0", Target: "goblin", Healed: 20}}, 50, 30, 0},
+		{"miss", []CombatAction{{Attacker: "player:0", Target: "enemy:0", Damage: 30, Missed: true}}, 50, 50, 0},
+		{"unknown target", []CombatAction{{Attacker: "player:0", Target: "dragon", Damage: 30}}, 50, 50, 0},
+		{"capped at max", []CombatAction{{Target: "enemy:0", Damage: 80}}, 50, 100, 0},
+		{"heal from KO", []CombatAction{{Target: "enemy:0", Healed: 90}}, 50, 0, 1},
+		{"kill", []CombatAction{
+			{Target: "enemy:0", Damage: 20},
+			{Target: "enemy:0", Damage: 30, Killed: true},
+		}, 0, 50, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := &CombatRequest{
+				Players: []Player{{Name: "Aria"}},
+				Enemies: []Enemy{{Name: "Goblin"}},
+			}
+			goblin := &sceneUnit{hp: tt.hp, maxHP: 100, fade: 1}
+			sc := &combatScene{req: req, players: []*sceneUnit{{hp: 10, maxHP: 10}}, enemies: []*sceneUnit{goblin}}
+			sc.rewind(tt.actions)
+			if goblin.hp != tt.want || goblin.fade != tt.fade {
+				t.Errorf("hp %v fade %v; want hp %v fade %v", goblin.hp, goblin.fade, tt.want, tt.fade)
+			}
+		})
+	}
+}
+
+func TestLungeDir(t *testing.T) {
+	th := &Theme{}
+	th.Party.Portrait.X, th.Party.Portrait.Bottom = 10, 100
+	sc := &combatScene{
+		theme: th,
+		party: []partySlot{{x: 0, y: 0, scale: 1}, {x: 50, y: 600, scale: 1}},
+	}
+	sprite := image.NewNRGBA(image.Rect(0, 0, 100, 100))
+	goblin := &sceneUnit{ref: unitRef{sideEnemy, 0}, sprite: sprite, x: 750, y: 30}
+
+	tests := []struct {
+		name   string
+		target *sceneUnit
+		dx, dy float64
+	}{
+		// Portrait centre (80, 680) from the goblin's (800, 80): 720 left, 600 down
+		{"party card", &sceneUnit{ref: unitRef{sidePlayer, 1}, portrait: image.NewNRGBA(image.Rect(0, 0, 40, 40))}, -720, 600},
+		{"battlefield", &sceneUnit{ref: unitRef{sideEnemy, 1}, sprite: sprite, x: 950, y: 30}, 1, 0},
+		{"nothing drawn", &sceneUnit{ref: unitRef{sidePlayer, 1}}, 0, 0},
+	}
+	for _, tt := range tests {
+		dx, dy := sc.lungeDir(goblin, tt.target)
+		want := math.Hypot(tt.dx, tt.dy)
+		if want == 0 {
+			want = 1
+		}
+		if math.Abs(dx-tt.dx/want) > 1e-9 || math.Abs(dy-tt.dy/want) > 1e-9 {
+			t.Errorf("%s: lunge (%.3f, %.3f); want (%.3f, %.3f)", tt.name, dx, dy, tt.dx/want, tt.dy/want)
+		}
+	}
+}
@@ -0,0 +1,258 @@
+package combat
+
+import (
+	"fmt"
+	"image"
+	_ "image/jpeg"
+	_ "image/png"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"image-service/pkg/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// UI pieces the combat renderer draws; a missing one fails validation
+var requiredUI = []string{
+	"player_state.png", "heart.png", "mana.png", "Options_menu.png", "banner.png",
+	"hp1.png", "hp2.png", "hp3.png", "hp4.png", "hp5.png",
+	"mana1.png", "mana2.png", "mana3.png", "mana4.png", "mana5.png",
+	"fantesy.ttf",
+}
+
+// AssetInfo is one file in the /api/assets inventory
+type AssetInfo struct {
+	File        string   `json:"file"`
+	DisplayName string   `json:"displayName,omitempty"`
+	Facing      string   `json:"facing,omitempty"`
+	Tags        []string `json:"tags,omitempty"`
+	Width       int      `json:"width,omitempty"`
+	Height      int      `json:"height,omitempty"`
+}
+
+// AssetGroup is a manifest group in the /api/assets inventory
+type AssetGroup struct {
+	Key         string      `json:"key"`
+	DisplayName string      `json:"displayName"`
+	AliasOf     string      `json:"aliasOf,omitempty"`
+	Tags        []string    `json:"tags,omitempty"`
+	EvolvesFrom string      `json:"evolvesFrom,omitempty"`
+	EvolveLevel int         `json:"evolveLevel,omitempty"`
+	Sprites     []AssetInfo `json:"sprites"`
+}
+
+// AssetCosmetic is a manifest cosmetic in the /api/assets inventory
+type AssetCosmetic struct {
+	Key  string `json:"key"`
+	Slot string `json:"slot"`
+	AssetInfo
+}
+
+var imageSizes sync.Map // path -> image.Point
+
+// imageSize decodes just the header of an image file, cached per path
+func imageSize(path string) (image.Point, error) {
+	if size, ok := imageSizes.Load(path); ok {
+		return size.(image.Point), nil
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return image.Point{}, err
+	}
+	defer f.Close()
+
+	cfg, _, err := image.DecodeConfig(f)
+	if err != nil {
+		return image.Point{}, err
+	}
+	size := image.Pt(cfg.Width, cfg.Height)
+	imageSizes.Store(path, size)
+	return size, nil
+}
+
+// decodeImage fully decodes an image file, so truncated or corrupt pixel
+// data is caught, and caches its size. The pixels aren't kept.
+func decodeImage(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	img, _, err := image.Decode(f)
+	if err != nil {
+		return err
+	}
+	imageSizes.Store(path, img.Bounds().Size())
+	return nil
+}
+
+// ValidateAssets checks that every manifest sprite, background and required UI
+// piece under assetsPath exists and decodes. Problems are logged; the returned
+// error is non-nil when the renderer would be missing something it needs.
+func ValidateAssets(assetsPath string) error {
+	root := filepath.Join(assetsPath, "rpgasset")
+	var problems []string
+	check := func(path string) {
+		if filepath.Ext(path) == ".ttf" {
+			if _, err := utils.LoadFont(path, 12); err != nil {
+				problems = append(problems, fmt.Sprintf("%s: %v", path, err))
+			}
+			return
+		}
+		if err := decodeImage(path); err != nil {
+			problems = append(problems, fmt.Sprintf("%s: %v", path, err))
+		}
+	}
+
+	m := Sprites()
+	for _, sec := range []struct {
+		groups map[string]*SpriteGroup
+		dir    string
+	}{{m.Characters, "characters"}, {m.Enemies, "enemies"}, {m.Bosses, "enemies"}} {
+		for _, key := range sortedGroupKeys(sec.groups) {
+			for _, s := range sec.groups[key].Sprites {
+				check(filepath.Join(root, sec.dir, s.File))
+			}
+		}
+	}
+
+	backgrounds := listBackgrounds(assetsPath)
+	if len(backgrounds) == 0 {
+		problems = append(problems, "no environment backgrounds found")
+	}
+	for _, bg := range backgrounds {
+		check(bg)
+	}
+
+	for _, f := range requiredUI {
+		check(filepath.Join(root, "ui", f))
+	}
+
+	themes := ThemeNames(assetsPath)
+	if _, err := LoadTheme(DefaultTheme, assetsPath); err != nil {
+		problems = append(problems, err.Error())
+	}
+	for _, name := range themes {
+		t, err := LoadTheme(name, assetsPath)
+		if err != nil {
+			problems = append(problems, err.Error())
+			continue
+		}
+		for _, aspect := range t.Aspects() {
+			if _, err := ThemeFor(name, aspect, 0, 0, assetsPath); err != nil {
+				problems = append(problems, err.Error())
+			}
+		}
+	}
+
+	if len(problems) > 0 {
+		return fmt.Errorf("%d asset problem(s):\n  %s", len(problems), strings.Join(problems, "\n  "))
+	}
+	log.Printf("✅ Assets validated: %d backgrounds, %d UI pieces, %d themes", len(backgrounds), len(requiredUI), len(themes))
+	return nil
+}
+
+// listBackgrounds returns the environment image paths, sorted by name
+func listBackgrounds(assetsPath string) []string {
+	envPath := filepath.Join(assetsPath, "rpgasset", "environment")
+	entries, err := os.ReadDir(envPath)
+	if err != nil {
+		return nil
+	}
+
+	var files []string
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		switch strings.ToLower(filepath.Ext(entry.Name())) {
+		case ".png", ".jpg", ".jpeg":
+			files = append(files, filepath.Join(envPath, entry.Name()))
+		}
+	}
+	sort.Strings(files)
+	return files
+}
+
+// ListAssets is the read-only inventory the bot builds its menus from
+func ListAssets(c *gin.Context) {
+	assetsPath := "assets"
+	root := filepath.Join(assetsPath, "rpgasset")
+	m := Sprites()
+
+	describe := func(path string, info AssetInfo) AssetInfo {
+		if size, err := imageSize(path); err == nil {
+			info.Width, info.Height = size.X, size.Y
+		}
+		return info
+	}
+	groups := func(section map[string]*SpriteGroup, dir string) []AssetGroup {
+		out := []AssetGroup{}
+		for _, key := range sortedGroupKeys(section) {
+			g := section[key]
+			ag := AssetGroup{Key: key, DisplayName: g.DisplayName, AliasOf: g.AliasOf, Tags: g.Tags, EvolvesFrom: g.EvolvesFrom, EvolveLevel: g.EvolveLevel, Sprites: []AssetInfo{}}
+			for _, s := range g.Sprites {
+				ag.Sprites = append(ag.Sprites, describe(filepath.Join(root, dir, s.File), AssetInfo{
+					File:        s.File,
+					DisplayName: s.DisplayName,
+					Facing:      s.Facing,
+					Tags:        s.Tags,
+				}))
+			}
+			out = append(out, ag)
+		}
+		return out
+	}
+
+	backgrounds := []AssetInfo{}
+	for _, path := range listBackgrounds(assetsPath) {
+		backgrounds = append(backgrounds, describe(path, AssetInfo{File: filepath.Base(path)}))
+	}
+
+	ui := []AssetInfo{}
+	fonts := []string{}
+	entries, _ := os.ReadDir(filepath.Join(root, "ui"))
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		path := filepath.Join(root, "ui", entry.Name())
+		if strings.EqualFold(filepath.Ext(path), ".ttf") {
+			fonts = append(fonts, entry.Name())
+			continue
+		}
+		ui = append(ui, describe(path, AssetInfo{File: entry.Name()}))
+	}
+
+	cosmetics := []AssetCosmetic{}
+	for _, key := range sortedGroupKeys(m.Cosmetics) {
+		item := m.Cosmetics[key]
+		cosmetics = append(cosmetics, AssetCosmetic{
+			Key:  key,
+			Slot: item.Slot,
+			AssetInfo: describe(filepath.Join(root, item.File), AssetInfo{
+				File:        item.File,
+				DisplayName: item.DisplayName,
+				Facing:      item.Facing,
+			}),
+		})
+	}
+
+	c.JSON(200, gin.H{
+		"classes":     groups(m.Characters, "characters"),
+		"enemyGroups": groups(m.Enemies, "enemies"),
+		"bosses":      groups(m.Bosses, "enemies"),
+		"biomes":      groups(m.Environments, "environment"),
+		"cosmetics":   cosmetics,
+		"backgrounds": backgrounds,
+		"ui":          ui,
+		"fonts":       fonts,
+		"themes":      ThemeNames(assetsPath),
+	})
+}
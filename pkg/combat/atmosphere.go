@@ -0,0 +1,253 @@
+package combat
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"math"
+	"strings"
+
+	"image-service/pkg/utils"
+
+	"github.com/disintegration/imaging"
+	"github.com/fogleman/gg"
+)
+
+// lightGrade is how a time of day colors the arena: the art is desaturated,
+// multiplied per channel, and a sky tint fades down from the top
+type lightGrade struct {
+	sat      float64
+	mul      [3]float64
+	sky      color.NRGBA
+	vignette uint8 // Alpha of the darkened corners
+}
+
+var timesOfDay = map[string]lightGrade{
+	"dawn":  {0.9, [3]float64{1.05, 0.9, 0.88}, color.NRGBA{255, 150, 120, 90}, 60},
+	"day":   {1.12, [3]float64{1.08, 1.06, 1.0}, color.NRGBA{255, 250, 220, 35}, 0},
+	"dusk":  {0.95, [3]float64{1.05, 0.78, 0.7}, color.NRGBA{190, 80, 150, 110}, 90},
+	"night": {0.5, [3]float64{0.45, 0.55, 0.85}, color.NRGBA{10, 20, 70, 120}, 170},
+}
+
+// weatherStyle is a kind of weather: how many particles it has and the wash
+// it lays over the arena
+type weatherStyle struct {
+	count int
+	wash  color.NRGBA
+}
+
+var weatherStyles = map[string]weatherStyle{
+	"rain":      {220, color.NRGBA{40, 60, 95, 55}},
+	"snow":      {170, color.NRGBA{205, 220, 240, 30}},
+	"fog":       {12, color.NRGBA{180, 185, 195, 60}},
+	"embers":    {70, color.NRGBA{255, 100, 30, 25}},
+	"sandstorm": {190, color.NRGBA{205, 160, 95, 75}},
+}
+
+// checkAtmosphere normalizes the request's time of day and weather and
+// rejects ones it doesn't know
+func (req *CombatRequest) checkAtmosphere() error {
+	req.TimeOfDay = strings.ToLower(strings.TrimSpace(req.TimeOfDay))
+	req.Weather = strings.ToLower(strings.TrimSpace(req.Weather))
+	if _, ok := timesOfDay[req.TimeOfDay]; !ok && req.TimeOfDay != "" {
+		return fmt.Errorf("unknown timeOfDay %q (dawn, day, dusk or night)", req.TimeOfDay)
+	}
+	if _, ok := weatherStyles[req.Weather]; !ok && req.Weather != "" {
+		return fmt.Errorf("unknown weather %q (rain, snow, fog, embers or sandstorm)", req.Weather)
+	}
+	return nil
+}
+
+// gradeArena color grades the background art for a time of day
+func gradeArena(img image.Image, timeOfDay string) image.Image {
+	g, ok := timesOfDay[timeOfDay]
+	if !ok {
+		return img
+	}
+	return imaging.AdjustFunc(img, func(c color.NRGBA) color.NRGBA {
+		r, gr, b := float64(c.R), float64(c.G), float64(c.B)
+		lum := 0.299*r + 0.587*gr + 0.114*b
+		ch := func(v, mul float64) uint8 {
+			return uint8(math.Max(0, math.Min(255, (lum+(v-lum)*g.sat)*mul)))
+		}
+		return color.NRGBA{ch(r, g.mul[0]), ch(gr, g.mul[1]), ch(b, g.mul[2]), c.A}
+	})
+}
+
+// drawAtmosphere lays the time of day's sky and vignette and the weather's
+// wash over the background
+func drawAtmosphere(dc *gg.Context, timeOfDay, weather string) {
+	w, h := float64(dc.Width()), float64(dc.Height())
+	if g, ok := timesOfDay[timeOfDay]; ok {
+		sky := gg.NewLinearGradient(0, 0, 0, h*0.6)
+		sky.AddColorStop(0, g.sky)
+		sky.AddColorStop(1, withAlpha(g.sky, 0))
+		dc.SetFillStyle(sky)
+		dc.DrawRectangle(0, 0, w, h*0.6)
+		dc.Fill()
+
+		if g.vignette > 0 {
+			v := gg.NewRadialGradient(w/2, h/2, math.Min(w, h)*0.4, w/2, h/2, math.Hypot(w, h)/2)
+			v.AddColorStop(0, color.NRGBA{0, 0, 0, 0})
+			v.AddColorStop(1, color.NRGBA{0, 0, 0, g.vignette})
+			dc.SetFillStyle(v)
+			dc.DrawRectangle(0, 0, w, h)
+			dc.Fill()
+		}
+	}
+	if s, ok := weatherStyles[weather]; ok {
+		dc.SetColor(s.wash)
+		dc.DrawRectangle(0, 0, w, h)
+		dc.Fill()
+	}
+}
+
+// weatherParticle is a raindrop, snowflake, fog bank, ember or gust of sand
+type weatherParticle struct {
+	x, y   float64 // At t = 0
+	vx, vy float64 // Pixels per second
+	size   float64
+	phase  float64 // Offsets sway and flicker so particles don't move in step
+	alpha  float64
+	img    image.Image // Fog banks are prerendered
+}
+
+// weatherLayer is the weather drawn over the battlefield. Particles move
+// with time, so animated turns show it falling, drifting or rising.
+type weatherLayer struct {
+	kind      string
+	w, h      float64
+	particles []weatherParticle
+
+	// The particles as last drawn, reused while the time doesn't change
+	frame  *gg.Context
+	frameT float64
+}
+
+// newWeather scatters a weather's particles over a w x h canvas. The same
+// seed scatters them the same way.
+func newWeather(kind string, seed Seed, w, h int) *weatherLayer {
+	style, ok := weatherStyles[kind]
+	if !ok {
+		return nil
+	}
+	wl := &weatherLayer{kind: kind, w: float64(w), h: float64(h)}
+	rng := seed.rand("weather:" + kind)
+	s := math.Min(wl.w, wl.h) / CANVAS_H
+	span := func(lo, hi float64) float64 { return lo + rng.Float64()*(hi-lo) }
+
+	for i := 0; i < style.count; i++ {
+		p := weatherParticle{x: rng.Float64() * wl.w, y: rng.Float64() * wl.h, phase: rng.Float64() * 2 * math.Pi}
+		switch kind {
+		case "rain":
+			p.vy = span(900, 1300) * s
+			p.vx = -p.vy * 0.22
+			p.size = span(16, 30) * s
+			p.alpha = span(80, 150)
+		case "snow":
+			p.vy = span(35, 90) * s
+			p.vx = span(-15, 15) * s
+			p.size = span(1.5, 4) * s
+			p.alpha = span(170, 235)
+		case "fog":
+			p.y = span(0.35, 0.95) * wl.h
+			p.vx = span(12, 35) * s
+			p.size = span(180, 340) * s
+			p.img = fogBank(p.size, span(90, 140))
+		case "embers":
+			p.vy = -span(30, 85) * s
+			p.vx = span(-10, 10) * s
+			p.size = span(1.5, 3.5) * s
+			p.alpha = span(160, 255)
+		case "sandstorm":
+			p.vx = span(500, 900) * s
+			p.vy = span(20, 60) * s
+			p.size = span(8, 40) * s
+			p.alpha = span(70, 140)
+		}
+		wl.particles = append(wl.particles, p)
+	}
+	return wl
+}
+
+// fogBank is a soft elliptical puff of fog r wide each side of its center,
+// alpha at its thickest
+func fogBank(r, alpha float64) image.Image {
+	w, h := max(1, int(r*2)), max(1, int(r*0.9))
+	img := image.NewNRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			dx := (float64(x)+0.5)/float64(w)*2 - 1
+			dy := (float64(y)+0.5)/float64(h)*2 - 1
+			d := 1 - math.Min(1, math.Hypot(dx, dy))
+			i := img.PixOffset(x, y)
+			img.Pix[i], img.Pix[i+1], img.Pix[i+2] = 215, 220, 228
+			img.Pix[i+3] = uint8(alpha * d * d)
+		}
+	}
+	return img
+}
+
+// wrap keeps v within [-margin, limit+margin) so particles that leave one
+// edge come back in at the other
+func wrap(v, limit, margin float64) float64 {
+	span := limit + 2*margin
+	return math.Mod(math.Mod(v+margin, span)+span, span) - margin
+}
+
+// draw paints the particles t seconds in
+func (wl *weatherLayer) draw(dc *gg.Context, t float64) {
+	if wl.frame == nil || wl.frameT != t {
+		if wl.frame == nil {
+			wl.frame = gg.NewContext(int(wl.w), int(wl.h))
+		}
+		wl.frame.SetColor(color.Transparent)
+		wl.frame.Clear()
+		wl.paint(wl.frame, t)
+		wl.frameT = t
+	}
+	utils.Blit(dc, wl.frame.Image(), 0, 0)
+}
+
+// paint draws every particle at time t
+func (wl *weatherLayer) paint(dc *gg.Context, t float64) {
+	for _, p := range wl.particles {
+		margin := p.size * 2
+		x := wrap(p.x+p.vx*t, wl.w, margin)
+		y := wrap(p.y+p.vy*t, wl.h, margin)
+		switch wl.kind {
+		case "rain":
+			dx, dy := p.vx/p.vy*p.size, p.size
+			dc.DrawLine(x, y, x+dx, y+dy)
+			dc.SetColor(color.NRGBA{190, 210, 255, uint8(p.alpha)})
+			dc.SetLineWidth(math.Max(1, p.size/16))
+			dc.Stroke()
+		case "snow":
+			x += math.Sin(t*1.5+p.phase) * 10
+			dc.DrawCircle(x, y, p.size)
+			dc.SetColor(color.NRGBA{255, 255, 255, uint8(p.alpha)})
+			dc.Fill()
+		case "fog":
+			y = p.y + math.Sin(t*0.4+p.phase)*8
+			b := p.img.Bounds()
+			dc.DrawImage(p.img, int(x)-b.Dx()/2, int(y)-b.Dy()/2)
+		case "embers":
+			x += math.Sin(t*2+p.phase) * 8
+			a := p.alpha * (0.6 + 0.4*math.Sin(t*9+p.phase*3))
+			glow := gg.NewRadialGradient(x, y, 0, x, y, p.size*4)
+			glow.AddColorStop(0, color.NRGBA{255, 140, 40, uint8(a * 0.5)})
+			glow.AddColorStop(1, color.NRGBA{255, 80, 20, 0})
+			dc.SetFillStyle(glow)
+			dc.DrawCircle(x, y, p.size*4)
+			dc.Fill()
+			dc.DrawCircle(x, y, p.size)
+			dc.SetColor(color.NRGBA{255, 225, 150, uint8(a)})
+			dc.Fill()
+		case "sandstorm":
+			dc.DrawLine(x, y, x-p.size, y-p.size*p.vy/p.vx)
+			dc.SetColor(color.NRGBA{225, 190, 130, uint8(p.alpha)})
+			dc.SetLineWidth(math.Max(1, p.size/14))
+			dc.Stroke()
+		}
+	}
+}
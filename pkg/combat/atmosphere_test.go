@@ -0,0 +1,122 @@
+package combat
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestCheckAtmosphere(t *testing.T) {
+	tests := []struct {
+		time, weather string
+		want          string // Normalized "time/weather", or the error
+	}{
+		{"", "", "/"},
+		{"night", "rain", "night/rain"},
+		{" Dusk ", "SNOW", "dusk/snow"},
+		{"day", "", "day/"},
+		{"", "Sandstorm", "/sandstorm"},
+		{"noon", "", `unknown timeOfDay "noon"`},
+		{"", "hail", `unknown weather "hail"`},
+		{"midnight", "hail", `unknown timeOfDay "midnight"`},
+	}
+	for _, tt := range tests {
+		req := &CombatRequest{TimeOfDay: tt.time, Weather: tt.weather}
+		var got string
+		if err := req.checkAtmosphere(); err != nil {
+			got = err.Error()
+		} else {
+			got = req.TimeOfDay + "/" + req.Weather
+		}
+		if !strings.HasPrefix(got, tt.want) {
+			t.Errorf("%q, %q: %q; want %q", tt.time, tt.weather, got, tt.want)
+		}
+	}
+}
+
+func TestNewWeather(t *testing.T) {
+	for kind, style := range weatherStyles {
+		a, b := newWeather(kind, "7", 320, 200), newWeather(kind, "7", 320, 200)
+		if len(a.particles) != style.count {
+			t.Errorf("%s: %d particles; want %d", kind, len(a.particles), style.count)
+		}
+		if !reflect.DeepEqual(a.particles, b.particles) {
+			t.Errorf("%s: particles differ for the same seed", kind)
+		}
+		if reflect.DeepEqual(a.particles, newWeather(kind, "8", 320, 200).particles) {
+			t.Errorf("%s: same particles for seeds 7 and 8", kind)
+		}
+	}
+	if wl := newWeather("hail", "7", 320, 200); wl != nil {
+		t.Errorf("newWeather(hail) = %v; want nil", wl)
+	}
+}
+
+func TestWrap(t *testing.T) {
+	tests := []struct {
+		v, limit, margin float64
+		want             float64
+	}{
+		{50, 100, 10, 50},
+		{-10, 100, 10, -10},
+		{110, 100, 10, -10},
+		{115, 100, 10, -5},
+		{-15, 100, 10, 105},
+		{50 + 3*120, 100, 10, 50},
+		{50 - 3*120, 100, 10, 50},
+	}
+	for _, tt := range tests {
+		if got := wrap(tt.v, tt.limit, tt.margin); got != tt.want {
+			t.Errorf("wrap(%v, %v, %v) = %v; want %v", tt.v, tt.limit, tt.margin, got, tt.want)
+		}
+	}
+}
+
+// Weather redraws the whole canvas when it moves, so an animated turn with
+// weather must stay close to the size of the same turn without it
+func TestWeatherGIFSize(t *testing.T) {
+	if testing.Short() {
+		t.Skip("renders two animated turns")
+	}
+	const assets = "../../assets"
+	if err := LoadManifest(assets + "/rpgasset/manifest.json"); err != nil {
+		t.Fatal(err)
+	}
+	theme, err := LoadTheme("", assets)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	turn := func(weather string) int {
+		req := &CombatRequest{
+			Players: []Player{
+				{Name: "Ann", Class: "FIGHTER", Level: 5, HP: 60, MaxHP: 100},
+				{Name: "Bo", Class: "MAGE", Level: 5, HP: 50, MaxHP: 100},
+			},
+			Enemies: []Enemy{
+				{Name: "Bat", CurrentHP: 20, MaxHP: 20},
+				{Name: "Ogre", CurrentHP: 120, MaxHP: 200, IsBoss: true},
+			},
+			Actions: []CombatAction{
+				{Attacker: "player:0", Target: "enemy:0", Skill: "Slash", Damage: 20, Killed: true},
+				{Attacker: "enemy:1", Target: "player:1", Skill: "Smash", Damage: 30},
+				{Attacker: "player:1", Target: "enemy:1", Skill: "Fire", Damage: 40},
+			},
+			Background: "env3.png",
+			Weather:    weather,
+			Seed:       "7",
+		}
+		buf, err := animateTurn(buildScene(req, theme, assets), req.Actions)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return len(buf)
+	}
+
+	plain := turn("")
+	for _, weather := range []string{"snow", "rain"} {
+		if size := turn(weather); size > plain*3/2 {
+			t.Errorf("%s turn is %d KB; want at most 1.5x the %d KB turn without weather", weather, size/1024, plain/1024)
+		}
+	}
+}
@@ -0,0 +1,144 @@
+package combat
+
+import (
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"math/rand"
+	"path/filepath"
+	"strings"
+)
+
+// Seed makes per-request choices repeatable, e.g. one arena for a whole
+// dungeon run. The bot may send it as a number or a string.
+type Seed string
+
+// UnmarshalJSON accepts a JSON string or number. Numbers keep the text they
+// were sent as.
+func (s *Seed) UnmarshalJSON(data []byte) error {
+	var str string
+	if err := json.Unmarshal(data, &str); err == nil {
+		*s = Seed(str)
+		return nil
+	}
+	var num json.Number
+	if err := json.Unmarshal(data, &num); err != nil {
+		return fmt.Errorf("seed must be a string or a number, got %s", data)
+	}
+	*s = Seed(num)
+	return nil
+}
+
+// rand returns a generator for one kind of choice (salt) so different choices
+// made from the same seed don't move in lockstep. Without a seed it is random.
+func (s Seed) rand(salt string) *rand.Rand {
+	if s == "" {
+		return rand.New(rand.NewSource(rand.Int63()))
+	}
+	h := fnv.New64a()
+	h.Write([]byte(salt))
+	h.Write([]byte{0})
+	h.Write([]byte(s))
+	return rand.New(rand.NewSource(int64(h.Sum64())))
+}
+
+// selectBackground picks the scene's environment: the requested file, then
+// the requested biome, then a biome tagged with the enemies' element, then any
+// environment. The pick within a pool is stable for a given seed.
+func selectBackground(req *CombatRequest, assetsPath string) string {
+	if path := backgroundFile(req.Background, assetsPath); path != "" {
+		return path
+	}
+
+	envPath := filepath.Join(assetsPath, "rpgasset", "environment")
+	m := Sprites()
+	var pool []string
+	if biome := strings.ToUpper(strings.TrimSpace(req.Biome)); biome != "" {
+		for _, f := range spriteFiles(m.Environments, biome) {
+			pool = append(pool, filepath.Join(envPath, f))
+		}
+	}
+	if len(pool) == 0 {
+		pool = elementBackgrounds(m, req.dominantElement(), envPath)
+	}
+	if len(pool) == 0 {
+		pool = listBackgrounds(assetsPath)
+	}
+	if len(pool) == 0 {
+		return ""
+	}
+	return pool[req.Seed.rand("background").Intn(len(pool))]
+}
+
+// elementBackgrounds returns the environments of every biome tagged with element
+func elementBackgrounds(m *Manifest, element, envPath string) []string {
+	if element == "" {
+		return nil
+	}
+	seen := make(map[string]bool)
+	var files []string
+	for _, key := range sortedGroupKeys(m.Environments) {
+		if !hasTag(m.Environments[key].Tags, element) {
+			continue
+		}
+		for _, f := range spriteFiles(m.Environments, key) {
+			if !seen[f] {
+				seen[f] = true
+				files = append(files, filepath.Join(envPath, f))
+			}
+		}
+	}
+	return files
+}
+
+// dominantElement is the boss's element, otherwise the most common element
+// among the enemies (first seen wins ties)
+func (req *CombatRequest) dominantElement() string {
+	counts := make(map[string]int)
+	best := ""
+	for _, e := range req.Enemies {
+		el := strings.ToUpper(strings.TrimSpace(e.Element))
+		if el == "" {
+			continue
+		}
+		if e.IsBoss {
+			return el
+		}
+		counts[el]++
+		if counts[el] > counts[best] {
+			best = el
+		}
+	}
+	return best
+}
+
+// checkBackground rejects a background that isn't a bare filename, so a
+// request can only pick art from rpgasset/environment
+func checkBackground(name string) error {
+	if name != "" && (name != filepath.Base(name) || strings.ContainsAny(name, `/\`) || name == "." || name == "..") {
+		return fmt.Errorf("background %q must be a filename in rpgasset/environment", name)
+	}
+	return nil
+}
+
+// backgroundFile returns the path for a background filename, or "" when it
+// isn't one of the environments
+func backgroundFile(name string, assetsPath string) string {
+	if name == "" || checkBackground(name) != nil {
+		return ""
+	}
+	path := filepath.Join(assetsPath, "rpgasset", "environment", name)
+	if !fileExists(path) {
+		return ""
+	}
+	return path
+}
+
+func hasTag(tags []string, tag string) bool {
+	for _, t := range tags {
+		if strings.EqualFold(t, tag) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,77 @@
+package combat
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestBackgroundFile(t *testing.T) {
+	assets := t.TempDir()
+	env := filepath.Join(assets, "rpgasset", "environment")
+	if err := os.MkdirAll(env, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(env, "cave.png"), nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	outside := filepath.Join(assets, "secret.png")
+	if err := os.WriteFile(outside, nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		want    string
+		invalid bool
+	}{
+		{"", "", false},
+		{"cave.png", filepath.Join(env, "cave.png"), false},
+		{"missing.png", "", false},
+		{outside, "", true},
+		{"../../secret.png", "", true},
+		{"environment/cave.png", "", true},
+		{`..\secret.png`, "", true},
+		{"..", "", true},
+		{".", "", true},
+	}
+	for _, tt := range tests {
+		if got := backgroundFile(tt.name, assets); got != tt.want {
+			t.Errorf("backgroundFile(%q) = %q; want %q", tt.name, got, tt.want)
+		}
+		if err := checkBackground(tt.name); (err != nil) != tt.invalid {
+			t.Errorf("checkBackground(%q) = %v; want invalid %v", tt.name, err, tt.invalid)
+		}
+	}
+}
+
+func TestSeedJSON(t *testing.T) {
+	tests := []struct {
+		data string
+		want Seed
+		ok   bool
+	}{
+		{`"run-42"`, "run-42", true},
+		{`7`, "7", true},
+		{`"7"`, "7", true},
+		{`12345678901234567890`, "12345678901234567890", true},
+		{`1.5`, "1.5", true},
+		{`true`, "", false},
+		{`{"a": 1}`, "", false},
+		{`[7]`, "", false},
+	}
+	for _, tt := range tests {
+		var s Seed
+		err := json.Unmarshal([]byte(tt.data), &s)
+		if (err == nil) != tt.ok || s != tt.want {
+			t.Errorf("%s: seed %q, error %v; want %q", tt.data, s, err, tt.want)
+		}
+	}
+	if Seed("7").rand("background").Int63() != Seed("7").rand("background").Int63() {
+		t.Error("the same seed drew different numbers")
+	}
+	if Seed("7").rand("background").Int63() == Seed("7").rand("weather").Int63() {
+		t.Error("different salts drew the same number")
+	}
+}
@@ -0,0 +1,320 @@
+package combat
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"math"
+	"path/filepath"
+	"strings"
+
+	"image-service/pkg/utils"
+
+	"github.com/disintegration/imaging"
+	"github.com/fogleman/gg"
+	"github.com/gin-gonic/gin"
+)
+
+// BossIntroRequest is the splash shown as a party enters a boss room. The
+// boss picks its sprite like an enemy in a combat request; tier (MID, HIGH,
+// CALAMITY) or level chooses from the boss groups.
+type BossIntroRequest struct {
+	Enemy
+	Title    string `json:"title"`    // Epithet under the name, e.g. "Devourer of Worlds"
+	Threat   string `json:"threat"`   // Badge text; the tier's by default
+	Animated bool   `json:"animated"` // GIF zooming in on the boss
+
+	Background string `json:"background"`
+	Biome      string `json:"biome"`
+	Seed       Seed   `json:"seed"`
+}
+
+// bossTierStyle is how a boss tier is announced
+type bossTierStyle struct {
+	threat string
+	stars  int
+	c      color.RGBA
+}
+
+var bossTierStyles = map[string]bossTierStyle{
+	"MID":      {"MID BOSS", 1, color.RGBA{245, 158, 11, 255}},
+	"HIGH":     {"HIGH BOSS", 2, color.RGBA{239, 68, 68, 255}},
+	"CALAMITY": {"CALAMITY", 3, color.RGBA{190, 80, 255, 255}},
+}
+
+const (
+	bossIntroFrames = 8
+	bossZoomDelay   = 10
+	bossIntroGIFW   = 640 // The GIF is scaled down from the canvas to stay around 1 MB
+	bossZoom        = 0.3 // Extra scale reached at the end of the zoom
+	bossRays        = 16
+)
+
+func GenerateBossIntro(c *gin.Context) {
+	var req BossIntroRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	if err := checkBackground(req.Background); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+
+	intro, err := newBossIntro(&req, "assets")
+	if err != nil {
+		c.JSON(500, gin.H{"error": "Failed to load boss sprite"})
+		return
+	}
+	c.Header("X-Enemy-Sprites", intro.spriteFile)
+	c.Header("X-Background", intro.backgroundFile)
+
+	if req.Animated {
+		gb := utils.NewGIFBuilder()
+		dc := gg.NewContext(CANVAS_W, CANVAS_H)
+		for f := 0; f <= bossIntroFrames; f++ {
+			t := float64(f) / bossIntroFrames
+			intro.draw(dc, t)
+			delay := bossZoomDelay
+			if f == bossIntroFrames {
+				delay = holdDelay
+			}
+			gb.AddFrame(imaging.Resize(dc.Image(), bossIntroGIFW, 0, imaging.Linear), delay)
+		}
+		buf, err := gb.Bytes()
+		if err != nil {
+			c.JSON(500, gin.H{"error": "Failed to encode animation"})
+			return
+		}
+		c.Data(200, "image/gif", buf)
+		return
+	}
+
+	dc := gg.NewContext(CANVAS_W, CANVAS_H)
+	intro.draw(dc, 1)
+	buf, err := utils.EncodeImageToBuffer(dc.Image())
+	if err != nil {
+		c.JSON(500, gin.H{"error": "Failed to encode image"})
+		return
+	}
+
+	c.Data(200, "image/png", buf)
+}
+
+// bossIntro holds the splash's loaded art so animation frames only redraw it
+type bossIntro struct {
+	req            *BossIntroRequest
+	background     image.Image
+	backgroundFile string
+	sprite         image.Image // Cropped to its opaque bounds
+	spriteFile     string
+	vignette       image.Image
+	rayGlow        *image.Alpha // The rays' alpha before they're cut out
+	core           image.Image  // Glow right behind the boss
+	style          bossTierStyle
+	light          color.NRGBA // Ray color: the boss's element, or its tier's
+}
+
+func newBossIntro(req *BossIntroRequest, assetsPath string) (*bossIntro, error) {
+	boss := req.Enemy
+	boss.IsBoss = true
+	spritePath := SelectEnemySprite(boss, 0, boss.Level, assetsPath)
+	sprite, err := loadEnemy(boss, spritePath)
+	if err != nil {
+		return nil, err
+	}
+	if b := utils.OpaqueBounds(sprite, 16); !b.Empty() {
+		sprite = imaging.Crop(sprite, b)
+	}
+
+	b := &bossIntro{req: req, sprite: sprite, spriteFile: filepath.Base(spritePath)}
+	bgPath := selectBackground(&CombatRequest{Background: req.Background, Biome: req.Biome, Seed: req.Seed, Enemies: []Enemy{boss}}, assetsPath)
+	if bg, err := utils.LoadImage(bgPath); bgPath != "" && err == nil {
+		b.background = imaging.Fill(bg, CANVAS_W, CANVAS_H, imaging.Center, imaging.Lanczos)
+		b.backgroundFile = filepath.Base(bgPath)
+	}
+
+	b.style = bossTierStyles[bossTier(boss)]
+	b.light = bossLight(boss.Element, b.style)
+	b.prerender()
+	return b, nil
+}
+
+// prerender draws the parts of the splash that don't move. gg's gradient
+// fills are slow, and every animation frame would redraw them.
+func (b *bossIntro) prerender() {
+	cx, cy := bossCenter()
+	fill := func(g gg.Gradient, shape func(dc *gg.Context)) *image.RGBA {
+		dc := gg.NewContext(CANVAS_W, CANVAS_H)
+		dc.SetFillStyle(g)
+		shape(dc)
+		dc.Fill()
+		return dc.Image().(*image.RGBA)
+	}
+	whole := func(dc *gg.Context) { dc.DrawRectangle(0, 0, CANVAS_W, CANVAS_H) }
+
+	v := gg.NewRadialGradient(cx, cy, float64(CANVAS_H)*0.35, cx, cy, float64(CANVAS_W)*0.6)
+	v.AddColorStop(0, color.NRGBA{0, 0, 0, 0})
+	v.AddColorStop(1, color.NRGBA{0, 0, 0, 235})
+	b.vignette = fill(v, whole)
+
+	// The rays' light: its alpha is cut to the ray shapes each frame
+	glow := gg.NewRadialGradient(cx, cy, 0, cx, cy, float64(CANVAS_W))
+	glow.AddColorStop(0, color.NRGBA{255, 255, 255, 80})
+	glow.AddColorStop(1, color.NRGBA{255, 255, 255, 0})
+	b.rayGlow = image.NewAlpha(image.Rect(0, 0, CANVAS_W, CANVAS_H))
+	draw.Draw(b.rayGlow, b.rayGlow.Bounds(), fill(glow, whole), image.Point{}, draw.Src)
+
+	r := float64(CANVAS_H) * 0.45
+	core := gg.NewRadialGradient(cx, cy, 0, cx, cy, r)
+	core.AddColorStop(0, withAlpha(b.light, 130))
+	core.AddColorStop(1, withAlpha(b.light, 0))
+	b.core = fill(core, func(dc *gg.Context) { dc.DrawCircle(cx, cy, r) })
+}
+
+// bossTier is the boss group a boss comes from: its tier when it names one,
+// otherwise its level bucket as GetEnemySpritePath picks it
+func bossTier(boss Enemy) string {
+	tier := strings.ToUpper(strings.TrimSpace(boss.Tier))
+	if _, ok := bossTierStyles[tier]; ok {
+		return tier
+	}
+	switch {
+	case boss.Level <= 60:
+		return "MID"
+	case boss.Level <= 90:
+		return "HIGH"
+	default:
+		return "CALAMITY"
+	}
+}
+
+// bossLight is the rays' color: the glow of the boss's element, or its
+// tier's color when it has none
+func bossLight(element string, style bossTierStyle) color.NRGBA {
+	el := strings.ToLower(strings.TrimSpace(element))
+	if alias, ok := fxElementAliases[el]; ok {
+		el = alias
+	}
+	if p, ok := fxElements[el]; ok && el != "" {
+		return p.glow
+	}
+	return color.NRGBA(style.c)
+}
+
+// bossView is the part of the canvas still in view at zoom, which scales
+// about bossCenter
+func bossView(zoom float64) image.Rectangle {
+	cx, cy := bossCenter()
+	w, h := float64(CANVAS_W)/zoom, float64(CANVAS_H)/zoom
+	x, y := cx-w/2, cy-cy/zoom
+	return image.Rect(int(x), int(y), int(x+w), int(y+h))
+}
+
+// bossCenter is the point the zoom and the light rays center on
+func bossCenter() (float64, float64) {
+	return float64(CANVAS_W) / 2, float64(CANVAS_H) * 0.46
+}
+
+// draw paints the splash at zoom progress t, from 0 (wide) to 1 (closest).
+// The name, title and badge fade in over the second half.
+func (b *bossIntro) draw(dc *gg.Context, t float64) {
+	ease := 1 - math.Pow(1-t, 3)
+	zoom := 1 + bossZoom*ease
+	cx, cy := bossCenter()
+
+	// Arena, zoomed with the boss and darkened so the light reads
+	dc.SetColor(color.Black)
+	dc.Clear()
+	if b.background != nil {
+		bg := b.background
+		if zoom > 1 {
+			// Crop the part that stays in view, then scale it up to the canvas
+			bg = imaging.Crop(bg, bossView(zoom))
+			bg = imaging.Resize(bg, CANVAS_W, CANVAS_H, imaging.Linear)
+		}
+		utils.Blit(dc, bg, 0, 0)
+	}
+	dc.SetColor(color.NRGBA{0, 0, 0, 130})
+	dc.DrawRectangle(0, 0, CANVAS_W, CANVAS_H)
+	dc.Fill()
+
+	b.drawRays(dc, ease)
+
+	// Boss art is often small, so it is scaled up to fill the frame, not just fitted
+	sb := b.sprite.Bounds()
+	scale := math.Min(float64(CANVAS_W)*0.45/float64(sb.Dx()), float64(CANVAS_H)*0.44/float64(sb.Dy())) * zoom
+	img := imaging.Resize(b.sprite, max(1, int(float64(sb.Dx())*scale)), 0, imaging.Lanczos)
+	ib := img.Bounds()
+	feet := cy + float64(ib.Dy())/2
+	utils.DrawShadow(dc, cx, feet-6, float64(ib.Dx())*0.4, 0.7)
+	utils.Blit(dc, img, int(cx)-ib.Dx()/2, int(feet)-ib.Dy())
+
+	utils.Blit(dc, b.vignette, 0, 0)
+
+	b.drawCaption(dc, math.Max(0, math.Min(1, (t-0.5)*2)))
+}
+
+// drawRays draws light rays fanning out from behind the boss, turning a
+// little as the zoom plays
+func (b *bossIntro) drawRays(dc *gg.Context, spin float64) {
+	cx, cy := bossCenter()
+	reach := float64(CANVAS_W)
+	shape := gg.NewContext(CANVAS_W, CANVAS_H)
+	for i := 0; i < bossRays; i++ {
+		a := (float64(i) + spin*0.5) * 2 * math.Pi / bossRays
+		half := math.Pi / bossRays * 0.45
+		if i%2 == 1 {
+			half *= 0.5
+		}
+		shape.MoveTo(cx, cy)
+		shape.LineTo(cx+math.Cos(a-half)*reach, cy+math.Sin(a-half)*reach)
+		shape.LineTo(cx+math.Cos(a+half)*reach, cy+math.Sin(a+half)*reach)
+		shape.ClosePath()
+	}
+	shape.SetColor(color.White)
+	shape.Fill()
+
+	mask := shape.AsMask()
+	for i, a := range mask.Pix {
+		mask.Pix[i] = uint8(int(a) * int(b.rayGlow.Pix[i]) / 255)
+	}
+	light := image.NewUniform(color.NRGBA{b.light.R, b.light.G, b.light.B, 255})
+	draw.DrawMask(dc.Image().(draw.Image), mask.Bounds(), light, image.Point{}, mask, image.Point{}, draw.Over)
+	utils.Blit(dc, b.core, 0, 0)
+}
+
+// drawCaption draws the threat badge at the top and the boss's name and
+// title at the bottom, at opacity alpha
+func (b *bossIntro) drawCaption(dc *gg.Context, alpha float64) {
+	if alpha <= 0 {
+		return
+	}
+	// Drawn on a layer so the text and its shadow fade together
+	layer := gg.NewContext(CANVAS_W, CANVAS_H)
+	setFont := cardFont(layer)
+
+	threat := b.req.Threat
+	if threat == "" {
+		threat = b.style.threat
+	}
+	if face := setFont(26); face != nil {
+		drawBadge(layer, face, strings.ToUpper(threat), CANVAS_W/2, 28, b.style.c)
+	}
+	for i := 0; i < b.style.stars; i++ {
+		x := CANVAS_W/2 + (float64(i)-float64(b.style.stars-1)/2)*30
+		drawStar(layer, x, 92, 12, b.style.c)
+	}
+
+	name := b.req.Name
+	if name == "" {
+		name = "???"
+	}
+	if face := setFont(78); face != nil {
+		drawInkOutlined(layer, face, truncateText(layer, strings.ToUpper(name), CANVAS_W-80), CANVAS_W/2, CANVAS_H-118, 0.5, 0.5, color.White, color.Black, 3)
+	}
+	if face := setFont(30); face != nil && b.req.Title != "" {
+		drawInk(layer, face, truncateText(layer, b.req.Title, CANVAS_W-120), CANVAS_W/2, CANVAS_H-52, 0.5, 0.5, b.style.c)
+	}
+	utils.DrawImageAlpha(dc, layer.Image(), 0, 0, alpha)
+}
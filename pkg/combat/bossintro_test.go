@@ -0,0 +1,73 @@
+package combat
+
+import (
+	"image"
+	"image/color"
+	"math"
+	"testing"
+)
+
+func TestBossTier(t *testing.T) {
+	tests := []struct {
+		boss Enemy
+		want string
+	}{
+		{Enemy{Tier: "calamity"}, "CALAMITY"},
+		{Enemy{Tier: " High ", Level: 10}, "HIGH"},
+		{Enemy{Tier: "mythic", Level: 10}, "MID"},
+		{Enemy{Level: 60}, "MID"},
+		{Enemy{Level: 61}, "HIGH"},
+		{Enemy{Level: 90}, "HIGH"},
+		{Enemy{Level: 91}, "CALAMITY"},
+		{Enemy{}, "MID"},
+	}
+	for _, tt := range tests {
+		if got := bossTier(tt.boss); got != tt.want {
+			t.Errorf("bossTier(%+v) = %s; want %s", tt.boss, got, tt.want)
+		}
+	}
+}
+
+func TestBossLight(t *testing.T) {
+	high := bossTierStyles["HIGH"]
+	tests := []struct {
+		element string
+		want    string // Palette key, or "tier" for the tier's color
+	}{
+		{"fire", "fire"},
+		{" Frost ", "ice"},
+		{"void", "dark"},
+		{"", "tier"},
+		{"physical", "tier"},
+		{"plasma", "tier"},
+	}
+	for _, tt := range tests {
+		want := fxElements[tt.want].glow
+		if tt.want == "tier" {
+			want = color.NRGBA(high.c)
+		}
+		if got := bossLight(tt.element, high); got != want {
+			t.Errorf("bossLight(%q) = %v; want %s %v", tt.element, got, tt.want, want)
+		}
+	}
+}
+
+func TestBossView(t *testing.T) {
+	canvas := image.Rect(0, 0, CANVAS_W, CANVAS_H)
+	if got := bossView(1); got != canvas {
+		t.Errorf("bossView(1) = %v; want the canvas %v", got, canvas)
+	}
+	cx, cy := bossCenter()
+	for _, zoom := range []float64{1.05, 1.15, 1 + bossZoom} {
+		v := bossView(zoom)
+		if !v.In(canvas) {
+			t.Errorf("bossView(%v) = %v; want it inside %v", zoom, v, canvas)
+		}
+		// Scaled back up to the canvas, the boss's center must not move
+		x := (cx - float64(v.Min.X)) * float64(CANVAS_W) / float64(v.Dx())
+		y := (cy - float64(v.Min.Y)) * float64(CANVAS_H) / float64(v.Dy())
+		if math.Abs(x-cx) > 2 || math.Abs(y-cy) > 2 {
+			t.Errorf("bossView(%v) moves the center to (%.1f, %.1f); want (%.1f, %.1f)", zoom, x, y, cx, cy)
+		}
+	}
+}
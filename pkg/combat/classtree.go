@@ -0,0 +1,379 @@
+package combat
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"math"
+	"strings"
+
+	"image-service/pkg/utils"
+
+	"github.com/disintegration/imaging"
+	"github.com/fogleman/gg"
+	"github.com/gin-gonic/gin"
+	"golang.org/x/image/font"
+)
+
+// ClassTreeRequest draws a promotion tree with the player's place on it. The
+// tree comes from classes when sent, otherwise from the manifest's
+// evolvesFrom links.
+type ClassTreeRequest struct {
+	Name        string      `json:"name"`
+	Class       string      `json:"class"` // The player's current class, highlighted
+	SpriteIndex int         `json:"spriteIndex"`
+	Level       int         `json:"level"`   // Promotions needing more are locked; 0 ignores levels
+	Root        string      `json:"root"`    // Base class to draw from; the current class's by default
+	Classes     []ClassNode `json:"classes"` // The tree, one entry per class
+
+	Background string `json:"background"`
+	Biome      string `json:"biome"`
+	Seed       Seed   `json:"seed"`
+}
+
+// ClassNode is one class in a request's tree: the class it's promoted from
+// (empty for a base class) and the level the promotion takes
+type ClassNode struct {
+	Class  string `json:"class"`
+	From   string `json:"from"`
+	Level  int    `json:"level"`
+	Locked bool   `json:"locked"` // Locked regardless of class and level
+}
+
+const maxTreeClasses = 40
+
+// Where a class stands for the player, in increasing order of progress
+const (
+	classLocked = iota
+	classAvailable
+	classOwned
+	classCurrent
+)
+
+var lockedColor = color.RGBA{110, 110, 120, 255}
+
+// treeNode is a class placed on the tree
+type treeNode struct {
+	ClassNode
+	children []*treeNode
+	depth    int
+	state    int
+	x, y     float64
+}
+
+func GenerateClassTree(c *gin.Context) {
+	var req ClassTreeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	if err := checkBackground(req.Background); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	root, err := buildClassTree(&req)
+	if err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+
+	buf, err := utils.EncodeImageToBuffer(renderClassTree(&req, root, "assets"))
+	if err != nil {
+		c.JSON(500, gin.H{"error": "Failed to encode image"})
+		return
+	}
+
+	c.Data(200, "image/png", buf)
+}
+
+// buildClassTree links the request's classes, or the manifest's, into the
+// tree under the root class and works out where the player stands on it
+func buildClassTree(req *ClassTreeRequest) (*treeNode, error) {
+	req.Class = strings.ToUpper(strings.TrimSpace(req.Class))
+	req.Root = strings.ToUpper(strings.TrimSpace(req.Root))
+
+	classes := req.Classes
+	if len(classes) == 0 {
+		m := Sprites()
+		for _, key := range sortedGroupKeys(m.Characters) {
+			g := m.Characters[key]
+			classes = append(classes, ClassNode{Class: key, From: g.EvolvesFrom, Level: g.EvolveLevel})
+		}
+	}
+
+	nodes := make(map[string]*treeNode, len(classes))
+	var order []*treeNode
+	for _, cn := range classes {
+		cn.Class = strings.ToUpper(strings.TrimSpace(cn.Class))
+		cn.From = strings.ToUpper(strings.TrimSpace(cn.From))
+		if cn.Class == "" {
+			return nil, fmt.Errorf("every class needs a name")
+		}
+		if nodes[cn.Class] != nil {
+			return nil, fmt.Errorf("duplicate class %q", cn.Class)
+		}
+		n := &treeNode{ClassNode: cn}
+		nodes[cn.Class] = n
+		order = append(order, n)
+	}
+	for _, n := range order {
+		if n.From == "" {
+			continue
+		}
+		parent := nodes[n.From]
+		if parent == nil {
+			return nil, fmt.Errorf("class %q evolves from unknown class %q", n.Class, n.From)
+		}
+		parent.children = append(parent.children, n)
+	}
+
+	// The root is the one asked for, or the base class of the player's
+	rootKey := req.Root
+	if rootKey == "" {
+		if nodes[req.Class] == nil {
+			return nil, fmt.Errorf("send root, or a class that's in the tree")
+		}
+		rootKey = req.Class
+		for steps := 0; nodes[rootKey].From != ""; steps++ {
+			if steps > len(nodes) {
+				return nil, fmt.Errorf("class %q evolves from itself", req.Class)
+			}
+			rootKey = nodes[rootKey].From
+		}
+	}
+	root := nodes[rootKey]
+	if root == nil {
+		return nil, fmt.Errorf("unknown root class %q", rootKey)
+	}
+	if len(req.Classes) == 0 && len(root.children) == 0 {
+		return nil, fmt.Errorf("the manifest has no promotions from %q; send classes", rootKey)
+	}
+
+	// The player's path is their class and everything it was promoted from
+	path := make(map[*treeNode]bool)
+	if cur := nodes[req.Class]; cur != nil {
+		for n, steps := cur, 0; n != nil && steps <= len(nodes); n, steps = nodes[n.From], steps+1 {
+			path[n] = true
+		}
+	}
+	inTree := path[root]
+	seen := map[*treeNode]bool{root: true}
+	queue := []*treeNode{root}
+	for len(queue) > 0 {
+		n := queue[0]
+		queue = queue[1:]
+
+		// Classes past the current one are open if the level allows; other
+		// branches, and everything under one that's locked, stay locked
+		switch {
+		case n.Class == req.Class:
+			n.state = classCurrent
+		case path[n] && inTree:
+			n.state = classOwned
+		case n.Locked || (req.Level > 0 && n.Level > req.Level):
+			n.state = classLocked
+		case n == root:
+			n.state = classAvailable
+		default:
+			if p := nodes[n.From].state; p == classAvailable || p == classCurrent {
+				n.state = classAvailable
+			}
+		}
+
+		for _, child := range n.children {
+			if seen[child] {
+				return nil, fmt.Errorf("class %q evolves from itself", child.Class)
+			}
+			seen[child] = true
+			if len(seen) > maxTreeClasses {
+				return nil, fmt.Errorf("too many classes under %s (max %d)", root.Class, maxTreeClasses)
+			}
+			child.depth = n.depth + 1
+			queue = append(queue, child)
+		}
+	}
+	return root, nil
+}
+
+// renderClassTree draws the title and the tree top-down from its base class
+func renderClassTree(req *ClassTreeRequest, root *treeNode, assetsPath string) image.Image {
+	dc := gg.NewContext(CANVAS_W, CANVAS_H)
+	setFont := cardFont(dc)
+
+	drawBlurredArena(dc, &CombatRequest{Background: req.Background, Biome: req.Biome, Seed: req.Seed}, assetsPath)
+
+	title := strings.ToUpper(treeClassName(root.Class)) + " CLASS TREE"
+	if req.Name != "" {
+		title = strings.ToUpper(req.Name)
+	}
+	if face := setFont(56); face != nil {
+		drawInk(dc, face, truncateText(dc, title, CANVAS_W-80), CANVAS_W/2, 42, 0.5, 0.5, victoryColor)
+	}
+	if face := setFont(22); face != nil && req.Class != "" {
+		sub := treeClassName(req.Class)
+		if req.Level > 0 {
+			sub = fmt.Sprintf("Level %d %s", req.Level, sub)
+		}
+		drawInk(dc, face, sub, CANVAS_W/2, 86, 0.5, 0.5, mutedColor)
+	}
+
+	size, labelW := layoutClassTree(root, image.Rect(30, 118, CANVAS_W-30, CANVAS_H-20))
+	var nodes []*treeNode
+	walkClassTree(root, func(n *treeNode) { nodes = append(nodes, n) })
+	for _, n := range nodes {
+		for _, child := range n.children {
+			drawPromotion(dc, n, child, size, setFont)
+		}
+	}
+	for _, n := range nodes {
+		drawClassNode(dc, req, n, size, labelW, setFont, assetsPath)
+	}
+	return dc.Image()
+}
+
+// layoutClassTree places classes in rows by promotion tier, giving each
+// final class its own column and centring the rest over their promotions.
+// It returns the size of a class's portrait and the room for its name.
+func layoutClassTree(root *treeNode, area image.Rectangle) (float64, float64) {
+	leaves, depth := 0, 0
+	walkClassTree(root, func(n *treeNode) {
+		if len(n.children) == 0 {
+			leaves++
+		}
+		depth = max(depth, n.depth)
+	})
+
+	colW := float64(area.Dx()) / float64(leaves)
+	rowH := math.Min(190, float64(area.Dy())/float64(depth+1))
+	top := float64(area.Min.Y) + (float64(area.Dy())-rowH*float64(depth+1))/2
+	size := math.Min(110, math.Min(colW*0.8, rowH*0.52))
+
+	next := 0
+	var place func(n *treeNode)
+	place = func(n *treeNode) {
+		n.y = top + rowH*float64(n.depth) + size/2 + 4
+		if len(n.children) == 0 {
+			n.x = float64(area.Min.X) + colW*(float64(next)+0.5)
+			next++
+			return
+		}
+		for _, child := range n.children {
+			place(child)
+		}
+		n.x = (n.children[0].x + n.children[len(n.children)-1].x) / 2
+	}
+	place(root)
+	return size, colW - 10
+}
+
+// walkClassTree visits the tree depth first, parents before their promotions
+func walkClassTree(n *treeNode, visit func(*treeNode)) {
+	visit(n)
+	for _, child := range n.children {
+		walkClassTree(child, visit)
+	}
+}
+
+// drawPromotion links a class to a promotion with an elbow line and a level
+// badge, gold along the player's path and grey into locked classes
+func drawPromotion(dc *gg.Context, from, to *treeNode, size float64, setFont func(float64) font.Face) {
+	lc := color.RGBA{230, 230, 240, 255}
+	switch {
+	case to.state >= classOwned:
+		lc = victoryColor
+	case to.state == classLocked:
+		lc = lockedColor
+	}
+
+	// The line leaves under the class's name and the badge sits on the
+	// drop into the promotion
+	y0, y1 := from.y+size/2+26, to.y-size/2-2
+	mid := y0 + 6
+	dc.MoveTo(from.x, y0)
+	dc.LineTo(from.x, mid)
+	dc.LineTo(to.x, mid)
+	dc.LineTo(to.x, y1)
+	dc.SetColor(color.NRGBA{0, 0, 0, 160})
+	dc.SetLineWidth(7)
+	dc.StrokePreserve()
+	dc.SetColor(lc)
+	dc.SetLineWidth(3)
+	dc.Stroke()
+
+	if face := setFont(14); face != nil && to.Level > 0 {
+		drawBadge(dc, face, fmt.Sprintf("Lv %d", to.Level), to.x, (mid+y1)/2-12, lc)
+	}
+}
+
+// drawClassNode draws a class's sprite in a frame with its name underneath.
+// The current class glows gold and locked ones are greyed with a padlock.
+func drawClassNode(dc *gg.Context, req *ClassTreeRequest, n *treeNode, size, labelW float64, setFont func(float64) font.Face, assetsPath string) {
+	x, y := n.x-size/2, n.y-size/2
+	border := color.RGBA{200, 200, 215, 255}
+	switch n.state {
+	case classCurrent, classOwned:
+		border = victoryColor
+	case classLocked:
+		border = lockedColor
+	}
+
+	if n.state == classCurrent {
+		glow := gg.NewRadialGradient(n.x, n.y, size*0.3, n.x, n.y, size*0.95)
+		glow.AddColorStop(0, color.NRGBA{255, 215, 0, 150})
+		glow.AddColorStop(1, color.NRGBA{255, 215, 0, 0})
+		dc.SetFillStyle(glow)
+		dc.DrawCircle(n.x, n.y, size*0.95)
+		dc.Fill()
+	}
+	dc.DrawRoundedRectangle(x, y, size, size, 10)
+	dc.SetColor(color.NRGBA{border.R / 6, border.G / 6, border.B / 6, 225})
+	dc.FillPreserve()
+	dc.SetColor(border)
+	dc.SetLineWidth(3)
+	if n.state == classCurrent {
+		dc.SetLineWidth(5)
+	}
+	dc.Stroke()
+
+	index := 0
+	if n.state == classCurrent {
+		index = req.SpriteIndex
+	}
+	if sprite, err := utils.LoadImage(GetCharacterSpritePath(n.Class, index, assetsPath)); err == nil {
+		if b := utils.OpaqueBounds(sprite, 16); !b.Empty() {
+			sprite = imaging.Crop(sprite, b)
+		}
+		sb := sprite.Bounds()
+		scale := (size - 14) / math.Max(float64(sb.Dx()), float64(sb.Dy()))
+		img := image.Image(imaging.Resize(sprite, max(1, int(float64(sb.Dx())*scale)), 0, imaging.Lanczos))
+		ib := img.Bounds()
+		if n.state == classLocked {
+			utils.DrawImageAlpha(dc, imaging.Grayscale(img), int(n.x)-ib.Dx()/2, int(y+size-7)-ib.Dy(), 0.55)
+		} else {
+			utils.Blit(dc, img, int(n.x)-ib.Dx()/2, int(y+size-7)-ib.Dy())
+		}
+	}
+	if n.state == classLocked {
+		drawLock(dc, x+size-12, y+14, 10)
+	}
+
+	nameColor := color.Color(color.White)
+	switch n.state {
+	case classCurrent:
+		nameColor = victoryColor
+	case classLocked:
+		nameColor = lockedColor
+	}
+	if face := setFont(17); face != nil {
+		drawInk(dc, face, truncateText(dc, treeClassName(n.Class), labelW), n.x, y+size+14, 0.5, 0.5, nameColor)
+	}
+}
+
+// treeClassName is a class's display name, or its key when the manifest
+// doesn't know it
+func treeClassName(class string) string {
+	if g, ok := Sprites().Characters[class]; ok && g.DisplayName != "" {
+		return g.DisplayName
+	}
+	return class
+}
@@ -0,0 +1,70 @@
+package combat
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestBuildClassTree(t *testing.T) {
+	useManifest(t, &Manifest{Characters: map[string]*SpriteGroup{
+		"FIGHTER": spriteGroup("fighter.png"),
+		"MAGE":    {EvolvesFrom: "FIGHTER", EvolveLevel: 10, Sprites: []SpriteMeta{{File: "mage.png"}}},
+	}})
+	tree := []ClassNode{
+		{Class: "NOVICE"},
+		{Class: "fighter", From: "novice", Level: 10},
+		{Class: "KNIGHT", From: "FIGHTER", Level: 30},
+		{Class: "BERSERKER", From: "FIGHTER", Level: 30, Locked: true},
+		{Class: "MAGE", From: "NOVICE", Level: 10},
+		{Class: "SAGE", From: "MAGE", Level: 30},
+	}
+	many := []ClassNode{{Class: "ROOT"}}
+	for i := 0; i < maxTreeClasses; i++ {
+		many = append(many, ClassNode{Class: fmt.Sprintf("C%d", i), From: "ROOT"})
+	}
+	states := map[int]string{classLocked: "L", classAvailable: "A", classOwned: "O", classCurrent: "C"}
+
+	tests := []struct {
+		name string
+		req  ClassTreeRequest
+		want string // class:state depth first, or the error
+	}{
+		{"midway", ClassTreeRequest{Class: "FIGHTER", Level: 20, Classes: tree},
+			"NOVICE:O FIGHTER:C KNIGHT:L BERSERKER:L MAGE:L SAGE:L"},
+		{"promotion open", ClassTreeRequest{Class: "FIGHTER", Level: 40, Classes: tree},
+			"NOVICE:O FIGHTER:C KNIGHT:A BERSERKER:L MAGE:L SAGE:L"},
+		{"levels ignored", ClassTreeRequest{Class: "fighter", Classes: tree},
+			"NOVICE:O FIGHTER:C KNIGHT:A BERSERKER:L MAGE:L SAGE:L"},
+		{"base class", ClassTreeRequest{Class: " novice ", Level: 5, Classes: tree},
+			"NOVICE:C FIGHTER:L KNIGHT:L BERSERKER:L MAGE:L SAGE:L"},
+		{"other branch", ClassTreeRequest{Class: "FIGHTER", Root: "mage", Level: 40, Classes: tree},
+			"MAGE:A SAGE:A"},
+		{"no class", ClassTreeRequest{Root: "NOVICE", Classes: tree},
+			"NOVICE:A FIGHTER:A KNIGHT:A BERSERKER:L MAGE:A SAGE:A"},
+		{"manifest", ClassTreeRequest{Class: "MAGE"}, "FIGHTER:O MAGE:C"},
+		{"manifest without promotions", ClassTreeRequest{Root: "MAGE"}, `no promotions from "MAGE"`},
+		{"no name", ClassTreeRequest{Class: "A", Classes: []ClassNode{{Class: "A"}, {Class: " "}}}, "every class needs a name"},
+		{"duplicate", ClassTreeRequest{Class: "A", Classes: []ClassNode{{Class: "A"}, {Class: "a"}}}, `duplicate class "A"`},
+		{"unknown parent", ClassTreeRequest{Class: "A", Classes: []ClassNode{{Class: "A", From: "Z"}}}, `"A" evolves from unknown class "Z"`},
+		{"class not in tree", ClassTreeRequest{Class: "ROGUE", Classes: tree}, "send root"},
+		{"unknown root", ClassTreeRequest{Root: "ROGUE", Classes: tree}, `unknown root class "ROGUE"`},
+		{"loop", ClassTreeRequest{Class: "A", Classes: []ClassNode{{Class: "A", From: "B"}, {Class: "B", From: "A"}}}, "evolves from itself"},
+		{"loop under the root", ClassTreeRequest{Root: "A", Classes: []ClassNode{{Class: "A", From: "B"}, {Class: "B", From: "A"}}}, "evolves from itself"},
+		{"too many", ClassTreeRequest{Root: "ROOT", Classes: many}, "too many classes under ROOT"},
+	}
+	for _, tt := range tests {
+		root, err := buildClassTree(&tt.req)
+		if err != nil {
+			if !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("%s: %v; want %q", tt.name, err, tt.want)
+			}
+			continue
+		}
+		var nodes []string
+		walkClassTree(root, func(n *treeNode) { nodes = append(nodes, n.Class+":"+states[n.state]) })
+		if got := strings.Join(nodes, " "); got != tt.want {
+			t.Errorf("%s: %q; want %q", tt.name, got, tt.want)
+		}
+	}
+}
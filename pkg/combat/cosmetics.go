@@ -0,0 +1,301 @@
+package combat
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"math"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"image-service/pkg/utils"
+
+	"github.com/disintegration/imaging"
+	"github.com/fogleman/gg"
+)
+
+// cosmeticSlots are the layers a sprite can anchor, with their default draw
+// order around the base sprite at 0
+var cosmeticSlots = map[string]int{
+	"aura":   -3,
+	"frame":  -2,
+	"weapon": 1,
+	"pet":    2,
+}
+
+// Per slot defaults for manifest cosmetics: width as a fraction of the
+// character's art width and the point on the layer that sits on the anchor
+var cosmeticDefaults = map[string]struct {
+	scale float64
+	pivot LayerAnchor
+}{
+	"frame":  {0.8, LayerAnchor{X: 0.5, Y: 0.5}},
+	"weapon": {0.45, LayerAnchor{X: 0.5, Y: 0.8}},
+	"pet":    {0.5, LayerAnchor{X: 0.5, Y: 1}},
+}
+
+// Named title frame colors, besides hex and element names
+var frameColors = map[string]color.NRGBA{
+	"gold":   {255, 205, 70, 255},
+	"silver": {215, 225, 235, 255},
+	"bronze": {205, 125, 60, 255},
+}
+
+var composedSprites sync.Map // manifest, sprite path and loadout -> image.Image
+
+// clearSpriteCaches drops the art built from the previous manifest. Keys
+// still carry the manifest so a render racing a reload can't file stale art
+// under the new one.
+func clearSpriteCaches() {
+	composedSprites.Clear()
+	variantSprites.Clear()
+	portraitFrames.Clear()
+}
+
+// cosmeticColor parses an aura or frame color: gold, silver, bronze, an
+// element name or hex
+func cosmeticColor(s string) (color.NRGBA, bool) {
+	s = strings.ToLower(strings.TrimSpace(s))
+	if c, ok := frameColors[s]; ok {
+		return c, true
+	}
+	if alias, ok := fxElementAliases[s]; ok {
+		s = alias
+	}
+	if p, ok := fxElements[s]; ok && s != "" {
+		return p.glow, true
+	}
+	if strings.HasPrefix(s, "#") && (len(s) == 7 || len(s) == 9) {
+		return utils.ParseHexColor(s), true
+	}
+	return color.NRGBA{}, false
+}
+
+// cosmetic looks up a manifest cosmetic for slot, case-insensitively
+func (m *Manifest) cosmetic(key, slot string) (*Cosmetic, bool) {
+	c, ok := m.Cosmetics[strings.ToUpper(strings.TrimSpace(key))]
+	if !ok || c.Slot != slot {
+		return nil, false
+	}
+	return c, true
+}
+
+// loadCharacter loads a player's sprite with their cosmetics composited on.
+// The HUD portrait, battlefield sprite and profile cards all start from it.
+func loadCharacter(class string, index int, cos Cosmetics, assetsPath string) (image.Image, error) {
+	path := GetCharacterSpritePath(class, index, assetsPath)
+	base, err := utils.LoadImage(path)
+	if err != nil || cos == (Cosmetics{}) {
+		return base, err
+	}
+
+	m := Sprites()
+	loadout, cacheable := m.loadoutKey(cos)
+	if !cacheable {
+		return composeCharacter(m, CharacterSprite(class, index), path, base, cos, assetsPath), nil
+	}
+	key := fmt.Sprintf("%p|%s|%s", m, path, loadout)
+	if img, ok := composedSprites.Load(key); ok {
+		return img.(image.Image), nil
+	}
+	img := composeCharacter(m, CharacterSprite(class, index), path, base, cos, assetsPath)
+	composedSprites.Store(key, img)
+	return img, nil
+}
+
+// loadoutKey names a loadout for the sprite cache. Only loadouts made of
+// manifest cosmetics and named colors are cacheable; hex colors are
+// composed every time so clients can't grow the cache without bound.
+func (m *Manifest) loadoutKey(cos Cosmetics) (string, bool) {
+	named := func(s string) bool {
+		s = strings.ToLower(strings.TrimSpace(s))
+		_, ok := cosmeticColor(s)
+		return s == "" || ok && !strings.HasPrefix(s, "#")
+	}
+	item := func(key, slot string) bool {
+		_, ok := m.cosmetic(key, slot)
+		return ok || strings.TrimSpace(key) == ""
+	}
+	if !item(cos.Weapon, "weapon") || !item(cos.Pet, "pet") || !named(cos.Aura) || !(named(cos.Frame) || item(cos.Frame, "frame")) {
+		return "", false
+	}
+	norm := func(s string) string { return strings.ToLower(strings.TrimSpace(s)) }
+	return strings.Join([]string{norm(cos.Weapon), norm(cos.Aura), norm(cos.Frame), norm(cos.Pet)}, "|"), true
+}
+
+// spriteLayer is one layer of a composed character
+type spriteLayer struct {
+	z    int
+	draw func(dc *gg.Context)
+}
+
+// composeCharacter draws the cosmetic layers and the base sprite in z order
+// on a canvas the size of the base, so the result is placed exactly like the
+// bare sprite. Cosmetics the manifest doesn't know are skipped.
+func composeCharacter(m *Manifest, meta SpriteMeta, path string, base image.Image, cos Cosmetics, assetsPath string) image.Image {
+	b := base.Bounds()
+	w, h := float64(b.Dx()), float64(b.Dy())
+	art := spriteArt(path, base)
+	artW := (art.right - art.left) * w
+
+	// Where a slot attaches and its draw order: the sprite's own anchor wins
+	// (the halo falls back to the face), then the cosmetic's z, then the
+	// slot's default
+	anchor := func(slot string, item *Cosmetic) (float64, float64, int) {
+		a, ok := meta.Anchors[slot]
+		if face, hasFace := meta.Anchors["face"]; !ok && hasFace && slot == "frame" {
+			a, ok = LayerAnchor{X: face.X, Y: face.Y}, true
+		}
+		if !ok {
+			a = defaultAnchor(slot, art, meta.Facing)
+		}
+		z := cosmeticSlots[slot]
+		if item != nil && item.Z != nil {
+			z = *item.Z
+		}
+		if a.Z != nil {
+			z = *a.Z
+		}
+		return a.X * w, a.Y * h, z
+	}
+
+	layers := []spriteLayer{{0, func(dc *gg.Context) { dc.DrawImage(base, -b.Min.X, -b.Min.Y) }}}
+	addImage := func(slot, key string) {
+		item, ok := m.cosmetic(key, slot)
+		if !ok {
+			return
+		}
+		img, err := utils.LoadImage(filepath.Join(assetsPath, "rpgasset", item.File))
+		if err != nil {
+			return
+		}
+		if o := utils.OpaqueBounds(img, 16); !o.Empty() {
+			img = imaging.Crop(img, o)
+		}
+
+		def := cosmeticDefaults[slot]
+		scale, pivot := def.scale, def.pivot
+		if item.Scale > 0 {
+			scale = item.Scale
+		}
+		if item.Pivot != nil {
+			pivot = *item.Pivot
+		}
+		lw := math.Max(1, artW*scale)
+		layer := imaging.Resize(img, int(lw), 0, imaging.Lanczos)
+		if (item.Facing == "left" && meta.Facing == "right") || (item.Facing == "right" && meta.Facing == "left") {
+			layer = imaging.FlipH(layer)
+			pivot.X = 1 - pivot.X
+		}
+
+		x, y, z := anchor(slot, item)
+		lh := float64(layer.Bounds().Dy())
+		layers = append(layers, spriteLayer{z, func(dc *gg.Context) {
+			dc.DrawImage(layer, int(x-pivot.X*lw), int(y-pivot.Y*lh))
+		}})
+	}
+
+	if c, ok := cosmeticColor(cos.Aura); ok {
+		_, _, z := anchor("aura", nil)
+		glow := auraGlow(base, c)
+		layers = append(layers, spriteLayer{z, func(dc *gg.Context) { dc.DrawImage(glow, 0, 0) }})
+	}
+	if _, ok := m.cosmetic(cos.Frame, "frame"); ok {
+		addImage("frame", cos.Frame)
+	} else if c, ok := cosmeticColor(cos.Frame); ok {
+		x, y, z := anchor("frame", nil)
+		layers = append(layers, spriteLayer{z, func(dc *gg.Context) { drawHalo(dc, x, y, (art.bottom-art.top)*h*0.145, c) }})
+	}
+	addImage("weapon", cos.Weapon)
+	addImage("pet", cos.Pet)
+
+	sort.SliceStable(layers, func(i, j int) bool { return layers[i].z < layers[j].z })
+	dc := gg.NewContext(b.Dx(), b.Dy())
+	for _, l := range layers {
+		l.draw(dc)
+	}
+	return dc.Image()
+}
+
+// defaultAnchor guesses a slot's anchor from where the art sits: the weapon
+// hand on the facing side, the head for the frame, the ground in front for a
+// pet and the middle for the aura
+func defaultAnchor(slot string, art artBox, facing string) LayerAnchor {
+	aw, ah := art.right-art.left, art.bottom-art.top
+	cx := (art.left + art.right) / 2
+	var a LayerAnchor
+	switch slot {
+	case "weapon":
+		a = LayerAnchor{X: cx + aw*0.3, Y: art.top + ah*0.55}
+	case "frame":
+		a = LayerAnchor{X: cx, Y: art.top + ah*0.09}
+	case "pet":
+		a = LayerAnchor{X: cx + aw*0.5, Y: art.bottom}
+	default:
+		a = LayerAnchor{X: cx, Y: (art.top + art.bottom) / 2}
+	}
+	if facing == "left" {
+		a.X = 2*cx - a.X
+	}
+	return a
+}
+
+// auraGlow is a soft silhouette of the sprite in c, drawn behind it
+func auraGlow(sprite image.Image, c color.NRGBA) image.Image {
+	b := sprite.Bounds()
+	// Blurring a quarter-size mask is cheap, and scaling it back up softens it
+	small := imaging.Resize(sprite, max(1, b.Dx()/4), 0, imaging.Box)
+	mask := image.NewNRGBA(small.Bounds())
+	for i := 0; i < len(mask.Pix); i += 4 {
+		mask.Pix[i], mask.Pix[i+1], mask.Pix[i+2] = c.R, c.G, c.B
+		mask.Pix[i+3] = small.Pix[i+3]
+	}
+	glow := imaging.Blur(mask, float64(small.Bounds().Dx())*0.03)
+	strength := float64(c.A) / 255 * 0.9
+	for i := 3; i < len(glow.Pix); i += 4 {
+		glow.Pix[i] = uint8(math.Min(255, float64(glow.Pix[i])*2.5*strength))
+	}
+	return imaging.Resize(glow, b.Dx(), b.Dy(), imaging.Linear)
+}
+
+// drawHalo is the built-in title frame: a ring behind the head, set with gems
+func drawHalo(dc *gg.Context, x, y, r float64, c color.NRGBA) {
+	dark := color.NRGBA{c.R / 3, c.G / 3, c.B / 3, 255}
+	light := color.NRGBA{uint8(255 - (255-int(c.R))/3), uint8(255 - (255-int(c.G))/3), uint8(255 - (255-int(c.B))/3), 255}
+
+	glow := gg.NewRadialGradient(x, y, r*0.5, x, y, r*1.35)
+	glow.AddColorStop(0, withAlpha(c, 0))
+	glow.AddColorStop(0.5, withAlpha(c, 90))
+	glow.AddColorStop(1, withAlpha(c, 0))
+	dc.SetFillStyle(glow)
+	dc.DrawCircle(x, y, r*1.35)
+	dc.Fill()
+
+	for _, ring := range []struct {
+		width float64
+		c     color.NRGBA
+	}{{0.2, dark}, {0.12, c}, {0.04, light}} {
+		dc.SetColor(ring.c)
+		dc.SetLineWidth(r * ring.width)
+		dc.DrawCircle(x, y, r)
+		dc.Stroke()
+	}
+
+	gem := r * 0.11
+	for i := 0; i < 8; i++ {
+		a := float64(i) * math.Pi / 4
+		gx, gy := x+math.Cos(a)*r, y+math.Sin(a)*r
+		dc.MoveTo(gx, gy-gem*1.4)
+		dc.LineTo(gx+gem, gy)
+		dc.LineTo(gx, gy+gem*1.4)
+		dc.LineTo(gx-gem, gy)
+		dc.ClosePath()
+		dc.SetColor(light)
+		dc.FillPreserve()
+		dc.SetColor(dark)
+		dc.SetLineWidth(math.Max(1, r*0.03))
+		dc.Stroke()
+	}
+}
@@ -0,0 +1,124 @@
+package combat
+
+import (
+	"image"
+	"image/color"
+	"image/png"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCosmeticColor(t *testing.T) {
+	tests := []struct {
+		s    string
+		want color.NRGBA
+		ok   bool
+	}{
+		{"gold", frameColors["gold"], true},
+		{" Silver ", frameColors["silver"], true},
+		{"fire", fxElements["fire"].glow, true},
+		{"shadow", fxElements["dark"].glow, true},
+		{"#ff8000", color.NRGBA{255, 128, 0, 255}, true},
+		{"#ff800080", color.NRGBA{255, 128, 0, 128}, true},
+		{"#f80", color.NRGBA{}, false},
+		{"physical", color.NRGBA{}, false},
+		{"plaid", color.NRGBA{}, false},
+		{"", color.NRGBA{}, false},
+	}
+	for _, tt := range tests {
+		got, ok := cosmeticColor(tt.s)
+		if ok != tt.ok || got != tt.want {
+			t.Errorf("cosmeticColor(%q) = %v, %v; want %v, %v", tt.s, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestLoadoutKey(t *testing.T) {
+	m := &Manifest{Cosmetics: map[string]*Cosmetic{
+		"SWORD":  {Slot: "weapon", File: "sword.png"},
+		"WOLF":   {Slot: "pet", File: "wolf.png"},
+		"LAUREL": {Slot: "frame", File: "laurel.png"},
+	}}
+	tests := []struct {
+		cos       Cosmetics
+		want      string
+		cacheable bool
+	}{
+		{Cosmetics{Weapon: "sword"}, "sword|||", true},
+		{Cosmetics{Weapon: " SWORD ", Aura: "Fire", Frame: "gold", Pet: "wolf"}, "sword|fire|gold|wolf", true},
+		{Cosmetics{Frame: "laurel"}, "||laurel|", true},
+		{Cosmetics{Aura: "shadow"}, "|shadow||", true},
+		{Cosmetics{Aura: "#ff8000"}, "", false},
+		{Cosmetics{Frame: "#ff8000"}, "", false},
+		{Cosmetics{Weapon: "axe"}, "", false},
+		{Cosmetics{Weapon: "wolf"}, "", false}, // A pet in the weapon slot
+		{Cosmetics{Pet: "sword"}, "", false},
+		{Cosmetics{Aura: "plaid"}, "", false},
+	}
+	for _, tt := range tests {
+		got, ok := m.loadoutKey(tt.cos)
+		if ok != tt.cacheable || got != tt.want {
+			t.Errorf("loadoutKey(%+v) = %q, %v; want %q, %v", tt.cos, got, ok, tt.want, tt.cacheable)
+		}
+	}
+}
+
+// writeSolidPNG writes a w x h PNG filled with c, making its folder
+func writeSolidPNG(t *testing.T, path string, w, h int, c color.NRGBA) {
+	t.Helper()
+	img := image.NewNRGBA(image.Rect(0, 0, w, h))
+	for i := 0; i < len(img.Pix); i += 4 {
+		img.Pix[i], img.Pix[i+1], img.Pix[i+2], img.Pix[i+3] = c.R, c.G, c.B, c.A
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if err := png.Encode(f, img); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestComposeOrder(t *testing.T) {
+	red, blue := color.NRGBA{255, 0, 0, 255}, color.NRGBA{0, 0, 255, 255}
+	assets := t.TempDir()
+	writeSolidPNG(t, filepath.Join(assets, "rpgasset", "sword.png"), 10, 10, blue)
+	base := image.NewNRGBA(image.Rect(0, 0, 40, 40))
+	for i := 0; i < len(base.Pix); i += 4 {
+		base.Pix[i], base.Pix[i+3] = 255, 255
+	}
+
+	z := func(v int) *int { return &v }
+	tests := []struct {
+		name    string
+		itemZ   *int
+		anchorZ *int
+		want    color.NRGBA
+	}{
+		{"slot default", nil, nil, blue},
+		{"cosmetic behind", z(-1), nil, red},
+		{"anchor behind", z(5), z(-1), red},
+		{"anchor in front", z(-1), z(2), blue},
+	}
+	for _, tt := range tests {
+		m := &Manifest{Cosmetics: map[string]*Cosmetic{
+			"SWORD": {Slot: "weapon", File: "sword.png", Scale: 0.5, Z: tt.itemZ},
+		}}
+		meta := SpriteMeta{Anchors: map[string]LayerAnchor{"weapon": {X: 0.5, Y: 0.5, Z: tt.anchorZ}}}
+		img := composeCharacter(m, meta, filepath.Join(assets, "hero.png"), base, Cosmetics{Weapon: "sword"}, assets)
+
+		// The 20 px sword hangs from (20, 20) by a point 80% down it
+		got := color.NRGBAModel.Convert(img.At(20, 14)).(color.NRGBA)
+		if got != tt.want {
+			t.Errorf("%s: %v over the sprite; want %v", tt.name, got, tt.want)
+		}
+		if c := color.NRGBAModel.Convert(img.At(2, 2)).(color.NRGBA); c != red {
+			t.Errorf("%s: %v away from the sword; want the sprite", tt.name, c)
+		}
+	}
+}
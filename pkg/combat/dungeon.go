@@ -0,0 +1,614 @@
+package combat
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"math"
+	"sort"
+	"strings"
+
+	"image-service/pkg/utils"
+
+	"github.com/disintegration/imaging"
+	"github.com/fogleman/gg"
+	"github.com/gin-gonic/gin"
+	"golang.org/x/image/font"
+)
+
+// DungeonMapRequest is one dungeon floor: its rooms, how they link and the
+// route the party has taken so far
+type DungeonMapRequest struct {
+	Name    string        `json:"name"` // Floor title, e.g. "Floor 3"
+	Rooms   []DungeonRoom `json:"rooms"`
+	Path    []string      `json:"path"`    // Room IDs in the order they were entered
+	Current string        `json:"current"` // Room the party is in; the end of path by default
+
+	Background string `json:"background"`
+	Biome      string `json:"biome"`
+	Seed       Seed   `json:"seed"`
+}
+
+// DungeonRoom is one node of the floor. Links are the rooms reachable from
+// it; the map reads left to right from rooms nothing links to.
+type DungeonRoom struct {
+	ID      string   `json:"id"`
+	Type    string   `json:"type"` // start, combat, elite, shop, rest, treasure, event, boss
+	Links   []string `json:"links"`
+	Visited bool     `json:"visited"`
+	Locked  bool     `json:"locked"`
+	Boss    Enemy    `json:"boss"` // Picks the boss room's thumbnail, like an enemy in a combat request
+}
+
+const maxDungeonRooms = 60
+
+// Map ink and parchment colors
+var (
+	inkColor       = color.RGBA{60, 38, 20, 255}
+	parchmentLight = color.RGBA{240, 222, 180, 255}
+	parchmentDark  = color.RGBA{150, 110, 65, 255}
+	pathColor      = color.RGBA{178, 34, 34, 255}
+)
+
+// Token fill per room type; unknown types use event's
+var roomColors = map[string]color.RGBA{
+	"start":    {200, 190, 160, 255},
+	"combat":   {205, 120, 95, 255},
+	"elite":    {170, 70, 150, 255},
+	"shop":     {230, 185, 70, 255},
+	"rest":     {110, 170, 100, 255},
+	"treasure": {215, 160, 60, 255},
+	"event":    {110, 150, 205, 255},
+	"boss":     {160, 30, 30, 255},
+}
+
+// Legend order
+var roomTypes = []string{"start", "combat", "elite", "shop", "rest", "treasure", "event", "boss"}
+
+func GenerateDungeonMap(c *gin.Context) {
+	var req DungeonMapRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	if err := req.validate(); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+
+	buf, err := utils.EncodeImageToBuffer(renderDungeonMap(&req, "assets"))
+	if err != nil {
+		c.JSON(500, gin.H{"error": "Failed to encode image"})
+		return
+	}
+
+	c.Data(200, "image/png", buf)
+}
+
+// validate checks the room graph (unique IDs, and links, path and current
+// that only name rooms on the floor) and the background
+func (req *DungeonMapRequest) validate() error {
+	if len(req.Rooms) == 0 {
+		return fmt.Errorf("rooms is required")
+	}
+	if len(req.Rooms) > maxDungeonRooms {
+		return fmt.Errorf("too many rooms: %d (max %d)", len(req.Rooms), maxDungeonRooms)
+	}
+	ids := make(map[string]bool, len(req.Rooms))
+	for _, r := range req.Rooms {
+		if r.ID == "" {
+			return fmt.Errorf("every room needs an id")
+		}
+		if ids[r.ID] {
+			return fmt.Errorf("duplicate room id %q", r.ID)
+		}
+		ids[r.ID] = true
+	}
+	for _, r := range req.Rooms {
+		for _, l := range r.Links {
+			if !ids[l] {
+				return fmt.Errorf("room %q links to unknown room %q", r.ID, l)
+			}
+		}
+	}
+	for _, id := range req.Path {
+		if !ids[id] {
+			return fmt.Errorf("path names unknown room %q", id)
+		}
+	}
+	if req.Current != "" && !ids[req.Current] {
+		return fmt.Errorf("current names unknown room %q", req.Current)
+	}
+	return checkBackground(req.Background)
+}
+
+// mapNode is a room placed on the map
+type mapNode struct {
+	room  *DungeonRoom
+	depth int
+	x, y  float64
+	r     float64
+}
+
+// layoutDungeon places rooms in columns by their distance from the entrance
+// rooms, ordering each column by where the rooms that lead into it sit so
+// links cross as little as possible
+func layoutDungeon(rooms []DungeonRoom, area image.Rectangle) map[string]*mapNode {
+	nodes := make(map[string]*mapNode, len(rooms))
+	incoming := make(map[string][]string)
+	for i := range rooms {
+		nodes[rooms[i].ID] = &mapNode{room: &rooms[i], depth: -1}
+		for _, l := range rooms[i].Links {
+			incoming[l] = append(incoming[l], rooms[i].ID)
+		}
+	}
+
+	// Breadth-first from the entrances, so loops back to earlier rooms don't
+	// push anything further right. Rooms left over (inside a loop nothing
+	// enters) start a new search in request order.
+	var queue []string
+	visit := func(id string, depth int) {
+		if n := nodes[id]; n.depth < 0 {
+			n.depth = depth
+			queue = append(queue, id)
+		}
+	}
+	for _, r := range rooms {
+		if len(incoming[r.ID]) == 0 {
+			visit(r.ID, 0)
+		}
+	}
+	for next := 0; next < len(rooms); next++ {
+		if len(queue) == 0 {
+			visit(rooms[next].ID, 0)
+		}
+		for len(queue) > 0 {
+			n := nodes[queue[0]]
+			queue = queue[1:]
+			for _, l := range n.room.Links {
+				visit(l, n.depth+1)
+			}
+		}
+	}
+
+	columns := 0
+	for _, n := range nodes {
+		columns = max(columns, n.depth+1)
+	}
+	cols := make([][]*mapNode, columns)
+	for i := range rooms {
+		n := nodes[rooms[i].ID]
+		cols[n.depth] = append(cols[n.depth], n)
+	}
+
+	colW := float64(area.Dx()) / float64(columns)
+	for c, col := range cols {
+		if c > 0 {
+			order := make(map[*mapNode]float64, len(col))
+			for i, n := range col {
+				sum, count := 0.0, 0
+				for _, from := range incoming[n.room.ID] {
+					if p := nodes[from]; p.depth < c {
+						sum += p.y
+						count++
+					}
+				}
+				order[n] = float64(area.Min.Y+area.Max.Y)/2 + float64(i)*1e-3
+				if count > 0 {
+					order[n] = sum/float64(count) + float64(i)*1e-3
+				}
+			}
+			sort.SliceStable(col, func(i, j int) bool { return order[col[i]] < order[col[j]] })
+		}
+
+		gap := math.Min(120, float64(area.Dy())/float64(len(col)))
+		top := float64(area.Min.Y+area.Max.Y)/2 - gap*float64(len(col)-1)/2
+		for i, n := range col {
+			n.x = float64(area.Min.X) + colW*(float64(c)+0.5)
+			n.y = top + gap*float64(i)
+			n.r = math.Min(28, math.Min(colW, gap)*0.3)
+			if roomType(n.room) == "boss" {
+				n.r = math.Min(48, math.Min(colW, gap)*0.45)
+			}
+		}
+	}
+	return nodes
+}
+
+// roomType normalises a room's type
+func roomType(r *DungeonRoom) string {
+	return strings.ToLower(strings.TrimSpace(r.Type))
+}
+
+// renderDungeonMap draws the floor as an inked map on parchment: links as
+// dashed trails, the route taken in red, and a token per room
+func renderDungeonMap(req *DungeonMapRequest, assetsPath string) image.Image {
+	dc := gg.NewContext(CANVAS_W, CANVAS_H)
+	setFont := cardFont(dc)
+	drawParchment(dc, &CombatRequest{Background: req.Background, Biome: req.Biome, Seed: req.Seed}, assetsPath)
+
+	title := req.Name
+	if title == "" {
+		title = "Dungeon"
+	}
+	if face := setFont(56); face != nil {
+		dc.SetColor(inkColor)
+		drawInkText(dc, face, truncateText(dc, strings.ToUpper(title), CANVAS_W-120), CANVAS_W/2, 52, 0.5, 0.5)
+	}
+
+	nodes := layoutDungeon(req.Rooms, image.Rect(60, 100, CANVAS_W-60, CANVAS_H-80))
+
+	current := req.Current
+	if current == "" && len(req.Path) > 0 {
+		current = req.Path[len(req.Path)-1]
+	}
+	visited := make(map[string]bool)
+	taken := make(map[[2]string]bool)
+	for i, id := range req.Path {
+		visited[id] = true
+		if i > 0 {
+			taken[[2]string{req.Path[i-1], id}] = true
+			taken[[2]string{id, req.Path[i-1]}] = true
+		}
+	}
+
+	// Trails first, the route over them
+	for _, r := range req.Rooms {
+		for _, l := range r.Links {
+			if !taken[[2]string{r.ID, l}] {
+				drawTrail(dc, nodes[r.ID], nodes[l], false)
+			}
+		}
+	}
+	for i := 1; i < len(req.Path); i++ {
+		drawTrail(dc, nodes[req.Path[i-1]], nodes[req.Path[i]], true)
+	}
+
+	for _, r := range req.Rooms {
+		n := nodes[r.ID]
+		drawRoomToken(dc, n, r.ID == current, r.Visited || visited[r.ID], assetsPath)
+	}
+
+	drawMapLegend(dc, req.Rooms, setFont)
+	return dc.Image()
+}
+
+// drawParchment fills the map with the arena art washed into old paper: sepia
+// toned, faded and darkened toward burnt edges
+func drawParchment(dc *gg.Context, req *CombatRequest, assetsPath string) {
+	bgPath := selectBackground(req, assetsPath)
+	bg, err := utils.LoadImage(bgPath)
+	if bgPath == "" || err != nil {
+		dc.SetColor(parchmentLight)
+		dc.Clear()
+	} else {
+		gray := imaging.Grayscale(imaging.Fill(bg, CANVAS_W, CANVAS_H, imaging.Center, imaging.Lanczos))
+		gray = imaging.Blur(gray, 1.5)
+		paper := image.NewNRGBA(gray.Bounds())
+		for i := 0; i < len(paper.Pix); i += 4 {
+			// Squash the art into the paper's light half so ink reads over it
+			t := 0.55 + 0.45*float64(gray.Pix[i])/255
+			paper.Pix[i] = lerpByte(parchmentDark.R, parchmentLight.R, t)
+			paper.Pix[i+1] = lerpByte(parchmentDark.G, parchmentLight.G, t)
+			paper.Pix[i+2] = lerpByte(parchmentDark.B, parchmentLight.B, t)
+			paper.Pix[i+3] = 255
+		}
+		dc.DrawImage(paper, 0, 0)
+	}
+
+	cx, cy := float64(CANVAS_W)/2, float64(CANVAS_H)/2
+	edge := gg.NewRadialGradient(cx, cy, float64(CANVAS_H)*0.45, cx, cy, float64(CANVAS_W)*0.62)
+	edge.AddColorStop(0, color.NRGBA{90, 50, 15, 0})
+	edge.AddColorStop(1, color.NRGBA{70, 35, 10, 200})
+	dc.SetFillStyle(edge)
+	dc.DrawRectangle(0, 0, CANVAS_W, CANVAS_H)
+	dc.Fill()
+
+	dc.SetColor(color.NRGBA{inkColor.R, inkColor.G, inkColor.B, 160})
+	dc.SetLineWidth(3)
+	dc.DrawRectangle(14, 14, CANVAS_W-28, CANVAS_H-28)
+	dc.Stroke()
+	dc.SetLineWidth(1)
+	dc.DrawRectangle(20, 20, CANVAS_W-40, CANVAS_H-40)
+	dc.Stroke()
+}
+
+func lerpByte(a, b uint8, t float64) uint8 {
+	return uint8(float64(a) + (float64(b)-float64(a))*t)
+}
+
+// drawInkText draws ink-anchored text in the current color without a shadow,
+// the way ink sits on paper
+func drawInkText(dc *gg.Context, face font.Face, text string, x, y, ax, ay float64) {
+	ox, oy := inkOrigin(face, text, x, y, ax, ay)
+	dc.DrawString(text, ox, oy)
+}
+
+// drawTrail links two rooms edge to edge: a dashed ink line, or a solid red
+// one for the route taken. Links that lead back left, or within a column,
+// bow upward so they don't run over the forward trails.
+func drawTrail(dc *gg.Context, a, b *mapNode, taken bool) {
+	dx, dy := b.x-a.x, b.y-a.y
+	d := math.Hypot(dx, dy)
+	if d <= a.r+b.r {
+		return
+	}
+	// Control point: the midpoint, pushed off the line for backward links
+	mx, my := (a.x+b.x)/2, (a.y+b.y)/2
+	if b.x <= a.x {
+		px, py := dy/d, -dx/d
+		if py > 0 {
+			px, py = -px, -py
+		}
+		mx, my = mx+px*d*0.35, my+py*d*0.35
+	}
+	from := func(n *mapNode) (float64, float64) {
+		vx, vy := mx-n.x, my-n.y
+		if l := math.Hypot(vx, vy); l > 0 {
+			return n.x + vx/l*(n.r+4), n.y + vy/l*(n.r+4)
+		}
+		return n.x, n.y
+	}
+	x0, y0 := from(a)
+	x1, y1 := from(b)
+
+	if taken {
+		dc.SetColor(pathColor)
+		dc.SetLineWidth(5)
+		dc.SetLineCapRound()
+	} else {
+		dc.SetColor(color.NRGBA{inkColor.R, inkColor.G, inkColor.B, 170})
+		dc.SetLineWidth(2.5)
+		dc.SetDash(8, 7)
+	}
+	dc.MoveTo(x0, y0)
+	dc.QuadraticTo(mx, my, x1, y1)
+	dc.Stroke()
+	dc.SetDash()
+}
+
+// drawRoomToken draws a room's round token and icon. The current room gets a
+// gold ring, visited rooms a check, and locked rooms are greyed with a lock.
+func drawRoomToken(dc *gg.Context, n *mapNode, current, visited bool, assetsPath string) {
+	typ := roomType(n.room)
+	fill, ok := roomColors[typ]
+	if !ok {
+		fill = roomColors["event"]
+	}
+	locked := n.room.Locked && !current && !visited
+	if locked {
+		fill = color.RGBA{140, 130, 115, 255}
+	}
+
+	if current {
+		glow := gg.NewRadialGradient(n.x, n.y, n.r, n.x, n.y, n.r*1.8)
+		glow.AddColorStop(0, color.NRGBA{victoryColor.R, victoryColor.G, victoryColor.B, 200})
+		glow.AddColorStop(1, color.NRGBA{victoryColor.R, victoryColor.G, victoryColor.B, 0})
+		dc.SetFillStyle(glow)
+		dc.DrawCircle(n.x, n.y, n.r*1.8)
+		dc.Fill()
+	}
+
+	dc.DrawCircle(n.x, n.y, n.r)
+	dc.SetColor(fill)
+	dc.FillPreserve()
+	dc.SetColor(inkColor)
+	dc.SetLineWidth(3)
+	dc.Stroke()
+
+	if typ == "boss" && !locked {
+		drawBossThumb(dc, n, assetsPath)
+	} else {
+		drawRoomIcon(dc, typ, n.x, n.y, n.r*0.62)
+	}
+
+	switch {
+	case current:
+		dc.DrawCircle(n.x, n.y, n.r+5)
+		dc.SetColor(victoryColor)
+		dc.SetLineWidth(4)
+		dc.Stroke()
+	case locked:
+		dc.DrawCircle(n.x, n.y, n.r)
+		dc.SetColor(color.NRGBA{60, 50, 40, 110})
+		dc.Fill()
+		drawLock(dc, n.x+n.r*0.7, n.y+n.r*0.6, n.r*0.55)
+	case visited:
+		drawCheck(dc, n.x+n.r*0.7, n.y+n.r*0.7, n.r*0.38)
+	}
+}
+
+// drawBossThumb fills the boss room's token with its boss sprite
+func drawBossThumb(dc *gg.Context, n *mapNode, assetsPath string) {
+	boss := n.room.Boss
+	boss.IsBoss = true
+	sprite, err := loadEnemy(boss, SelectEnemySprite(boss, 0, boss.Level, assetsPath))
+	if err != nil {
+		drawRoomIcon(dc, "boss", n.x, n.y, n.r*0.62)
+		return
+	}
+	if b := utils.OpaqueBounds(sprite, 16); !b.Empty() {
+		sprite = imaging.Crop(sprite, b)
+	}
+	size := int(n.r * 1.8)
+	thumb := imaging.Fit(sprite, size, size, imaging.Lanczos)
+	tb := thumb.Bounds()
+
+	dc.Push()
+	dc.DrawCircle(n.x, n.y, n.r-2)
+	dc.Clip()
+	dc.DrawCircle(n.x, n.y, n.r)
+	dc.SetColor(color.RGBA{50, 20, 20, 255})
+	dc.Fill()
+	utils.Blit(dc, thumb, int(n.x)-tb.Dx()/2, int(n.y+n.r*0.9)-tb.Dy())
+	dc.ResetClip()
+	dc.Pop()
+
+	dc.DrawCircle(n.x, n.y, n.r)
+	dc.SetColor(inkColor)
+	dc.SetLineWidth(3)
+	dc.Stroke()
+}
+
+// drawRoomIcon draws a room type's ink icon in a box of radius r
+func drawRoomIcon(dc *gg.Context, typ string, cx, cy, r float64) {
+	dc.SetColor(inkColor)
+	dc.SetLineCapRound()
+	switch typ {
+	case "start":
+		dc.SetLineWidth(r * 0.16)
+		dc.DrawLine(cx-r*0.5, cy+r, cx-r*0.5, cy-r)
+		dc.Stroke()
+		dc.MoveTo(cx-r*0.5, cy-r)
+		dc.LineTo(cx+r*0.8, cy-r*0.6)
+		dc.LineTo(cx-r*0.5, cy-r*0.15)
+		dc.Fill()
+	case "combat", "elite":
+		dc.SetLineWidth(r * 0.18)
+		for _, s := range []float64{-1, 1} {
+			dc.DrawLine(cx-s*r*0.8, cy-r*0.8, cx+s*r*0.6, cy+r*0.6)
+			dc.Stroke()
+			dc.DrawLine(cx+s*r*0.2, cy+r*0.75, cx+s*r*0.75, cy+r*0.2)
+			dc.Stroke()
+		}
+		if typ == "elite" {
+			drawStar(dc, cx, cy-r*0.95, r*0.35, inkColor)
+		}
+	case "shop":
+		dc.DrawEllipse(cx, cy+r*0.25, r*0.75, r*0.7)
+		dc.Fill()
+		dc.MoveTo(cx-r*0.35, cy-r*0.4)
+		dc.LineTo(cx-r*0.55, cy-r*0.85)
+		dc.LineTo(cx+r*0.55, cy-r*0.85)
+		dc.LineTo(cx+r*0.35, cy-r*0.4)
+		dc.Fill()
+		drawCoin(dc, cx, cy+r*0.25, r*0.35)
+	case "rest":
+		dc.SetLineWidth(r * 0.18)
+		dc.DrawLine(cx-r*0.8, cy+r*0.9, cx+r*0.8, cy+r*0.55)
+		dc.DrawLine(cx-r*0.8, cy+r*0.55, cx+r*0.8, cy+r*0.9)
+		dc.Stroke()
+		dc.MoveTo(cx, cy-r)
+		dc.QuadraticTo(cx+r*0.75, cy, cx+r*0.35, cy+r*0.55)
+		dc.LineTo(cx-r*0.35, cy+r*0.55)
+		dc.QuadraticTo(cx-r*0.75, cy, cx, cy-r)
+		dc.SetColor(color.RGBA{230, 110, 30, 255})
+		dc.FillPreserve()
+		dc.SetColor(inkColor)
+		dc.SetLineWidth(2)
+		dc.Stroke()
+	case "treasure":
+		dc.DrawRectangle(cx-r*0.85, cy-r*0.2, r*1.7, r)
+		dc.SetColor(color.RGBA{120, 75, 35, 255})
+		dc.FillPreserve()
+		dc.SetColor(inkColor)
+		dc.SetLineWidth(2)
+		dc.Stroke()
+		dc.DrawArc(cx, cy-r*0.2, r*0.85, math.Pi, 2*math.Pi)
+		dc.ClosePath()
+		dc.SetColor(color.RGBA{150, 95, 45, 255})
+		dc.FillPreserve()
+		dc.SetColor(inkColor)
+		dc.Stroke()
+		dc.DrawRectangle(cx-r*0.15, cy-r*0.35, r*0.3, r*0.35)
+		dc.SetColor(victoryColor)
+		dc.Fill()
+	case "boss":
+		// Skull
+		dc.DrawCircle(cx, cy-r*0.2, r*0.75)
+		dc.Fill()
+		dc.DrawRectangle(cx-r*0.45, cy+r*0.3, r*0.9, r*0.55)
+		dc.Fill()
+		dc.SetColor(parchmentLight)
+		dc.DrawCircle(cx-r*0.3, cy-r*0.15, r*0.2)
+		dc.DrawCircle(cx+r*0.3, cy-r*0.15, r*0.2)
+		dc.Fill()
+	default:
+		if face, err := utils.LoadFont(utils.GetAssetPath("rpgasset", "ui", "fantesy.ttf"), r*2); err == nil {
+			dc.SetFontFace(face)
+			drawInkText(dc, face, "?", cx, cy, 0.5, 0.5)
+		}
+	}
+}
+
+// drawLock draws a small padlock badge centred on (cx, cy)
+func drawLock(dc *gg.Context, cx, cy, r float64) {
+	dc.DrawArc(cx, cy-r*0.2, r*0.5, math.Pi, 2*math.Pi)
+	dc.SetColor(inkColor)
+	dc.SetLineWidth(r * 0.3)
+	dc.Stroke()
+	dc.DrawRoundedRectangle(cx-r*0.75, cy-r*0.2, r*1.5, r*1.1, r*0.2)
+	dc.SetColor(color.RGBA{215, 170, 60, 255})
+	dc.FillPreserve()
+	dc.SetColor(inkColor)
+	dc.SetLineWidth(2)
+	dc.Stroke()
+	dc.DrawCircle(cx, cy+r*0.25, r*0.18)
+	dc.Fill()
+}
+
+// drawCheck draws a visited mark: a check on a small green disc
+func drawCheck(dc *gg.Context, cx, cy, r float64) {
+	dc.DrawCircle(cx, cy, r)
+	dc.SetColor(color.RGBA{70, 140, 60, 255})
+	dc.FillPreserve()
+	dc.SetColor(inkColor)
+	dc.SetLineWidth(2)
+	dc.Stroke()
+	dc.MoveTo(cx-r*0.5, cy)
+	dc.LineTo(cx-r*0.1, cy+r*0.45)
+	dc.LineTo(cx+r*0.55, cy-r*0.4)
+	dc.SetColor(color.White)
+	dc.SetLineWidth(r * 0.3)
+	dc.SetLineCapRound()
+	dc.Stroke()
+}
+
+// drawMapLegend lists the room types on the floor along the bottom edge
+func drawMapLegend(dc *gg.Context, rooms []DungeonRoom, setFont func(float64) font.Face) {
+	present := make(map[string]bool)
+	for i := range rooms {
+		present[roomType(&rooms[i])] = true
+	}
+	var shown []string
+	for _, t := range roomTypes {
+		if present[t] {
+			shown = append(shown, t)
+		}
+	}
+	face := setFont(18)
+	if len(shown) == 0 || face == nil {
+		return
+	}
+
+	const r, pad = 11.0, 26.0
+	widths := make([]float64, len(shown))
+	total := 0.0
+	for i, t := range shown {
+		w, _ := dc.MeasureString(roomLabel(t))
+		widths[i] = r*2 + 8 + w
+		total += widths[i]
+	}
+	total += pad * float64(len(shown)-1)
+
+	x, y := (CANVAS_W-total)/2, float64(CANVAS_H)-46
+	for i, t := range shown {
+		dc.DrawCircle(x+r, y, r)
+		dc.SetColor(roomColors[t])
+		dc.FillPreserve()
+		dc.SetColor(inkColor)
+		dc.SetLineWidth(2)
+		dc.Stroke()
+		drawRoomIcon(dc, t, x+r, y, r*0.6)
+
+		dc.SetFontFace(face)
+		dc.SetColor(inkColor)
+		drawInkText(dc, face, roomLabel(t), x+r*2+8, y, 0, 0.5)
+		x += widths[i] + pad
+	}
+}
+
+// roomLabel is a room type as the legend names it
+func roomLabel(typ string) string {
+	return strings.ToUpper(typ[:1]) + typ[1:]
+}
@@ -0,0 +1,90 @@
+package combat
+
+import (
+	"fmt"
+	"image"
+	"strings"
+	"testing"
+)
+
+// rooms builds a floor from "id>link,link" specs
+func rooms(specs ...string) []DungeonRoom {
+	var rs []DungeonRoom
+	for _, s := range specs {
+		id, links, _ := strings.Cut(s, ">")
+		r := DungeonRoom{ID: id, Type: "combat"}
+		if links != "" {
+			r.Links = strings.Split(links, ",")
+		}
+		rs = append(rs, r)
+	}
+	return rs
+}
+
+func TestDungeonValidate(t *testing.T) {
+	many := make([]string, maxDungeonRooms+1)
+	for i := range many {
+		many[i] = fmt.Sprintf("r%d", i)
+	}
+	tests := []struct {
+		name string
+		req  DungeonMapRequest
+		err  string // "" for a valid request
+	}{
+		{"valid", DungeonMapRequest{Rooms: rooms("a>b", "b>c", "c"), Path: []string{"a", "b"}, Current: "b"}, ""},
+		{"loop", DungeonMapRequest{Rooms: rooms("a>b", "b>a")}, ""},
+		{"background", DungeonMapRequest{Rooms: rooms("a"), Background: "env1.png"}, ""},
+		{"no rooms", DungeonMapRequest{}, "rooms is required"},
+		{"too many", DungeonMapRequest{Rooms: rooms(many...)}, "too many rooms"},
+		{"no id", DungeonMapRequest{Rooms: rooms("a", "")}, "needs an id"},
+		{"duplicate", DungeonMapRequest{Rooms: rooms("a>b", "b", "a")}, `duplicate room id "a"`},
+		{"bad link", DungeonMapRequest{Rooms: rooms("a>b", "b>z")}, `"b" links to unknown room "z"`},
+		{"bad path", DungeonMapRequest{Rooms: rooms("a>b", "b"), Path: []string{"a", "q"}}, `path names unknown room "q"`},
+		{"bad current", DungeonMapRequest{Rooms: rooms("a"), Current: "q"}, `current names unknown room "q"`},
+		{"background path", DungeonMapRequest{Rooms: rooms("a"), Background: "../environment/env1.png"}, "must be a filename"},
+	}
+	for _, tt := range tests {
+		err := tt.req.validate()
+		switch {
+		case tt.err == "" && err != nil:
+			t.Errorf("%s: %v; want valid", tt.name, err)
+		case tt.err != "" && (err == nil || !strings.Contains(err.Error(), tt.err)):
+			t.Errorf("%s: %v; want %q", tt.name, err, tt.err)
+		}
+	}
+}
+
+func TestLayoutDungeon(t *testing.T) {
+	area := image.Rect(100, 50, 900, 650)
+	tests := []struct {
+		name  string
+		rooms []DungeonRoom
+		want  string // Column of each room, in request order
+	}{
+		{"chain", rooms("a>b", "b>c", "c"), "a0 b1 c2"},
+		{"fork and join", rooms("a>b,c", "b>d", "c>d", "d"), "a0 b1 c1 d2"},
+		{"loop back to the middle", rooms("s>a", "a>b", "b>c", "c>a"), "s0 a1 b2 c3"},
+		{"self link", rooms("a>a,b", "b>b"), "a0 b1"},
+		{"cycle with no entrance", rooms("a>b", "b>c", "c>a"), "a0 b1 c2"},
+		{"entrance into a cycle", rooms("x>y", "y>z", "z>y", "s>x"), "x1 y2 z3 s0"},
+		{"separate floors", rooms("a>b", "b", "c>d", "d>c"), "a0 b1 c0 d1"},
+		{"alone", rooms("a"), "a0"},
+	}
+	for _, tt := range tests {
+		nodes := layoutDungeon(tt.rooms, area)
+		var got []string
+		for _, r := range tt.rooms {
+			n := nodes[r.ID]
+			got = append(got, fmt.Sprintf("%s%d", r.ID, n.depth))
+			if !image.Pt(int(n.x), int(n.y)).In(area) {
+				t.Errorf("%s: room %s at (%v, %v) outside %v", tt.name, r.ID, n.x, n.y, area)
+			}
+			if n.r <= 0 {
+				t.Errorf("%s: room %s has radius %v", tt.name, r.ID, n.r)
+			}
+		}
+		if s := strings.Join(got, " "); s != tt.want {
+			t.Errorf("%s: %q; want %q", tt.name, s, tt.want)
+		}
+	}
+}
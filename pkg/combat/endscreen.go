@@ -0,0 +1,463 @@
+package combat
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"math"
+	"strings"
+
+	"image-service/pkg/utils"
+
+	"github.com/disintegration/imaging"
+	"github.com/fogleman/gg"
+	"github.com/gin-gonic/gin"
+	"golang.org/x/image/font"
+)
+
+// EndScreenRequest is a battle result. A request with only text keeps the
+// original plain card; any other field draws the results card.
+type EndScreenRequest struct {
+	Text       string            `json:"text"`
+	Result     string            `json:"result"` // "victory" or "defeat"
+	Background string            `json:"background"`
+	Biome      string            `json:"biome"`
+	Seed       Seed              `json:"seed"`
+	Party      []EndScreenMember `json:"party"`
+	Loot       []LootItem        `json:"loot"`
+	Gold       int               `json:"gold"`
+	Turns      int               `json:"turns"`
+	MVP        string            `json:"mvp"` // Party member name
+}
+
+// EndScreenMember is one party member's share of the result
+type EndScreenMember struct {
+	Name        string `json:"name"`
+	Class       string `json:"class"`
+	SpriteIndex int    `json:"spriteIndex"`
+	Level       int    `json:"level"`
+	XPGained    int    `json:"xpGained"`
+	XP          int    `json:"xp"`       // Progress into the current level, after the battle
+	XPToNext    int    `json:"xpToNext"` // 0 hides the XP bar
+	LeveledUp   bool   `json:"leveledUp"`
+	KnockedOut  bool   `json:"knockedOut"`
+
+	Cosmetics Cosmetics `json:"cosmetics"`
+}
+
+// LootItem is one drop in the loot grid
+type LootItem struct {
+	Name     string `json:"name"`
+	Rarity   string `json:"rarity"` // common, uncommon, rare, epic, legendary, mythic
+	Quantity int    `json:"quantity"`
+}
+
+var rarityColors = map[string]color.RGBA{
+	"common":    {176, 176, 176, 255},
+	"uncommon":  {76, 175, 80, 255},
+	"rare":      {61, 139, 255, 255},
+	"epic":      {168, 85, 247, 255},
+	"legendary": {245, 158, 11, 255},
+	"mythic":    {239, 68, 68, 255},
+}
+
+// rarityColor returns the border color for a rarity, common when unknown
+func rarityColor(rarity string) color.RGBA {
+	if c, ok := rarityColors[strings.ToLower(strings.TrimSpace(rarity))]; ok {
+		return c
+	}
+	return rarityColors["common"]
+}
+
+var (
+	victoryColor = color.RGBA{255, 210, 80, 255}
+	defeatColor  = color.RGBA{230, 60, 60, 255}
+	xpColor      = color.RGBA{120, 220, 255, 255}
+	panelFill    = color.NRGBA{10, 12, 24, 190}
+)
+
+const (
+	lootCols = 3
+	lootRows = 2
+)
+
+func GenerateEndScreen(c *gin.Context) {
+	var req EndScreenRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	if err := checkBackground(req.Background); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+
+	var img image.Image
+	if req.plain() {
+		img = renderTextEndScreen(req.Text)
+	} else {
+		img = renderResultsCard(&req, "assets")
+	}
+
+	buf, err := utils.EncodeImageToBuffer(img)
+	if err != nil {
+		c.JSON(500, gin.H{"error": "Failed to encode image"})
+		return
+	}
+
+	c.Data(200, "image/png", buf)
+}
+
+// plain reports whether the request sets nothing but text
+func (req *EndScreenRequest) plain() bool {
+	return req.Result == "" && len(req.Party) == 0 && len(req.Loot) == 0 && req.Gold == 0 && req.Turns == 0 &&
+		req.MVP == "" && req.Background == "" && req.Biome == "" && req.Seed == ""
+}
+
+// renderTextEndScreen is the original end screen: one line of text on white
+func renderTextEndScreen(text string) image.Image {
+	dc := gg.NewContext(CANVAS_W, CANVAS_H)
+	dc.SetRGB(1, 1, 1) // Pure White
+	dc.Clear()
+
+	fontPath := utils.GetAssetPath("rpgasset", "ui", "fantesy.ttf")
+	face, err := utils.LoadFont(fontPath, 120) // 80pt approx 106px, let's go big
+	if err == nil {
+		dc.SetFontFace(face)
+		dc.SetColor(color.Black)
+		dc.DrawStringAnchored(text, CANVAS_W/2, CANVAS_H/2, 0.5, 0.5)
+	}
+	return dc.Image()
+}
+
+// renderResultsCard draws the victory / defeat card: blurred battle
+// background, the party with XP and level-ups, the loot grid and a stats
+// panel with gold, turns and the MVP
+func renderResultsCard(req *EndScreenRequest, assetsPath string) image.Image {
+	dc := gg.NewContext(CANVAS_W, CANVAS_H)
+	victory := !strings.EqualFold(strings.TrimSpace(req.Result), "defeat")
+	accent := victoryColor
+	if !victory {
+		accent = defeatColor
+	}
+	setFont := cardFont(dc)
+
+	drawBlurredArena(dc, &CombatRequest{Background: req.Background, Biome: req.Biome, Seed: req.Seed}, assetsPath)
+	if !victory {
+		dc.SetColor(color.NRGBA{70, 0, 0, 70})
+		dc.DrawRectangle(0, 0, CANVAS_W, CANVAS_H)
+		dc.Fill()
+	}
+
+	// Title, with the plain text as a subtitle
+	title := "VICTORY"
+	if !victory {
+		title = "DEFEAT"
+	}
+	if face := setFont(84); face != nil {
+		drawInk(dc, face, title, CANVAS_W/2, 50, 0.5, 0.5, accent)
+	}
+	if face := setFont(26); req.Text != "" && face != nil {
+		drawInk(dc, face, truncateText(dc, req.Text, CANVAS_W-80), CANVAS_W/2, 108, 0.5, 0.5, color.White)
+	}
+
+	drawEndScreenParty(dc, req, assetsPath, setFont, accent)
+	drawLootGrid(dc, req.Loot, setFont, image.Rect(24, 448, 648, 672))
+	drawResultStats(dc, req, setFont, accent, image.Rect(664, 448, 1000, 672))
+
+	return dc.Image()
+}
+
+// drawBlurredArena fills the card with the battle arena, blurred and darkened
+func drawBlurredArena(dc *gg.Context, req *CombatRequest, assetsPath string) {
+	bgPath := selectBackground(req, assetsPath)
+	if bg, err := utils.LoadImage(bgPath); bgPath != "" && err == nil {
+		bg = imaging.Fill(bg, CANVAS_W, CANVAS_H, imaging.Center, imaging.Lanczos)
+		utils.Blit(dc, imaging.Blur(bg, 6), 0, 0)
+	} else {
+		dc.SetHexColor("#1a1a1a")
+		dc.Clear()
+	}
+	dc.SetColor(color.RGBA{0, 0, 0, 140})
+	dc.DrawRectangle(0, 0, CANVAS_W, CANVAS_H)
+	dc.Fill()
+}
+
+// drawEndScreenParty draws each member's sprite in a row with name, level,
+// XP gained and an XP bar. The MVP gets a glow and badge; knocked-out members
+// are greyed.
+func drawEndScreenParty(dc *gg.Context, req *EndScreenRequest, assetsPath string, setFont func(float64) font.Face, accent color.RGBA) {
+	n := len(req.Party)
+	if n == 0 {
+		return
+	}
+	area := image.Rect(24, 132, CANVAS_W-24, 440)
+	colW := math.Min(220, float64(area.Dx())/float64(n))
+	left := float64(area.Min.X) + (float64(area.Dx())-colW*float64(n))/2
+	feet := float64(area.Max.Y) - 92
+	spriteH := math.Min(feet-float64(area.Min.Y)-10, colW*1.5)
+
+	for i, m := range req.Party {
+		cx := left + colW*(float64(i)+0.5)
+		mvp := req.MVP != "" && strings.EqualFold(req.MVP, m.Name)
+
+		if mvp {
+			cy := feet - spriteH/2
+			grad := gg.NewRadialGradient(cx, cy, 0, cx, cy, spriteH*0.6)
+			grad.AddColorStop(0, color.NRGBA{accent.R, accent.G, accent.B, 110})
+			grad.AddColorStop(1, color.NRGBA{accent.R, accent.G, accent.B, 0})
+			dc.SetFillStyle(grad)
+			dc.DrawEllipse(cx, cy, math.Min(colW*0.5, spriteH*0.6), spriteH*0.6)
+			dc.Fill()
+		}
+
+		if sprite, err := loadCharacter(m.Class, m.SpriteIndex, m.Cosmetics, assetsPath); err == nil {
+			if b := utils.OpaqueBounds(sprite, 16); !b.Empty() {
+				sprite = imaging.Crop(sprite, b)
+			}
+			img := imaging.Fit(sprite, int(colW*0.8), int(spriteH), imaging.Lanczos)
+			if m.KnockedOut {
+				img = imaging.AdjustBrightness(imaging.Grayscale(img), -35)
+			}
+			utils.DrawShadow(dc, cx, feet-4, float64(img.Bounds().Dx())*0.45, 0.6)
+			utils.Blit(dc, img, int(cx)-img.Bounds().Dx()/2, int(feet)-img.Bounds().Dy())
+		}
+
+		badgeY := float64(area.Min.Y) + 4
+		if face := setFont(20); mvp && face != nil {
+			drawBadge(dc, face, "MVP", cx, badgeY, accent)
+			badgeY += 30
+		}
+		if face := setFont(18); m.LeveledUp && face != nil {
+			drawBadge(dc, face, "LEVEL UP!", cx, badgeY, xpColor)
+		}
+
+		if face := setFont(math.Min(26, colW/6)); face != nil {
+			drawInk(dc, face, truncateText(dc, m.Name, colW-12), cx, feet+20, 0.5, 0.5, color.White)
+		}
+		if face := setFont(19); face != nil {
+			lv := fmt.Sprintf("Lv %d", m.Level)
+			if m.KnockedOut {
+				lv += "  KO"
+			}
+			drawInk(dc, face, lv, cx, feet+45, 0.5, 0.5, color.RGBA{200, 200, 215, 255})
+			if m.XPGained > 0 {
+				drawInk(dc, face, fmt.Sprintf("+%d XP", m.XPGained), cx, feet+67, 0.5, 0.5, xpColor)
+			}
+		}
+		if m.XPToNext > 0 {
+			barW := colW * 0.7
+			drawFlatBar(dc, cx-barW/2, feet+82, barW, 7, float64(m.XP), float64(m.XPToNext), "#78DCFF", "#000000A0", 3.5)
+		}
+	}
+}
+
+// drawLootGrid draws up to lootCols x lootRows items with rarity borders;
+// extra items are summarised in the last cell
+func drawLootGrid(dc *gg.Context, loot []LootItem, setFont func(float64) font.Face, r image.Rectangle) {
+	drawPanel(dc, r)
+	x0, y0 := float64(r.Min.X)+16, float64(r.Min.Y)+14
+	if face := setFont(24); face != nil {
+		drawInk(dc, face, "LOOT", x0, y0, 0, 0, color.White)
+	}
+	if len(loot) == 0 {
+		if face := setFont(22); face != nil {
+			drawInk(dc, face, "No loot", float64(r.Min.X+r.Max.X)/2, float64(r.Min.Y+r.Max.Y)/2, 0.5, 0.5, color.RGBA{160, 160, 170, 255})
+		}
+		return
+	}
+
+	gap := 10.0
+	top := y0 + 34
+	cellW := (float64(r.Dx()) - 32 - gap*(lootCols-1)) / lootCols
+	cellH := (float64(r.Max.Y) - 14 - top - gap*(lootRows-1)) / lootRows
+	shown := loot
+	more := 0
+	if len(loot) > lootCols*lootRows {
+		shown = loot[:lootCols*lootRows-1]
+		more = len(loot) - len(shown)
+	}
+
+	for i, item := range shown {
+		cx := x0 + float64(i%lootCols)*(cellW+gap)
+		cy := top + float64(i/lootCols)*(cellH+gap)
+		rc := rarityColor(item.Rarity)
+
+		dc.DrawRoundedRectangle(cx, cy, cellW, cellH, 8)
+		dc.SetColor(color.NRGBA{rc.R / 5, rc.G / 5, rc.B / 5, 220})
+		dc.FillPreserve()
+		dc.SetColor(rc)
+		dc.SetLineWidth(3)
+		dc.Stroke()
+
+		drawGem(dc, cx+26, cy+cellH/2, 15, rc)
+		tx, tw := cx+50, cellW-58
+		if face := setFont(19); face != nil {
+			drawInk(dc, face, truncateText(dc, item.Name, tw), tx, cy+cellH/2-4, 0, 1, color.White)
+		}
+		if face := setFont(15); face != nil {
+			sub := strings.ToUpper(strings.TrimSpace(item.Rarity))
+			if sub == "" {
+				sub = "COMMON"
+			}
+			if item.Quantity > 1 {
+				sub = fmt.Sprintf("x%d  %s", item.Quantity, sub)
+			}
+			drawInk(dc, face, truncateText(dc, sub, tw), tx, cy+cellH/2+6, 0, 0, rc)
+		}
+	}
+
+	if more > 0 {
+		i := len(shown)
+		cx := x0 + float64(i%lootCols)*(cellW+gap)
+		cy := top + float64(i/lootCols)*(cellH+gap)
+		dc.DrawRoundedRectangle(cx, cy, cellW, cellH, 8)
+		dc.SetColor(color.NRGBA{255, 255, 255, 30})
+		dc.Fill()
+		if face := setFont(24); face != nil {
+			drawInk(dc, face, fmt.Sprintf("+%d more", more), cx+cellW/2, cy+cellH/2, 0.5, 0.5, color.White)
+		}
+	}
+}
+
+// drawResultStats draws gold earned, turns taken and the MVP
+func drawResultStats(dc *gg.Context, req *EndScreenRequest, setFont func(float64) font.Face, accent color.RGBA, r image.Rectangle) {
+	drawPanel(dc, r)
+	x := float64(r.Min.X) + 24
+	y := float64(r.Min.Y) + 46
+	row := func(label, value string, c color.Color) {
+		if face := setFont(22); face != nil {
+			drawInk(dc, face, label, x+28, y, 0, 0.5, color.RGBA{170, 170, 185, 255})
+		}
+		if face := setFont(30); face != nil {
+			value = truncateText(dc, value, float64(r.Dx())-150)
+			drawInk(dc, face, value, float64(r.Max.X)-22, y, 1, 0.5, c)
+		}
+		y += 66
+	}
+
+	drawCoin(dc, x+6, y, 11)
+	row("Gold", fmt.Sprintf("+%d", req.Gold), victoryColor)
+
+	drawHourglass(dc, x+6, y, 11)
+	turns := "-"
+	if req.Turns > 0 {
+		turns = fmt.Sprintf("%d", req.Turns)
+	}
+	row("Turns", turns, color.White)
+
+	drawStar(dc, x+6, y, 12, accent)
+	mvp := req.MVP
+	if mvp == "" {
+		mvp = "-"
+	}
+	row("MVP", mvp, accent)
+}
+
+// drawPanel draws the translucent rounded box behind the loot and stats
+func drawPanel(dc *gg.Context, r image.Rectangle) {
+	dc.DrawRoundedRectangle(float64(r.Min.X), float64(r.Min.Y), float64(r.Dx()), float64(r.Dy()), 14)
+	dc.SetColor(panelFill)
+	dc.FillPreserve()
+	dc.SetColor(color.NRGBA{255, 255, 255, 50})
+	dc.SetLineWidth(2)
+	dc.Stroke()
+}
+
+// drawBadge draws dark text on a rounded pill whose top centre is (cx, y)
+func drawBadge(dc *gg.Context, face font.Face, text string, cx, y float64, fill color.RGBA) {
+	b, _ := font.BoundString(face, text)
+	w, h := float64(b.Max.X-b.Min.X)/64, float64(b.Max.Y-b.Min.Y)/64
+	pw, ph := w+22, h+12
+	dc.DrawRoundedRectangle(cx-pw/2, y, pw, ph, ph/2)
+	dc.SetColor(fill)
+	dc.Fill()
+
+	ox := cx - w/2 - float64(b.Min.X)/64
+	oy := y + 6 - float64(b.Min.Y)/64
+	dc.SetColor(color.RGBA{20, 14, 0, 255})
+	dc.DrawString(text, ox, oy)
+}
+
+// drawGem draws a faceted diamond as a generic item icon
+func drawGem(dc *gg.Context, cx, cy, r float64, c color.RGBA) {
+	dc.MoveTo(cx, cy-r)
+	dc.LineTo(cx+r*0.8, cy-r*0.2)
+	dc.LineTo(cx, cy+r)
+	dc.LineTo(cx-r*0.8, cy-r*0.2)
+	dc.ClosePath()
+	dc.SetColor(c)
+	dc.FillPreserve()
+	dc.SetColor(color.RGBA{255, 255, 255, 160})
+	dc.SetLineWidth(1.5)
+	dc.Stroke()
+	dc.MoveTo(cx-r*0.8, cy-r*0.2)
+	dc.LineTo(cx+r*0.8, cy-r*0.2)
+	dc.Stroke()
+}
+
+func drawCoin(dc *gg.Context, cx, cy, r float64) {
+	dc.DrawCircle(cx, cy, r)
+	dc.SetColor(victoryColor)
+	dc.FillPreserve()
+	dc.SetColor(color.RGBA{150, 100, 10, 255})
+	dc.SetLineWidth(2)
+	dc.Stroke()
+	dc.DrawCircle(cx, cy, r*0.55)
+	dc.Stroke()
+}
+
+func drawHourglass(dc *gg.Context, cx, cy, r float64) {
+	dc.MoveTo(cx-r*0.7, cy-r)
+	dc.LineTo(cx+r*0.7, cy-r)
+	dc.LineTo(cx-r*0.7, cy+r)
+	dc.LineTo(cx+r*0.7, cy+r)
+	dc.ClosePath()
+	dc.SetColor(color.RGBA{220, 220, 230, 255})
+	dc.SetLineWidth(2.5)
+	dc.Stroke()
+}
+
+func drawStar(dc *gg.Context, cx, cy, r float64, c color.RGBA) {
+	for i := 0; i < 10; i++ {
+		a := float64(i)*math.Pi/5 - math.Pi/2
+		rr := r
+		if i%2 == 1 {
+			rr = r * 0.45
+		}
+		dc.LineTo(cx+math.Cos(a)*rr, cy+math.Sin(a)*rr)
+	}
+	dc.ClosePath()
+	dc.SetColor(c)
+	dc.Fill()
+}
+
+// truncateText shortens s with "..." until it fits maxW in the current font
+func truncateText(dc *gg.Context, s string, maxW float64) string {
+	if w, _ := dc.MeasureString(s); w <= maxW {
+		return s
+	}
+	r := []rune(s)
+	for len(r) > 0 {
+		r = r[:len(r)-1]
+		t := strings.TrimSpace(string(r)) + "..."
+		if w, _ := dc.MeasureString(t); w <= maxW {
+			return t
+		}
+	}
+	return ""
+}
+
+// cardFont returns a setFont for dc: it loads the card font at a size, makes
+// it current and returns it, or nil when the font can't be loaded
+func cardFont(dc *gg.Context) func(size float64) font.Face {
+	fontPath := utils.GetAssetPath("rpgasset", "ui", "fantesy.ttf")
+	return func(size float64) font.Face {
+		face, err := utils.LoadFont(fontPath, size)
+		if err != nil {
+			return nil
+		}
+		dc.SetFontFace(face)
+		return face
+	}
+}
@@ -0,0 +1,71 @@
+package combat
+
+import (
+	"testing"
+
+	"github.com/fogleman/gg"
+)
+
+func TestEndScreenPlain(t *testing.T) {
+	tests := []struct {
+		name  string
+		req   EndScreenRequest
+		plain bool
+	}{
+		{"text", EndScreenRequest{Text: "Victory!"}, true},
+		{"empty", EndScreenRequest{}, true},
+		{"result", EndScreenRequest{Text: "Victory!", Result: "victory"}, false},
+		{"party", EndScreenRequest{Party: []EndScreenMember{{Name: "Ann"}}}, false},
+		{"loot", EndScreenRequest{Loot: []LootItem{{Name: "Gem"}}}, false},
+		{"gold", EndScreenRequest{Gold: 5}, false},
+		{"turns", EndScreenRequest{Turns: 3}, false},
+		{"mvp", EndScreenRequest{MVP: "Ann"}, false},
+		{"background", EndScreenRequest{Background: "env1.png"}, false},
+		{"biome", EndScreenRequest{Biome: "cave"}, false},
+		{"seed", EndScreenRequest{Seed: "7"}, false},
+	}
+	for _, tt := range tests {
+		if got := tt.req.plain(); got != tt.plain {
+			t.Errorf("%s: plain() = %v; want %v", tt.name, got, tt.plain)
+		}
+	}
+}
+
+func TestRarityColor(t *testing.T) {
+	tests := []struct {
+		rarity string
+		want   string
+	}{
+		{"legendary", "legendary"},
+		{" Epic ", "epic"},
+		{"MYTHIC", "mythic"},
+		{"", "common"},
+		{"cursed", "common"},
+	}
+	for _, tt := range tests {
+		if got := rarityColor(tt.rarity); got != rarityColors[tt.want] {
+			t.Errorf("rarityColor(%q) = %v; want %s %v", tt.rarity, got, tt.want, rarityColors[tt.want])
+		}
+	}
+}
+
+func TestTruncateText(t *testing.T) {
+	// The default face is 7 px per character
+	dc := gg.NewContext(10, 10)
+	tests := []struct {
+		text string
+		maxW float64
+		want string
+	}{
+		{"Sword", 35, "Sword"},
+		{"Sword of Dawn", 70, "Sword o..."},
+		{"Sword of Dawn", 63, "Sword..."},
+		{"Sword of Dawn", 20, ""},
+		{"", 10, ""},
+	}
+	for _, tt := range tests {
+		if got := truncateText(dc, tt.text, tt.maxW); got != tt.want {
+			t.Errorf("truncateText(%q, %v) = %q; want %q", tt.text, tt.maxW, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,303 @@
+package combat
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"math"
+	"sort"
+	"sync"
+
+	"image-service/pkg/utils"
+
+	"github.com/disintegration/imaging"
+	"github.com/fogleman/gg"
+)
+
+const (
+	minFormationScale = 0.25
+	maxFormationRows  = 4
+
+	// formationStep is how far along a row the next sprite starts, as a
+	// fraction of the sprite's width; sprites carry transparent margins so
+	// neighbours can overlap a little
+	formationStep = 0.8
+	// formationDepth is how far up each further row stands, as a fraction of
+	// the tallest sprite in the row in front
+	formationDepth = 0.3
+)
+
+// formationUnit is what the solver needs to know about an enemy
+type formationUnit struct {
+	index  int     // Request index, used by the theme's hand-placed slots
+	aspect float64 // Sprite height / width
+	boss   bool
+	art    artBox // Where the opaque art sits inside the sprite
+}
+
+// artBox is a sprite's opaque area as fractions of its width and height.
+// Sprites carry wide transparent margins, so fitting uses the art, not the box.
+type artBox struct {
+	left, top, right, bottom float64
+}
+
+var artBoxes sync.Map // sprite path -> artBox
+
+func newFormationUnit(index int, path string, sprite image.Image, boss bool) formationUnit {
+	b := sprite.Bounds()
+	return formationUnit{
+		index:  index,
+		aspect: float64(b.Dy()) / float64(b.Dx()),
+		boss:   boss,
+		art:    spriteArt(path, sprite),
+	}
+}
+
+// spriteArt finds where the sprite's opaque art sits, cached per path
+func spriteArt(path string, sprite image.Image) artBox {
+	if cached, ok := artBoxes.Load(path); ok {
+		return cached.(artBox)
+	}
+
+	// A thumbnail is plenty to find the margins
+	thumb := imaging.Resize(sprite, 64, 0, imaging.Box)
+	tb := thumb.Bounds()
+	art := artBox{0, 0, 1, 1}
+	if o := utils.OpaqueBounds(thumb, 16); !o.Empty() {
+		art = artBox{
+			left:   float64(o.Min.X-tb.Min.X) / float64(tb.Dx()),
+			top:    float64(o.Min.Y-tb.Min.Y) / float64(tb.Dy()),
+			right:  float64(o.Max.X-tb.Min.X) / float64(tb.Dx()),
+			bottom: float64(o.Max.Y-tb.Min.Y) / float64(tb.Dy()),
+		}
+	}
+	artBoxes.Store(path, art)
+	return art
+}
+
+// formationSlot is a solved sprite position (top-left) and width
+type formationSlot struct {
+	x, y, w float64
+}
+
+// artRect is where the unit's opaque art lands in this slot
+func (f formationSlot) artRect(u formationUnit) (x0, y0, x1, y1 float64) {
+	h := f.w * u.aspect
+	return f.x + f.w*u.art.left, f.y + h*u.art.top, f.x + f.w*u.art.right, f.y + h*u.art.bottom
+}
+
+// enemyFormation places enemies inside the theme's enemy zone and returns the
+// scale they're drawn at. solved is false when the theme's own slots are
+// used. The theme's hand-placed slots are kept whenever
+// everything fits them; otherwise the enemies are laid out in rows, front to
+// back with bosses behind, scaled down until every sprite stays in the zone,
+// above any battle panel, and no two HP bars touch. Themes without a zone always use their slots.
+func (t *Theme) enemyFormation(units []formationUnit, zone Box) (slots []formationSlot, scale float64, solved bool) {
+	classic := t.classicFormation(units)
+	if zone.W <= 0 || zone.H <= 0 || t.formationFits(units, classic, 1, zone) {
+		return classic, 1, false
+	}
+
+	zone = t.clearOfPanels(zone)
+	var best []formationSlot
+	bestScale := 0.0
+	for rows := 1; rows <= min(len(units), maxFormationRows); rows++ {
+		for s := 1.0; s >= minFormationScale && s > bestScale; s -= 0.05 {
+			slots := t.rowFormation(units, rows, s, zone)
+			if t.formationFits(units, slots, s, zone) {
+				best, bestScale = slots, s
+				break
+			}
+		}
+	}
+	if best == nil {
+		// Nothing fits even at the smallest scale: take the densest layout
+		bestScale = minFormationScale
+		best = t.rowFormation(units, min(len(units), maxFormationRows), bestScale, zone)
+	}
+	return best, bestScale, true
+}
+
+// clearOfPanels ends zone above the top of any battle panel that cuts into
+// it, so solved formations don't stand behind the menu
+func (t *Theme) clearOfPanels(zone Box) Box {
+	for _, p := range t.Panels {
+		if p.Show == "duel" || p.X >= zone.X+zone.W || p.X+p.W <= zone.X {
+			continue
+		}
+		if p.Y > zone.Y && p.Y < zone.Y+zone.H {
+			zone.H = p.Y - zone.Y
+		}
+	}
+	return zone
+}
+
+// classicFormation is the theme's hand-placed layout: groups of four in a
+// zigzag, each further group shifted by groupShift
+func (t *Theme) classicFormation(units []formationUnit) []formationSlot {
+	layout := t.Enemies
+	slots := make([]formationSlot, len(units))
+	for n, u := range units {
+		i := u.index
+		ex, ey := layout.X, layout.Y
+		sub := i % 4
+		if sub == 1 || sub == 2 {
+			ex -= layout.SpacingX
+		} else if sub == 3 {
+			ex -= layout.SpacingX * 2
+		}
+		if sub == 1 || sub == 3 {
+			ey += layout.SpacingY
+		}
+		ex += float64(i/4) * layout.GroupShift
+		slots[n] = formationSlot{ex, ey, t.enemyWidth(u, 1)}
+	}
+	return slots
+}
+
+func (t *Theme) enemyWidth(u formationUnit, scale float64) float64 {
+	w := t.Enemies.SpriteWidth * scale
+	if u.boss {
+		w *= t.Enemies.BossScale
+	}
+	return w
+}
+
+// rowFormation lays enemies out in rows at scale s. The front row's art
+// stands on the zone's bottom edge and each further row stands higher and is
+// shifted half a step, so back sprites and their HP bars show between front
+// ones.
+func (t *Theme) rowFormation(units []formationUnit, rows int, s float64, zone Box) []formationSlot {
+	// Front to back in request order, bosses last so they stand behind
+	order := make([]int, len(units))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool {
+		return !units[order[a]].boss && units[order[b]].boss
+	})
+
+	artW := func(u formationUnit) float64 {
+		return t.enemyWidth(u, s) * (u.art.right - u.art.left)
+	}
+	// Neighbours may overlap, but never so far that their HP bars touch
+	step := func(u formationUnit) float64 {
+		return math.Max(artW(u)*formationStep, float64(t.Units.HPBar.W)*s+6)
+	}
+	slots := make([]formationSlot, len(units))
+	per := (len(units) + rows - 1) / rows
+	feet := float64(zone.Y + zone.H)
+	for r := 0; r*per < len(order); r++ {
+		row := order[r*per : min((r+1)*per, len(order))]
+
+		width, tallest := 0.0, 0.0
+		for i, n := range row {
+			u := units[n]
+			if i < len(row)-1 {
+				width += step(u)
+			} else {
+				width += artW(u)
+			}
+			h := t.enemyWidth(u, s) * u.aspect
+			tallest = math.Max(tallest, h*(u.art.bottom-u.art.top))
+		}
+		x := float64(zone.X) + (float64(zone.W)-width)/2
+		if r%2 == 1 {
+			x += step(units[row[0]]) / 2
+		}
+		x = math.Max(float64(zone.X), math.Min(x, float64(zone.X+zone.W)-width))
+
+		for _, n := range row {
+			u := units[n]
+			w := t.enemyWidth(u, s)
+			slots[n] = formationSlot{x - w*u.art.left, feet - w*u.aspect*u.art.bottom, w}
+			x += step(u)
+		}
+		feet -= tallest * formationDepth
+	}
+	return slots
+}
+
+// formationFits reports whether every sprite and HP bar is inside the zone
+// and no two HP bars overlap
+func (t *Theme) formationFits(units []formationUnit, slots []formationSlot, s float64, zone Box) bool {
+	bar := t.Units.HPBar
+	z := zone.rect()
+	bars := make([]image.Rectangle, len(slots))
+	for i, f := range slots {
+		bw, bh := float64(bar.W)*s, float64(bar.H)*s
+		bx, by := f.x+(f.w-bw)/2, f.y+float64(bar.OffsetY)*s
+		bars[i] = image.Rect(int(bx)-2, int(by)-2, int(bx+bw)+2, int(by+bh)+2)
+
+		x0, y0, x1, y1 := f.artRect(units[i])
+		top := math.Min(y0, by)
+		if x0 < float64(z.Min.X) || x1 > float64(z.Max.X) || top < float64(z.Min.Y) || y1 > float64(z.Max.Y) {
+			return false
+		}
+	}
+	for i := range bars {
+		for j := i + 1; j < len(bars); j++ {
+			if bars[i].Overlaps(bars[j]) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// drawOverflowBadge draws "+N more" for enemies left out of the formation
+func (sc *combatScene) drawOverflowBadge(dc *gg.Context) {
+	if sc.overflow <= 0 {
+		return
+	}
+	zone := sc.theme.Enemies.Zone
+	face, err := sc.face(overflowBadgeSize(sc.theme))
+	if err != nil {
+		return
+	}
+	dc.SetFontFace(face)
+	text := fmt.Sprintf("+%d more", sc.overflow)
+	w, _ := dc.MeasureString(text)
+	drawBadge(dc, face, text, float64(zone.X)+w/2+11, float64(zone.Y), color.RGBA{255, 215, 90, 255})
+}
+
+// overflowBadgeSize is the badge's font size; the solver keeps the strip it
+// needs at the top of the zone clear
+func overflowBadgeSize(t *Theme) float64 {
+	return math.Max(14, t.Units.Name.Size)
+}
+
+// collapseOverflow trims the formation to the theme's max, keeping bosses and
+// enemies this turn's actions or effects refer to, then the earliest in the
+// request. The number left out is kept for the "+N more" badge.
+func (sc *combatScene) collapseOverflow(units []formationUnit) []formationUnit {
+	limit := sc.theme.Enemies.Max
+	if limit <= 0 || len(units) <= limit || sc.theme.Enemies.Zone.W <= 0 {
+		return units
+	}
+	involved := make(map[int]bool)
+	mark := func(ref string) {
+		if r, ok := sc.req.resolveUnit(ref); ok && r.side == sideEnemy {
+			involved[r.index] = true
+		}
+	}
+	for _, a := range sc.req.Actions {
+		mark(a.Attacker)
+		mark(a.Target)
+	}
+	for _, e := range sc.req.Effects {
+		mark(e.Source)
+		mark(e.Target)
+	}
+
+	kept := append([]formationUnit{}, units...)
+	sort.SliceStable(kept, func(a, b int) bool {
+		pa := kept[a].boss || involved[kept[a].index]
+		pb := kept[b].boss || involved[kept[b].index]
+		return pa && !pb
+	})
+	kept = kept[:limit]
+	sort.Slice(kept, func(a, b int) bool { return kept[a].index < kept[b].index })
+	sc.overflow = len(units) - limit
+	return kept
+}
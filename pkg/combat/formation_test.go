@@ -0,0 +1,110 @@
+package combat
+
+import (
+	"math"
+	"testing"
+)
+
+// formationTheme is a classic-style layout: four slots in a zigzag and a
+// 600x400 zone with a battle menu cutting into its bottom right
+func formationTheme() *Theme {
+	t := &Theme{
+		Panels: []Panel{{Box: Box{700, 420, 300, 200}, Show: "battle"}},
+		Enemies: EnemyStyle{
+			X: 850, Y: 250, SpacingX: 120, SpacingY: 60, GroupShift: -300,
+			SpriteWidth: 100, BossScale: 1.5,
+			Zone: Box{400, 100, 600, 400},
+		},
+	}
+	t.Units.HPBar.W, t.Units.HPBar.H, t.Units.HPBar.OffsetY = 60, 8, -12
+	return t
+}
+
+func formationUnits(n int, bosses ...int) []formationUnit {
+	units := make([]formationUnit, n)
+	for i := range units {
+		units[i] = formationUnit{index: i, aspect: 1, art: artBox{0.1, 0.1, 0.9, 0.9}}
+	}
+	for _, i := range bosses {
+		units[i].boss = true
+	}
+	return units
+}
+
+func TestClearOfPanels(t *testing.T) {
+	zone := Box{400, 100, 600, 400}
+	tests := []struct {
+		name  string
+		panel Panel
+		want  int // Zone height left
+	}{
+		{"cuts in", Panel{Box: Box{700, 420, 300, 200}, Show: "battle"}, 320},
+		{"always shown", Panel{Box: Box{300, 300, 200, 50}}, 200},
+		{"duel only", Panel{Box: Box{700, 420, 300, 200}, Show: "duel"}, 400},
+		{"beside", Panel{Box: Box{1000, 200, 100, 100}}, 400},
+		{"below", Panel{Box: Box{400, 500, 600, 100}}, 400},
+		{"above the top", Panel{Box: Box{400, 50, 600, 100}}, 400},
+	}
+	for _, tt := range tests {
+		th := &Theme{Panels: []Panel{tt.panel}}
+		got := th.clearOfPanels(zone)
+		if got.X != zone.X || got.Y != zone.Y || got.W != zone.W || got.H != tt.want {
+			t.Errorf("%s: zone %+v; want height %d", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestEnemyFormation(t *testing.T) {
+	th := formationTheme()
+	tests := []struct {
+		name   string
+		units  []formationUnit
+		zone   Box
+		solved bool
+	}{
+		{"fits the slots", formationUnits(3), th.Enemies.Zone, false},
+		{"no zone", formationUnits(12), Box{}, false},
+		{"crowd", formationUnits(12), th.Enemies.Zone, true},
+		{"crowd with bosses", formationUnits(9, 2, 5), th.Enemies.Zone, true},
+	}
+	for _, tt := range tests {
+		slots, scale, solved := th.enemyFormation(tt.units, tt.zone)
+		if solved != tt.solved || len(slots) != len(tt.units) {
+			t.Errorf("%s: solved %v with %d slots; want %v with %d", tt.name, solved, len(slots), tt.solved, len(tt.units))
+			continue
+		}
+		if !solved {
+			if scale != 1 {
+				t.Errorf("%s: classic slots at scale %v", tt.name, scale)
+			}
+			continue
+		}
+		if scale < minFormationScale || scale > 1 {
+			t.Errorf("%s: scale %v", tt.name, scale)
+		}
+		// Solved formations stand above the menu
+		if clear := th.clearOfPanels(tt.zone); !th.formationFits(tt.units, slots, scale, clear) {
+			t.Errorf("%s: formation doesn't fit %+v at scale %v", tt.name, clear, scale)
+		}
+	}
+}
+
+func TestRowFormationBossesBehind(t *testing.T) {
+	th := formationTheme()
+	units := formationUnits(6, 0)
+	slots := th.rowFormation(units, 2, 0.5, th.Enemies.Zone)
+	feet := func(i int) float64 {
+		_, _, _, y1 := slots[i].artRect(units[i])
+		return y1
+	}
+	// The boss is ordered last, so it ends the back row: 4, 5, 0
+	bottom := float64(th.Enemies.Zone.Y + th.Enemies.Zone.H)
+	for _, i := range []int{1, 2, 3} {
+		if math.Abs(feet(i)-bottom) > 1e-9 {
+			t.Errorf("enemy %d feet at %v; want the zone's bottom %v", i, feet(i), bottom)
+		}
+		if feet(0) >= feet(i) {
+			t.Errorf("boss feet at %v, not behind enemy %d at %v", feet(0), i, feet(i))
+		}
+	}
+}
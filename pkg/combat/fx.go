@@ -0,0 +1,544 @@
+package combat
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"math"
+	"math/rand"
+	"strings"
+
+	"image-service/pkg/utils"
+
+	"github.com/fogleman/gg"
+)
+
+// SkillEffect is a procedural visual drawn over the scene for a skill.
+// Source and Target are unit references like CombatAction's.
+type SkillEffect struct {
+	Type    string `json:"type"` // slash, projectile, burst, heal, lightning (or an alias like fireball)
+	Source  string `json:"source"`
+	Target  string `json:"target"`
+	Element string `json:"element"` // fire, ice, water, earth, lightning, holy, dark, poison, wind, nature
+}
+
+const (
+	maxEffects = 12
+
+	// Animated effects play over fxFrames frames of fxDelay each
+	fxFrames = 8
+	fxDelay  = 4
+)
+
+// fxKinds maps effect types and their aliases to a drawing kind, with the
+// element used when the request doesn't name one
+var fxKinds = map[string]struct{ kind, element string }{
+	"slash":      {"slash", ""},
+	"cut":        {"slash", ""},
+	"claw":       {"slash", ""},
+	"projectile": {"projectile", ""},
+	"orb":        {"projectile", ""},
+	"fireball":   {"projectile", "fire"},
+	"frostbolt":  {"projectile", "ice"},
+	"burst":      {"burst", ""},
+	"particles":  {"burst", ""},
+	"impact":     {"burst", ""},
+	"explosion":  {"burst", "fire"},
+	"heal":       {"heal", "nature"},
+	"healing":    {"heal", "nature"},
+	"sparkles":   {"heal", "holy"},
+	"lightning":  {"lightning", "lightning"},
+	"thunder":    {"lightning", "lightning"},
+	"bolt":       {"lightning", "lightning"},
+}
+
+// fxPeak is the progress each kind is frozen at in a still image: the moment
+// that reads best without motion
+var fxPeak = map[string]float64{
+	"slash":      0.5,
+	"projectile": 0.5,
+	"burst":      0.35,
+	"heal":       0.55,
+	"lightning":  0.3,
+}
+
+// fxPalette is an effect's white-hot core and colored glow
+type fxPalette struct {
+	core, glow color.NRGBA
+}
+
+var fxElements = map[string]fxPalette{
+	"":          {color.NRGBA{255, 255, 255, 255}, color.NRGBA{190, 205, 235, 255}},
+	"fire":      {color.NRGBA{255, 240, 200, 255}, color.NRGBA{255, 110, 20, 255}},
+	"ice":       {color.NRGBA{235, 250, 255, 255}, color.NRGBA{90, 190, 255, 255}},
+	"water":     {color.NRGBA{220, 240, 255, 255}, color.NRGBA{40, 120, 255, 255}},
+	"earth":     {color.NRGBA{255, 235, 190, 255}, color.NRGBA{170, 120, 50, 255}},
+	"lightning": {color.NRGBA{255, 255, 235, 255}, color.NRGBA{255, 215, 60, 255}},
+	"holy":      {color.NRGBA{255, 255, 240, 255}, color.NRGBA{255, 210, 110, 255}},
+	"dark":      {color.NRGBA{235, 210, 255, 255}, color.NRGBA{140, 60, 220, 255}},
+	"poison":    {color.NRGBA{230, 255, 210, 255}, color.NRGBA{120, 220, 60, 255}},
+	"wind":      {color.NRGBA{240, 255, 250, 255}, color.NRGBA{110, 235, 195, 255}},
+	"nature":    {color.NRGBA{240, 255, 230, 255}, color.NRGBA{80, 225, 115, 255}},
+}
+
+var fxElementAliases = map[string]string{
+	"physical": "", "none": "", "normal": "",
+	"flame": "fire", "frost": "ice", "electric": "lightning", "thunder": "lightning",
+	"light": "holy", "shadow": "dark", "void": "dark", "air": "wind", "heal": "nature",
+}
+
+// fxPoint is a point along a bolt: t runs from start (0) to end (1) and off
+// is the sideways displacement as a fraction of the bolt's length
+type fxPoint struct{ t, off float64 }
+
+type fxBranch struct {
+	from   int     // Index into the main bolt it forks from
+	angle  float64 // Turn from the main bolt's direction, radians
+	length float64 // Fraction of the main bolt's length
+	pts    []fxPoint
+}
+
+// fxArc is one slash crescent, in units of the target's radius
+type fxArc struct {
+	ox, oy, r    float64
+	start, sweep float64
+	delay        float64
+}
+
+// fxParticle is a spark. Bursts use dx/dy as its flight direction and speed;
+// heal sparkles use dx as the column offset and dy as the rise speed.
+type fxParticle struct {
+	dx, dy, size, delay float64
+}
+
+// sceneEffect is a SkillEffect resolved to units, with all its randomness
+// rolled once so every frame (and every render with the same seed) matches
+type sceneEffect struct {
+	kind      string
+	src, dst  *sceneUnit // src may be nil
+	pal       fxPalette
+	t         float64 // Progress 0-1; the effect isn't drawn outside that range
+	lift      float64 // Projectile arc height, or where a sourceless bolt enters
+	arcs      []fxArc
+	particles []fxParticle
+	bolt      []fxPoint
+	branches  []fxBranch
+}
+
+// buildEffects resolves the request's effects against the scene. Effects
+// with an unknown type or target are skipped.
+func (sc *combatScene) buildEffects() {
+	for i, fx := range sc.req.Effects {
+		if len(sc.effects) >= maxEffects {
+			break
+		}
+		kind, ok := fxKinds[strings.ToLower(strings.TrimSpace(fx.Type))]
+		dst := sc.unit(fx.Target)
+		if !ok || dst == nil {
+			continue
+		}
+		e := &sceneEffect{kind: kind.kind, dst: dst, t: fxPeak[kind.kind]}
+		if fx.Source != "" {
+			e.src = sc.unit(fx.Source)
+		}
+		e.pal = fxElements[sc.effectElement(fx, kind.element, e.src)]
+
+		rng := sc.req.Seed.rand(fmt.Sprintf("fx:%d:%s", i, e.kind))
+		e.roll(rng)
+		sc.effects = append(sc.effects, e)
+	}
+}
+
+// effectElement picks the effect's palette key: the requested element, else
+// the casting enemy's element, else the type's default
+func (sc *combatScene) effectElement(fx SkillEffect, fallback string, src *sceneUnit) string {
+	el := strings.ToLower(strings.TrimSpace(fx.Element))
+	if el == "" && src != nil && src.ref.side == sideEnemy {
+		el = strings.ToLower(sc.req.Enemies[src.ref.index].Element)
+	}
+	if alias, ok := fxElementAliases[el]; ok {
+		el = alias
+	}
+	if _, ok := fxElements[el]; !ok || el == "" {
+		el = fallback
+	}
+	return el
+}
+
+// roll draws the effect's random shape: slash angles, spark spreads, bolt paths
+func (e *sceneEffect) roll(rng *rand.Rand) {
+	switch e.kind {
+	case "slash":
+		n := 2 + rng.Intn(2)
+		base := -math.Pi*0.75 + rng.Float64()*0.6
+		for i := 0; i < n; i++ {
+			sweep := 1.7 + rng.Float64()*0.6
+			if i%2 == 1 {
+				sweep = -sweep
+			}
+			e.arcs = append(e.arcs, fxArc{
+				ox:    (rng.Float64() - 0.5) * 0.4,
+				oy:    (rng.Float64() - 0.5) * 0.4,
+				r:     0.9 + rng.Float64()*0.4,
+				start: base + float64(i)*math.Pi*0.55 + (rng.Float64()-0.5)*0.4,
+				sweep: sweep,
+				delay: float64(i) * 0.14,
+			})
+		}
+	case "heal":
+		for i := 0; i < 16; i++ {
+			e.particles = append(e.particles, fxParticle{
+				dx:    (rng.Float64()*2 - 1) * 0.7,
+				dy:    0.5 + rng.Float64()*0.6,
+				size:  0.6 + rng.Float64()*0.8,
+				delay: rng.Float64() * 0.45,
+			})
+		}
+	case "lightning":
+		e.bolt = jaggedPath(rng, 6, 0.16)
+		e.lift = (rng.Float64()*2 - 1) * 0.8
+		for i := 0; i < 2+rng.Intn(2); i++ {
+			side := 1.0
+			if rng.Intn(2) == 0 {
+				side = -1
+			}
+			e.branches = append(e.branches, fxBranch{
+				from:   len(e.bolt)/5 + rng.Intn(len(e.bolt)*3/5),
+				angle:  side * (0.35 + rng.Float64()*0.5),
+				length: 0.18 + rng.Float64()*0.2,
+				pts:    jaggedPath(rng, 4, 0.12),
+			})
+		}
+	}
+	if e.kind == "projectile" || e.kind == "burst" {
+		e.lift = 0.15 + rng.Float64()*0.2
+		n := 14
+		if e.kind == "burst" {
+			n = 22
+		}
+		for i := 0; i < n; i++ {
+			a := rng.Float64() * 2 * math.Pi
+			speed := 0.6 + rng.Float64()*0.8
+			e.particles = append(e.particles, fxParticle{
+				dx:    math.Cos(a) * speed,
+				dy:    math.Sin(a) * speed,
+				size:  0.5 + rng.Float64(),
+				delay: rng.Float64() * 0.15,
+			})
+		}
+	}
+}
+
+// jaggedPath builds a bolt by midpoint displacement, halving the jitter at
+// each of the levels
+func jaggedPath(rng *rand.Rand, levels int, jitter float64) []fxPoint {
+	pts := []fxPoint{{0, 0}, {1, 0}}
+	for l := 0; l < levels; l++ {
+		next := make([]fxPoint, 0, len(pts)*2-1)
+		for i := 0; i < len(pts)-1; i++ {
+			a, b := pts[i], pts[i+1]
+			next = append(next, a, fxPoint{(a.t + b.t) / 2, (a.off+b.off)/2 + (rng.Float64()*2-1)*jitter})
+		}
+		pts = append(next, pts[len(pts)-1])
+		jitter *= 0.55
+	}
+	return pts
+}
+
+// drawEffects paints every active effect onto a scratch layer and adds it to
+// the frame, so glows brighten what's behind them
+func (sc *combatScene) drawEffects(dc *gg.Context) {
+	active := false
+	for _, e := range sc.effects {
+		active = active || (e.t >= 0 && e.t <= 1)
+	}
+	dst, ok := dc.Image().(*image.RGBA)
+	if !active || !ok {
+		return
+	}
+	if sc.fxLayer == nil {
+		sc.fxLayer = gg.NewContext(sc.theme.Width, sc.theme.Height)
+	}
+	l := sc.fxLayer
+	layer := l.Image().(*image.RGBA)
+	clear(layer.Pix)
+
+	for _, e := range sc.effects {
+		if e.t < 0 || e.t > 1 {
+			continue
+		}
+		at, ok := sc.anchor(e.dst, 0.5)
+		if !ok {
+			continue
+		}
+		r := math.Max(24, at.h*0.45)
+		switch e.kind {
+		case "slash":
+			e.drawSlash(l, at, r)
+		case "projectile":
+			sc.drawProjectile(l, e, at, r)
+		case "burst":
+			e.drawImpact(l, at.x, at.y, r, e.t)
+		case "heal":
+			e.drawHeal(l, at, r)
+		case "lightning":
+			sc.drawLightning(l, e, at, r)
+		}
+	}
+	utils.AddBlend(dst, layer)
+}
+
+func (e *sceneEffect) drawSlash(l *gg.Context, at unitAnchor, r float64) {
+	for _, arc := range e.arcs {
+		p := (e.t - arc.delay) / 0.6
+		if p <= 0 || p >= 1 {
+			continue
+		}
+		head := arc.start + arc.sweep*easeOut(math.Min(1, p*1.6))
+		tail := arc.start + arc.sweep*easeIn(clamp01((p-0.3)/0.7))
+		fade := 1 - clamp01((p-0.6)/0.4)
+		// Center the circle behind the target so the blade's middle cuts across it
+		ar := arc.r * r
+		mid := arc.start + arc.sweep/2
+		cx := at.x + arc.ox*r - math.Cos(mid)*ar*0.9
+		cy := at.y + arc.oy*r - math.Sin(mid)*ar*0.9
+		w := r * 0.2
+
+		crescentPath(l, cx, cy, ar, tail, head, w*1.8)
+		l.SetColor(withAlpha(e.pal.glow, 70*fade))
+		l.Fill()
+		crescentPath(l, cx, cy, ar, tail, head, w)
+		l.SetColor(withAlpha(e.pal.glow, 200*fade))
+		l.Fill()
+		crescentPath(l, cx, cy, ar, tail, head, w*0.4)
+		l.SetColor(withAlpha(e.pal.core, 255*fade))
+		l.Fill()
+	}
+}
+
+// crescentPath outlines a blade stroke along a circle from a0 to a1, widest
+// (w) in the middle and tapering to points at both ends
+func crescentPath(l *gg.Context, cx, cy, r, a0, a1, w float64) {
+	const n = 24
+	l.NewSubPath()
+	for i := 0; i <= n; i++ {
+		u := float64(i) / n
+		a := a0 + (a1-a0)*u
+		rr := r + w/2*math.Sin(math.Pi*u)
+		l.LineTo(cx+math.Cos(a)*rr, cy+math.Sin(a)*rr)
+	}
+	for i := n; i >= 0; i-- {
+		u := float64(i) / n
+		a := a0 + (a1-a0)*u
+		rr := r - w/2*math.Sin(math.Pi*u)
+		l.LineTo(cx+math.Cos(a)*rr, cy+math.Sin(a)*rr)
+	}
+	l.ClosePath()
+}
+
+// drawProjectile flies a glowing orb with a fading trail from the source (or
+// in from the side the target faces) and bursts on impact
+func (sc *combatScene) drawProjectile(l *gg.Context, e *sceneEffect, at unitAnchor, r float64) {
+	const impact = 0.6
+	fx, fy := sc.effectOrigin(e, at, r)
+	dist := math.Hypot(at.x-fx, at.y-fy)
+	pos := func(f float64) (float64, float64) {
+		f = easeIn(f)
+		return fx + (at.x-fx)*f, fy + (at.y-fy)*f - e.lift*dist*math.Sin(math.Pi*f)
+	}
+
+	if flight := e.t / impact; flight < 1 {
+		orb := r * 0.2
+		for k := 8; k >= 1; k-- {
+			f := flight - float64(k)*0.035
+			if f < 0 {
+				continue
+			}
+			x, y := pos(f)
+			s := 1 - float64(k)/9
+			l.DrawCircle(x, y, orb*(0.4+0.6*s))
+			l.SetColor(withAlpha(e.pal.glow, 150*s))
+			l.Fill()
+		}
+		x, y := pos(flight)
+		drawGlow(l, x, y, orb*2.4, e.pal, 1)
+	}
+	if e.t > impact-0.05 {
+		e.drawImpact(l, at.x, at.y, r, (e.t-impact+0.05)/(1-impact+0.05))
+	}
+}
+
+// effectOrigin is where a traveling effect starts: the source unit, or off
+// the canvas edge opposite the target when there is none
+func (sc *combatScene) effectOrigin(e *sceneEffect, at unitAnchor, r float64) (float64, float64) {
+	if e.src != nil && e.src != e.dst {
+		if a, ok := sc.anchor(e.src, 0.4); ok {
+			return a.x, a.y
+		}
+	}
+	if at.x > float64(sc.theme.Width)/2 {
+		return -r, at.y - at.h*0.4
+	}
+	return float64(sc.theme.Width) + r, at.y - at.h*0.4
+}
+
+// drawImpact is a flash, a shockwave ring and a spray of sparks at progress u
+func (e *sceneEffect) drawImpact(l *gg.Context, cx, cy, r, u float64) {
+	if u <= 0 || u >= 1 {
+		return
+	}
+	drawGlow(l, cx, cy, r*(0.3+u*0.5), e.pal, (1-u)*(1-u))
+
+	l.SetLineWidth(math.Max(1, r*0.12*(1-u)))
+	l.SetColor(withAlpha(e.pal.glow, 220*(1-u)))
+	l.DrawCircle(cx, cy, r*(0.2+u*1.1))
+	l.Stroke()
+
+	for _, p := range e.particles {
+		f := clamp01((u - p.delay) / (1 - p.delay))
+		if f <= 0 || f >= 1 {
+			continue
+		}
+		// Sparks are drawn as streaks from where they were a moment ago
+		at := func(f float64) (float64, float64) {
+			d := easeOut(f) * r * 1.3
+			return cx + p.dx*d, cy + p.dy*d + f*f*r*0.3
+		}
+		x0, y0 := at(math.Max(0, f-0.12))
+		x1, y1 := at(f)
+		s := math.Max(1, r*0.05*p.size*(1-f))
+		l.SetLineCapRound()
+		l.SetLineWidth(s * 2.2)
+		l.SetColor(withAlpha(e.pal.glow, 150*(1-f)))
+		l.DrawLine(x0, y0, x1, y1)
+		l.Stroke()
+		l.SetLineWidth(s * 0.8)
+		l.SetColor(withAlpha(e.pal.core, 255*(1-f)))
+		l.DrawLine(x0, y0, x1, y1)
+		l.Stroke()
+	}
+}
+
+// drawHeal raises a soft light column with twinkling sparkles through the target
+func (e *sceneEffect) drawHeal(l *gg.Context, at unitAnchor, r float64) {
+	env := math.Sin(math.Pi * e.t)
+	bottom, top := at.y+at.h*0.42, at.y-at.h*0.7
+
+	// Stacked pills of shrinking width give the column soft sides
+	g := gg.NewLinearGradient(0, bottom, 0, top)
+	g.AddColorStop(0, withAlpha(e.pal.glow, 30*env))
+	g.AddColorStop(1, withAlpha(e.pal.glow, 0))
+	l.SetFillStyle(g)
+	for i := 0; i < 5; i++ {
+		w := r * (0.9 - float64(i)*0.15)
+		l.DrawRoundedRectangle(at.x-w, top, w*2, bottom-top, w)
+		l.Fill()
+	}
+
+	l.SetLineWidth(math.Max(1, r*0.04))
+	l.SetColor(withAlpha(e.pal.glow, 150*env))
+	l.DrawEllipse(at.x, bottom, r*(0.7+0.3*e.t), r*(0.2+0.08*e.t))
+	l.Stroke()
+
+	for _, p := range e.particles {
+		f := (e.t - p.delay) / 0.55
+		if f <= 0 || f >= 1 {
+			continue
+		}
+		x := at.x + p.dx*r
+		y := bottom - f*p.dy*(bottom-top)
+		s := r * 0.14 * p.size * math.Sin(math.Pi*f)
+		drawGlow(l, x, y, s*1.6, e.pal, 0.7)
+		l.SetColor(withAlpha(e.pal.core, 255))
+		drawStarShape(l, x, y, s, s*0.22, 4)
+	}
+}
+
+// drawLightning strikes a forked bolt from the source, or from the sky, to the
+// target. It flickers once mid-strike before fading.
+func (sc *combatScene) drawLightning(l *gg.Context, e *sceneEffect, at unitAnchor, r float64) {
+	a := 1.0
+	switch {
+	case e.t < 0.06:
+		return
+	case e.t > 0.38 && e.t < 0.48:
+		a = 0.25
+	case e.t > 0.7:
+		a = (1 - e.t) / 0.3
+	}
+
+	ex, ey := at.x, at.y-at.h*0.05
+	sx, sy := at.x+e.lift*r*2, -r
+	if e.src != nil && e.src != e.dst {
+		sx, sy = sc.effectOrigin(e, at, r)
+	}
+	main := boltPoints(e.bolt, sx, sy, ex, ey)
+	length := math.Hypot(ex-sx, ey-sy)
+	dir := math.Atan2(ey-sy, ex-sx)
+
+	paths := [][]gg.Point{main}
+	for _, b := range e.branches {
+		o := main[min(b.from, len(main)-1)]
+		d := dir + b.angle
+		bl := length * b.length
+		paths = append(paths, boltPoints(b.pts, o.X, o.Y, o.X+math.Cos(d)*bl, o.Y+math.Sin(d)*bl))
+	}
+
+	drawGlow(l, ex, ey, r*0.9, e.pal, a)
+	for i, pts := range paths {
+		w := r * 0.12
+		if i > 0 {
+			w *= 0.55
+		}
+		for _, pass := range []struct {
+			width, alpha float64
+			c            color.NRGBA
+		}{{w * 2.4, 60, e.pal.glow}, {w, 170, e.pal.glow}, {w * 0.35, 255, e.pal.core}} {
+			l.SetLineWidth(math.Max(1, pass.width))
+			l.SetLineCapRound()
+			l.SetLineJoinRound()
+			l.SetColor(withAlpha(pass.c, pass.alpha*a))
+			for _, p := range pts {
+				l.LineTo(p.X, p.Y)
+			}
+			l.Stroke()
+		}
+	}
+}
+
+// boltPoints places a jagged path between two canvas points
+func boltPoints(pts []fxPoint, x0, y0, x1, y1 float64) []gg.Point {
+	dx, dy := x1-x0, y1-y0
+	out := make([]gg.Point, len(pts))
+	for i, p := range pts {
+		out[i] = gg.Point{X: x0 + dx*p.t - dy*p.off, Y: y0 + dy*p.t + dx*p.off}
+	}
+	return out
+}
+
+// drawGlow fills a soft radial glow: the core color in the middle fading out
+// through the glow color
+func drawGlow(l *gg.Context, x, y, radius float64, pal fxPalette, a float64) {
+	if radius <= 0 || a <= 0 {
+		return
+	}
+	g := gg.NewRadialGradient(x, y, 0, x, y, radius)
+	g.AddColorStop(0, withAlpha(pal.core, 255*a))
+	g.AddColorStop(0.3, withAlpha(pal.glow, 200*a))
+	g.AddColorStop(1, withAlpha(pal.glow, 0))
+	l.SetFillStyle(g)
+	l.DrawCircle(x, y, radius)
+	l.Fill()
+}
+
+func withAlpha(c color.NRGBA, a float64) color.NRGBA {
+	c.A = uint8(math.Max(0, math.Min(255, a)))
+	return c
+}
+
+func clamp01(v float64) float64 {
+	return math.Max(0, math.Min(1, v))
+}
+
+func easeIn(t float64) float64  { return t * t }
+func easeOut(t float64) float64 { return 1 - (1-t)*(1-t) }
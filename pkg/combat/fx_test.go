@@ -0,0 +1,130 @@
+package combat
+
+import (
+	"math/rand"
+	"reflect"
+	"testing"
+)
+
+// fxScene is a two-on-two scene with the request's effects built
+func fxScene(seed Seed, effects ...SkillEffect) *combatScene {
+	req := &CombatRequest{
+		Players: []Player{{Name: "Ann"}, {Name: "Bo"}},
+		Enemies: []Enemy{{Name: "Bat", Element: "ice"}, {Name: "Ogre", Element: "Shadow"}},
+		Effects: effects,
+		Seed:    seed,
+	}
+	sc := &combatScene{req: req}
+	for i, p := range req.Players {
+		sc.players = append(sc.players, &sceneUnit{ref: unitRef{sidePlayer, i}, name: p.Name, hp: 10})
+	}
+	for i, e := range req.Enemies {
+		sc.enemies = append(sc.enemies, &sceneUnit{ref: unitRef{sideEnemy, i}, name: e.Name, hp: 10})
+	}
+	sc.buildEffects()
+	return sc
+}
+
+func TestEffectsSeeded(t *testing.T) {
+	effects := []SkillEffect{
+		{Type: "slash", Source: "player:0", Target: "enemy:0"},
+		{Type: "fireball", Source: "player:1", Target: "enemy:1"},
+		{Type: "explosion", Target: "enemy:1"},
+		{Type: "heal", Target: "player:0"},
+		{Type: "lightning", Source: "enemy:1", Target: "player:1"},
+	}
+	a, b := fxScene("7", effects...), fxScene("7", effects...)
+	if len(a.effects) != len(effects) {
+		t.Fatalf("built %d effects; want %d", len(a.effects), len(effects))
+	}
+	for i := range a.effects {
+		if !reflect.DeepEqual(a.effects[i], b.effects[i]) {
+			t.Errorf("%s: differs between renders with the same seed", effects[i].Type)
+		}
+	}
+
+	other := fxScene("8", effects...)
+	for i := range a.effects {
+		if reflect.DeepEqual(a.effects[i], other.effects[i]) {
+			t.Errorf("%s: same shape for seeds 7 and 8", effects[i].Type)
+		}
+	}
+}
+
+func TestBuildEffects(t *testing.T) {
+	tests := []struct {
+		fx   SkillEffect
+		kind string // "" when the effect is skipped
+		el   string
+	}{
+		{SkillEffect{Type: "Slash", Target: "bat"}, "slash", ""},
+		{SkillEffect{Type: " claw ", Target: "bat", Element: "poison"}, "slash", "poison"},
+		{SkillEffect{Type: "fireball", Target: "bat"}, "projectile", "fire"},
+		{SkillEffect{Type: "fireball", Target: "bat", Element: "frost"}, "projectile", "ice"},
+		{SkillEffect{Type: "orb", Source: "enemy:0", Target: "ann"}, "projectile", "ice"},
+		{SkillEffect{Type: "orb", Source: "ogre", Target: "ann"}, "projectile", "dark"},
+		{SkillEffect{Type: "orb", Source: "ogre", Target: "ann", Element: "physical"}, "projectile", ""},
+		{SkillEffect{Type: "orb", Source: "ann", Target: "bat"}, "projectile", ""},
+		{SkillEffect{Type: "heal", Target: "bo", Element: "plasma"}, "heal", "nature"},
+		{SkillEffect{Type: "sparkles", Target: "bo"}, "heal", "holy"},
+		{SkillEffect{Type: "bolt", Source: "nobody", Target: "ogre"}, "lightning", "lightning"},
+		{SkillEffect{Type: "dance", Target: "bat"}, "", ""},
+		{SkillEffect{Type: "slash", Target: "dragon"}, "", ""},
+		{SkillEffect{Type: "slash"}, "", ""},
+	}
+	for _, tt := range tests {
+		sc := fxScene("7", tt.fx)
+		if tt.kind == "" {
+			if len(sc.effects) != 0 {
+				t.Errorf("%+v: built %q; want it skipped", tt.fx, sc.effects[0].kind)
+			}
+			continue
+		}
+		if len(sc.effects) != 1 {
+			t.Errorf("%+v: skipped; want %s", tt.fx, tt.kind)
+			continue
+		}
+		e := sc.effects[0]
+		if e.kind != tt.kind || e.pal != fxElements[tt.el] {
+			t.Errorf("%+v: %s with palette %v; want %s with %q", tt.fx, e.kind, e.pal, tt.kind, tt.el)
+		}
+		if e.t != fxPeak[tt.kind] {
+			t.Errorf("%+v: progress %v; want the peak %v", tt.fx, e.t, fxPeak[tt.kind])
+		}
+	}
+
+	many := make([]SkillEffect, maxEffects+5)
+	for i := range many {
+		many[i] = SkillEffect{Type: "burst", Target: "bat"}
+	}
+	if n := len(fxScene("7", many...).effects); n != maxEffects {
+		t.Errorf("built %d of %d effects; want %d", n, len(many), maxEffects)
+	}
+}
+
+func TestJaggedPath(t *testing.T) {
+	for _, levels := range []int{0, 1, 4, 6} {
+		pts := jaggedPath(rand.New(rand.NewSource(1)), levels, 0.16)
+		if want := 1<<levels + 1; len(pts) != want {
+			t.Errorf("%d levels: %d points; want %d", levels, len(pts), want)
+			continue
+		}
+		if pts[0] != (fxPoint{0, 0}) || pts[len(pts)-1] != (fxPoint{1, 0}) {
+			t.Errorf("%d levels: runs %v to %v; want the ends pinned", levels, pts[0], pts[len(pts)-1])
+		}
+		for i := 1; i < len(pts); i++ {
+			if pts[i].t <= pts[i-1].t {
+				t.Errorf("%d levels: point %d at t %v after %v", levels, i, pts[i].t, pts[i-1].t)
+			}
+			// Jitter shrinks by 0.55 each level, so offsets stay under twice the first
+			if pts[i].off > 0.32 || pts[i].off < -0.32 {
+				t.Errorf("%d levels: point %d off by %v", levels, i, pts[i].off)
+			}
+		}
+	}
+	a := jaggedPath(rand.New(rand.NewSource(3)), 5, 0.16)
+	b := jaggedPath(rand.New(rand.NewSource(3)), 5, 0.16)
+	if !reflect.DeepEqual(a, b) {
+		t.Error("jaggedPath differs for the same source")
+	}
+}
@@ -0,0 +1,268 @@
+package combat
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"math"
+	"sync"
+
+	"image-service/pkg/utils"
+
+	"github.com/disintegration/imaging"
+	"github.com/fogleman/gg"
+)
+
+// partySlot is where one player's HUD card is drawn, and at what scale
+type partySlot struct {
+	x, y  int
+	scale float64
+}
+
+// partyLayout returns one slot per player. A solo player keeps the theme's
+// full-size card; larger parties get the grid with the biggest card scale
+// that fits zone, bottom-aligned so the battlefield stays clear. Mirrored
+// layouts fill the zone from the right edge of the canvas (PvP team B).
+func (t *Theme) partyLayout(n int, zone image.Rectangle, mirror bool) []partySlot {
+	if n <= 0 {
+		return nil
+	}
+	cardW, cardH, headroom := t.Party.Card.W, t.Party.Card.H, t.Party.Headroom
+	maxScale := t.soloScale()
+	if n == 1 {
+		slot := partySlot{t.Party.Solo.X, t.Party.Solo.Y, maxScale}
+		if mirror {
+			slot.x = t.Width - slot.x - scaled(cardW, maxScale)
+		}
+		return []partySlot{slot}
+	}
+
+	zw, zh := float64(zone.Dx()), float64(zone.Dy())
+	bestCols, bestScale := 1, 0.0
+	for cols := 1; cols <= n; cols++ {
+		rows := (n + cols - 1) / cols
+		s := math.Min(zw/float64(cols)/float64(cardW), zh/float64(rows)/float64(cardH+headroom))
+		if s > bestScale {
+			bestCols, bestScale = cols, s
+		}
+	}
+	s := math.Min(bestScale, maxScale)
+
+	cols := bestCols
+	rows := (n + cols - 1) / cols
+	cellW, cellH := float64(cardW)*s, float64(cardH+headroom)*s
+	top := float64(zone.Max.Y) - cellH*float64(rows)
+
+	slots := make([]partySlot, n)
+	for i := range slots {
+		col, row := i%cols, i/cols
+		x := zone.Min.X + int(float64(col)*cellW)
+		if mirror {
+			x = zone.Max.X - int(float64(col+1)*cellW)
+		}
+		slots[i] = partySlot{
+			x:     x,
+			y:     int(top + float64(row)*cellH + float64(headroom)*s),
+			scale: s,
+		}
+	}
+	return slots
+}
+
+// partyUI holds the card frame and icons pre-sized for one card scale
+type partyUI struct {
+	frame image.Image
+	icons []image.Image // Index matches theme.Party.Icons
+}
+
+// partyUIFor returns the card pieces for a scale, rendering them on first use
+func (sc *combatScene) partyUIFor(scale float64) partyUI {
+	if ui, ok := sc.partyUIs[scale]; ok {
+		return ui
+	}
+	party := sc.theme.Party
+	ui := partyUI{frame: sc.panelImage(party.Card, scale)}
+	for _, icon := range party.Icons {
+		ui.icons = append(ui.icons, sc.panelImage(icon, scale))
+	}
+	if sc.partyUIs == nil {
+		sc.partyUIs = make(map[float64]partyUI)
+	}
+	sc.partyUIs[scale] = ui
+	return ui
+}
+
+// panelImage renders a panel at scale: its UI image resized to the box, or
+// a flat rounded fill. Returns nil when there is nothing to draw.
+func (sc *combatScene) panelImage(p Panel, scale float64) image.Image {
+	w, h := scaled(p.W, scale), scaled(p.H, scale)
+	if w <= 0 || h <= 0 {
+		return nil
+	}
+	if p.Image != "" {
+		img, err := utils.LoadImage(sc.uiPath(p.Image))
+		if err != nil {
+			return nil
+		}
+		return imaging.Resize(img, w, h, imaging.Lanczos)
+	}
+	if p.Fill == "" && p.Stroke == "" {
+		return nil
+	}
+
+	dc := gg.NewContext(w, h)
+	r := p.Radius * scale
+	if p.Fill != "" {
+		dc.DrawRoundedRectangle(0, 0, float64(w), float64(h), r)
+		dc.SetColor(utils.ParseHexColor(p.Fill))
+		dc.Fill()
+	}
+	if p.Stroke != "" {
+		dc.DrawRoundedRectangle(1, 1, float64(w)-2, float64(h)-2, r)
+		dc.SetColor(utils.ParseHexColor(p.Stroke))
+		dc.SetLineWidth(2)
+		dc.Stroke()
+	}
+	return dc.Image()
+}
+
+var portraitFrames sync.Map // manifest, sprite path and aspect -> image.Rectangle
+
+// characterPortrait crops a w x h head-and-shoulders portrait from a player's
+// sprite, bare or composed. The frame is found on the bare sprite, around its
+// face anchor when the manifest sets one, so cosmetic layers don't move it.
+func characterPortrait(sprite image.Image, class string, index, w, h int, assetsPath string) image.Image {
+	path := GetCharacterSpritePath(class, index, assetsPath)
+	meta := CharacterSprite(class, index)
+	aspect := float64(w) / float64(h)
+	key := fmt.Sprintf("%p|%s|%.3f", Sprites(), path, aspect)
+	frame, ok := portraitFrames.Load(key)
+	if !ok {
+		base, err := utils.LoadImage(path)
+		if err != nil {
+			base = sprite
+		}
+		var face *image.Point
+		if a, ok := meta.Anchors["face"]; ok {
+			b := base.Bounds()
+			face = &image.Point{b.Min.X + int(a.X*float64(b.Dx())), b.Min.Y + int(a.Y*float64(b.Dy()))}
+		}
+		frame = utils.PortraitFrame(base, aspect, face)
+		portraitFrames.Store(key, frame)
+	}
+	return utils.CropPortrait(sprite, frame.(image.Rectangle), w, h)
+}
+
+// drawPlayerHUD draws one party card: frame, portrait, name, level and the
+// HP / energy bars. Knocked-out players get a dimmed card.
+func (sc *combatScene) drawPlayerHUD(dc *gg.Context, u *sceneUnit, slot partySlot) {
+	party := sc.theme.Party
+	s := slot.scale
+	at := func(v int) int { return scaled(v, s) }
+	ui := sc.partyUIFor(s)
+	ko := u.hp <= 0
+
+	if ui.frame != nil {
+		utils.Blit(dc, ui.frame, slot.x, slot.y)
+	}
+	for i, icon := range ui.icons {
+		if icon != nil {
+			utils.Blit(dc, icon, slot.x+at(party.Icons[i].X), slot.y+at(party.Icons[i].Y))
+		}
+	}
+
+	sc.drawStatBar(dc, party.HP, slot, u.hp, u.maxHP)
+	sc.drawStatBar(dc, party.Energy, slot, u.energy, u.maxEnergy)
+	if !ko {
+		sc.drawStatusRow(dc, party.Status, u.effects, float64(slot.x), float64(slot.y), s)
+	}
+
+	if u.portrait != nil {
+		portrait := u.portrait
+		if ko {
+			portrait = u.portraitKO
+		}
+		utils.Blit(dc, portrait, slot.x+at(party.Portrait.X), slot.y+at(party.Portrait.Bottom)-portrait.Bounds().Dy())
+	}
+
+	ox, oy := float64(slot.x), float64(slot.y)
+	if u.name != "" {
+		sc.drawText(dc, party.Name, u.name, ox, oy, s)
+	}
+	if u.level > 0 {
+		sc.drawText(dc, party.Level, fmt.Sprintf("Lv %d", u.level), ox, oy, s)
+	}
+
+	if ko {
+		dc.SetColor(themeColor(party.KO.Dim, color.RGBA{0, 0, 0, 120}))
+		dc.DrawRectangle(ox, oy, float64(party.Card.W)*s, float64(party.Card.H)*s)
+		dc.Fill()
+		sc.drawText(dc, party.KO.Text, "KO", ox, oy, s)
+	}
+}
+
+// drawStatBar splits current/max evenly over the bar's segments and draws
+// each one relative to the card slot
+func (sc *combatScene) drawStatBar(dc *gg.Context, b BarStyle, slot partySlot, current, max float64) {
+	n := len(b.Segments)
+	if n == 0 {
+		return
+	}
+	s := slot.scale
+	seg := max / float64(n)
+	for i, box := range b.Segments {
+		cur := math.Max(0, math.Min(seg, current-(float64(i)*seg)))
+		x, y := slot.x+scaled(box.X, s), slot.y+scaled(box.Y, s)
+		w, h := scaled(box.W, s), scaled(box.H, s)
+		if b.Image != "" {
+			drawBar(dc, sc.uiPath, x, y, cur, seg, b.Image, w, h)
+			continue
+		}
+		drawFlatBar(dc, float64(x), float64(y), float64(w), float64(h), cur, seg, b.Fill, b.Back, b.Radius*s)
+	}
+}
+
+// drawFlatBar draws a rounded back track with the filled part over it
+func drawFlatBar(dc *gg.Context, x, y, w, h, current, max float64, fill, back string, radius float64) {
+	if back != "" {
+		dc.DrawRoundedRectangle(x, y, w, h, radius)
+		dc.SetColor(utils.ParseHexColor(back))
+		dc.Fill()
+	}
+	if max <= 0 || current <= 0 {
+		return
+	}
+	fw := math.Max(w*math.Min(1, current/max), math.Min(w, radius*2))
+	dc.DrawRoundedRectangle(x, y, fw, h, radius)
+	dc.SetColor(themeColor(fill, color.White))
+	dc.Fill()
+}
+
+// drawText draws a theme text style positioned relative to (ox, oy) at scale s
+func (sc *combatScene) drawText(dc *gg.Context, st TextStyle, text string, ox, oy, s float64) {
+	face, err := sc.face(math.Max(12, st.Size*s))
+	if err != nil {
+		return
+	}
+	dc.SetFontFace(face)
+	x, y := ox+st.X*s, oy+st.Y*s
+	c := themeColor(st.Color, color.White)
+	if st.Shadow {
+		drawOutlined(dc, text, x, y, st.AnchorX, st.AnchorY, c)
+		return
+	}
+	dc.SetColor(c)
+	dc.DrawStringAnchored(text, x, y, st.AnchorX, st.AnchorY)
+}
+
+// drawOutlined draws anchored text with a dark drop shadow so it reads over any art
+func drawOutlined(dc *gg.Context, text string, x, y, ax, ay float64, c color.Color) {
+	dc.SetColor(color.RGBA{0, 0, 0, 200})
+	dc.DrawStringAnchored(text, x+2, y+2, ax, ay)
+	dc.SetColor(c)
+	dc.DrawStringAnchored(text, x, y, ax, ay)
+}
+
+func scaled(v int, s float64) int {
+	return int(math.Round(float64(v) * s))
+}
@@ -0,0 +1,148 @@
+package combat
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"strings"
+
+	"image-service/pkg/utils"
+
+	"github.com/fogleman/gg"
+	"github.com/gin-gonic/gin"
+	"golang.org/x/image/font"
+)
+
+// InventoryRequest is a player's bag, one page of slots at a time
+type InventoryRequest struct {
+	Name        string          `json:"name"`
+	Class       string          `json:"class"`
+	SpriteIndex int             `json:"spriteIndex"`
+	Cosmetics   Cosmetics       `json:"cosmetics"`
+	Items       []InventoryItem `json:"items"`
+	Capacity    int             `json:"capacity"` // Bag slots; 0 sizes the bag to its items
+	Gold        int             `json:"gold"`
+	Page        int             `json:"page"` // 1-based
+
+	Background string `json:"background"`
+	Biome      string `json:"biome"`
+	Seed       Seed   `json:"seed"`
+}
+
+// InventoryItem is one bag slot's stack
+type InventoryItem struct {
+	Item
+	Quantity int  `json:"quantity"`
+	Equipped bool `json:"equipped"`
+}
+
+const (
+	bagCols = 6
+	bagRows = 4
+)
+
+func GenerateInventory(c *gin.Context) {
+	var req InventoryRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	if err := checkBackground(req.Background); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+
+	buf, err := utils.EncodeImageToBuffer(renderInventory(&req, "assets"))
+	if err != nil {
+		c.JSON(500, gin.H{"error": "Failed to encode image"})
+		return
+	}
+
+	c.Data(200, "image/png", buf)
+}
+
+// renderInventory draws the player and their gold on the left and a page of
+// bag slots on the right, laid out like the shop
+func renderInventory(req *InventoryRequest, assetsPath string) image.Image {
+	dc := gg.NewContext(CANVAS_W, CANVAS_H)
+	setFont := cardFont(dc)
+
+	drawBlurredArena(dc, &CombatRequest{Background: req.Background, Biome: req.Biome, Seed: req.Seed}, assetsPath)
+
+	title := "INVENTORY"
+	if req.Name != "" {
+		title = strings.ToUpper(req.Name) + "'S BAG"
+	}
+	if face := setFont(64); face != nil {
+		drawInk(dc, face, truncateText(dc, title, CANVAS_W-80), CANVAS_W/2, 46, 0.5, 0.5, victoryColor)
+	}
+
+	drawStandingCharacter(dc, req.Class, req.SpriteIndex, req.Cosmetics, classDisplayName(req.Class, req.SpriteIndex), setFont, image.Rect(24, 110, 300, 590), assetsPath)
+	drawGoldBalance(dc, req.Gold, setFont, image.Rect(24, 604, 300, 672))
+	drawBagGrid(dc, req, setFont, image.Rect(316, 96, 1000, 672), assetsPath)
+	return dc.Image()
+}
+
+// drawBagGrid draws one page of bag slots: items with their stack size and
+// an equipped marker, then empty slots up to the bag's capacity. Items past
+// capacity still show, and the slot count turns red.
+func drawBagGrid(dc *gg.Context, req *InventoryRequest, setFont func(float64) font.Face, r image.Rectangle, assetsPath string) {
+	drawPanel(dc, r)
+	x0, y0 := float64(r.Min.X)+16, float64(r.Min.Y)+14
+	if face := setFont(24); face != nil {
+		drawInk(dc, face, "BAG", x0, y0, 0, 0, color.White)
+	}
+
+	slots := max(req.Capacity, len(req.Items))
+	page, pages, start, end := pageOf(req.Page, slots, bagCols*bagRows)
+	if face := setFont(18); face != nil {
+		used := fmt.Sprintf("%d", len(req.Items))
+		if req.Capacity > 0 {
+			used = fmt.Sprintf("%d / %d", len(req.Items), req.Capacity)
+		}
+		usedColor := color.Color(mutedColor)
+		if req.Capacity > 0 && len(req.Items) > req.Capacity {
+			usedColor = unaffordableColor
+		}
+		drawInk(dc, face, used+" slots", x0+70, y0+10, 0, 0.5, usedColor)
+		if pages > 1 {
+			drawInk(dc, face, fmt.Sprintf("Page %d / %d", page, pages), float64(r.Max.X)-16, y0+10, 1, 0.5, mutedColor)
+		}
+	}
+	if slots == 0 {
+		if face := setFont(22); face != nil {
+			drawInk(dc, face, "Empty", float64(r.Min.X+r.Max.X)/2, float64(r.Min.Y+r.Max.Y)/2, 0.5, 0.5, mutedColor)
+		}
+		return
+	}
+
+	gap := 10.0
+	top := y0 + 36
+	cellW := (float64(r.Dx()) - 32 - gap*(bagCols-1)) / bagCols
+	cellH := (float64(r.Max.Y) - 14 - top - gap*(bagRows-1)) / bagRows
+	for i := start; i < end; i++ {
+		n := i - start
+		x := x0 + float64(n%bagCols)*(cellW+gap)
+		y := top + float64(n/bagCols)*(cellH+gap)
+		if i >= len(req.Items) {
+			drawEmptySlot(dc, x, y, cellW, cellH)
+			continue
+		}
+
+		item := req.Items[i]
+		drawItemSlot(dc, x, y, cellW, cellH, rarityColor(item.Rarity))
+		icon := cellH * 0.52
+		drawItemIcon(dc, item.Item, x+cellW/2, y+10+icon/2, icon, assetsPath)
+		if face := setFont(15); face != nil {
+			drawInk(dc, face, truncateText(dc, item.Name, cellW-12), x+cellW/2, y+cellH-16, 0.5, 0.5, color.White)
+		}
+		if face := setFont(16); face != nil && item.Quantity > 1 {
+			drawInkOutlined(dc, face, fmt.Sprintf("x%d", item.Quantity), x+cellW-8, y+icon+4, 1, 1, color.White, color.Black, 1.5)
+		}
+		if item.Equipped {
+			if face := setFont(13); face != nil {
+				drawBadge(dc, face, "E", x+16, y+6, victoryColor)
+			}
+		}
+	}
+}
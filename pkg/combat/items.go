@@ -0,0 +1,279 @@
+package combat
+
+import (
+	"image"
+	"image/color"
+	"math"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"image-service/pkg/utils"
+
+	"github.com/disintegration/imaging"
+	"github.com/fogleman/gg"
+)
+
+// Item is what the shop and inventory grids show for one entry. Icon is an
+// image under rpgasset (e.g. "enemies/ice (3).png"); without one the icon is
+// drawn from the item's type.
+type Item struct {
+	Name   string `json:"name"`
+	Rarity string `json:"rarity"`
+	Type   string `json:"type"` // sword, bow, staff, shield, helm, armor, boots, ring, amulet, potion, scroll, key, gem
+	Icon   string `json:"icon"`
+}
+
+// Aliases onto the item types drawItemIcon knows
+var itemTypeAliases = map[string]string{
+	"weapon": "sword", "blade": "sword", "dagger": "sword", "axe": "sword",
+	"wand": "staff", "rod": "staff",
+	"head": "helm", "helmet": "helm", "hat": "helm",
+	"chest": "armor", "body": "armor",
+	"feet": "boots", "shoes": "boots",
+	"necklace": "amulet", "trinket": "amulet",
+	"consumable": "potion", "elixir": "potion",
+	"book": "scroll", "tome": "scroll",
+	"material": "gem", "crystal": "gem",
+}
+
+var itemIcons sync.Map // path -> image.Image, cropped to its opaque bounds
+
+// itemIconImage loads an item's asset icon. Paths that leave rpgasset or
+// don't load return nil, and the procedural icon is drawn instead.
+func itemIconImage(icon, assetsPath string) image.Image {
+	if icon == "" {
+		return nil
+	}
+	root := filepath.Join(assetsPath, "rpgasset")
+	path := filepath.Join(root, filepath.Clean("/"+icon))
+	if img, ok := itemIcons.Load(path); ok {
+		return img.(image.Image)
+	}
+	img, err := utils.LoadImage(path)
+	if err != nil {
+		return nil
+	}
+	if b := utils.OpaqueBounds(img, 16); !b.Empty() {
+		img = imaging.Crop(img, b)
+	}
+	itemIcons.Store(path, img)
+	return img
+}
+
+// drawItemIcon draws an item's icon fitted to a size x size box centred on
+// (cx, cy), tinted toward its rarity when procedural
+func drawItemIcon(dc *gg.Context, item Item, cx, cy, size float64, assetsPath string) {
+	if img := itemIconImage(item.Icon, assetsPath); img != nil {
+		fit := imaging.Fit(img, int(size), int(size), imaging.Lanczos)
+		b := fit.Bounds()
+		utils.Blit(dc, fit, int(cx)-b.Dx()/2, int(cy)-b.Dy()/2)
+		return
+	}
+
+	rc := rarityColor(item.Rarity)
+	r := size / 2
+	typ := strings.ToLower(strings.TrimSpace(item.Type))
+	if alias, ok := itemTypeAliases[typ]; ok {
+		typ = alias
+	}
+	metal := color.RGBA{210, 215, 225, 255}
+	dark := color.RGBA{40, 30, 30, 255}
+	wood := color.RGBA{140, 90, 50, 255}
+	outline := func(w float64) {
+		dc.SetColor(dark)
+		dc.SetLineWidth(w)
+		dc.Stroke()
+	}
+
+	switch typ {
+	case "sword":
+		dc.Push()
+		dc.RotateAbout(-math.Pi/4, cx, cy)
+		dc.DrawRectangle(cx-r*0.12, cy-r*0.95, r*0.24, r*1.3)
+		dc.SetColor(metal)
+		dc.FillPreserve()
+		outline(1.5)
+		dc.DrawRectangle(cx-r*0.45, cy+r*0.35, r*0.9, r*0.14)
+		dc.SetColor(rc)
+		dc.FillPreserve()
+		outline(1.5)
+		dc.DrawRectangle(cx-r*0.09, cy+r*0.49, r*0.18, r*0.45)
+		dc.SetColor(wood)
+		dc.Fill()
+		dc.Pop()
+	case "bow":
+		dc.DrawArc(cx-r*0.3, cy, r*0.85, -math.Pi*0.4, math.Pi*0.4)
+		dc.SetColor(wood)
+		dc.SetLineWidth(r * 0.18)
+		dc.Stroke()
+		top := gg.Point{X: cx - r*0.3 + math.Cos(-math.Pi*0.4)*r*0.85, Y: cy + math.Sin(-math.Pi*0.4)*r*0.85}
+		dc.DrawLine(top.X, top.Y, top.X, 2*cy-top.Y)
+		dc.SetColor(rc)
+		dc.SetLineWidth(2)
+		dc.Stroke()
+	case "staff":
+		dc.DrawLine(cx-r*0.55, cy+r*0.9, cx+r*0.35, cy-r*0.5)
+		dc.SetColor(wood)
+		dc.SetLineWidth(r * 0.18)
+		dc.Stroke()
+		dc.DrawCircle(cx+r*0.45, cy-r*0.62, r*0.3)
+		dc.SetColor(rc)
+		dc.FillPreserve()
+		outline(1.5)
+	case "shield":
+		dc.MoveTo(cx-r*0.75, cy-r*0.8)
+		dc.LineTo(cx+r*0.75, cy-r*0.8)
+		dc.QuadraticTo(cx+r*0.75, cy+r*0.4, cx, cy+r*0.95)
+		dc.QuadraticTo(cx-r*0.75, cy+r*0.4, cx-r*0.75, cy-r*0.8)
+		dc.SetColor(rc)
+		dc.FillPreserve()
+		outline(2)
+		dc.DrawLine(cx, cy-r*0.8, cx, cy+r*0.9)
+		dc.DrawLine(cx-r*0.72, cy-r*0.2, cx+r*0.72, cy-r*0.2)
+		dc.SetColor(metal)
+		dc.SetLineWidth(r * 0.12)
+		dc.Stroke()
+	case "helm":
+		dc.DrawArc(cx, cy+r*0.2, r*0.75, math.Pi, 2*math.Pi)
+		dc.LineTo(cx+r*0.75, cy+r*0.7)
+		dc.LineTo(cx-r*0.75, cy+r*0.7)
+		dc.ClosePath()
+		dc.SetColor(metal)
+		dc.FillPreserve()
+		outline(2)
+		dc.DrawRectangle(cx-r*0.5, cy+r*0.1, r, r*0.16)
+		dc.SetColor(dark)
+		dc.Fill()
+		dc.DrawLine(cx, cy-r*0.55, cx, cy-r*0.9)
+		dc.SetColor(rc)
+		dc.SetLineWidth(r * 0.2)
+		dc.Stroke()
+	case "armor":
+		dc.MoveTo(cx-r*0.35, cy-r*0.8)
+		dc.LineTo(cx-r*0.85, cy-r*0.55)
+		dc.LineTo(cx-r*0.7, cy-r*0.1)
+		dc.LineTo(cx-r*0.55, cy-r*0.2)
+		dc.LineTo(cx-r*0.55, cy+r*0.85)
+		dc.LineTo(cx+r*0.55, cy+r*0.85)
+		dc.LineTo(cx+r*0.55, cy-r*0.2)
+		dc.LineTo(cx+r*0.7, cy-r*0.1)
+		dc.LineTo(cx+r*0.85, cy-r*0.55)
+		dc.LineTo(cx+r*0.35, cy-r*0.8)
+		dc.QuadraticTo(cx, cy-r*0.45, cx-r*0.35, cy-r*0.8)
+		dc.SetColor(rc)
+		dc.FillPreserve()
+		outline(2)
+	case "boots":
+		for _, dx := range []float64{-r * 0.45, r * 0.05} {
+			dc.MoveTo(cx+dx, cy-r*0.75)
+			dc.LineTo(cx+dx+r*0.35, cy-r*0.75)
+			dc.LineTo(cx+dx+r*0.35, cy+r*0.35)
+			dc.LineTo(cx+dx+r*0.65, cy+r*0.5)
+			dc.LineTo(cx+dx+r*0.65, cy+r*0.8)
+			dc.LineTo(cx+dx, cy+r*0.8)
+			dc.ClosePath()
+			dc.SetColor(wood)
+			dc.FillPreserve()
+			outline(1.5)
+			dc.DrawRectangle(cx+dx, cy-r*0.75, r*0.35, r*0.15)
+			dc.SetColor(rc)
+			dc.Fill()
+		}
+	case "ring":
+		dc.DrawCircle(cx, cy+r*0.15, r*0.55)
+		dc.SetColor(victoryColor)
+		dc.SetLineWidth(r * 0.2)
+		dc.Stroke()
+		drawGem(dc, cx, cy-r*0.45, r*0.35, rc)
+	case "amulet":
+		dc.DrawArc(cx, cy-r*0.4, r*0.55, math.Pi*0.15, math.Pi*0.85)
+		dc.SetColor(victoryColor)
+		dc.SetLineWidth(r * 0.08)
+		dc.Stroke()
+		dc.DrawArc(cx, cy-r*0.9, r*0.7, math.Pi*0.2, math.Pi*0.8)
+		dc.Stroke()
+		dc.DrawCircle(cx, cy+r*0.45, r*0.38)
+		dc.SetColor(victoryColor)
+		dc.Fill()
+		dc.DrawCircle(cx, cy+r*0.45, r*0.26)
+		dc.SetColor(rc)
+		dc.Fill()
+	case "potion":
+		dc.DrawCircle(cx, cy+r*0.3, r*0.6)
+		dc.SetColor(color.RGBA{220, 230, 240, 255})
+		dc.FillPreserve()
+		outline(2)
+		dc.DrawCircle(cx, cy+r*0.35, r*0.48)
+		dc.SetColor(rc)
+		dc.Fill()
+		dc.DrawRectangle(cx-r*0.18, cy-r*0.6, r*0.36, r*0.42)
+		dc.SetColor(color.RGBA{220, 230, 240, 255})
+		dc.FillPreserve()
+		outline(2)
+		dc.DrawRectangle(cx-r*0.22, cy-r*0.85, r*0.44, r*0.25)
+		dc.SetColor(wood)
+		dc.Fill()
+	case "scroll":
+		dc.DrawRectangle(cx-r*0.55, cy-r*0.65, r*1.1, r*1.3)
+		dc.SetColor(color.RGBA{235, 215, 170, 255})
+		dc.FillPreserve()
+		outline(1.5)
+		for _, y := range []float64{-r * 0.7, r * 0.7} {
+			dc.DrawRoundedRectangle(cx-r*0.7, cy+y-r*0.12, r*1.4, r*0.24, r*0.12)
+			dc.SetColor(wood)
+			dc.Fill()
+		}
+		for i := 0; i < 3; i++ {
+			y := cy - r*0.3 + float64(i)*r*0.3
+			dc.DrawLine(cx-r*0.35, y, cx+r*0.35, y)
+		}
+		dc.SetColor(rc)
+		dc.SetLineWidth(r * 0.08)
+		dc.Stroke()
+	case "key":
+		dc.DrawCircle(cx-r*0.4, cy-r*0.4, r*0.32)
+		dc.SetColor(victoryColor)
+		dc.SetLineWidth(r * 0.16)
+		dc.Stroke()
+		dc.DrawLine(cx-r*0.18, cy-r*0.18, cx+r*0.75, cy+r*0.75)
+		dc.DrawLine(cx+r*0.35, cy+r*0.35, cx+r*0.15, cy+r*0.55)
+		dc.DrawLine(cx+r*0.6, cy+r*0.6, cx+r*0.4, cy+r*0.8)
+		dc.Stroke()
+		dc.DrawCircle(cx-r*0.4, cy-r*0.4, r*0.12)
+		dc.SetColor(rc)
+		dc.Fill()
+	default:
+		drawGem(dc, cx, cy, r*0.85, rc)
+	}
+}
+
+// drawItemSlot draws the rarity-bordered square an item sits in
+func drawItemSlot(dc *gg.Context, x, y, w, h float64, rc color.RGBA) {
+	dc.DrawRoundedRectangle(x, y, w, h, 8)
+	dc.SetColor(color.NRGBA{rc.R / 5, rc.G / 5, rc.B / 5, 220})
+	dc.FillPreserve()
+	dc.SetColor(rc)
+	dc.SetLineWidth(3)
+	dc.Stroke()
+}
+
+// drawEmptySlot draws an unused bag slot
+func drawEmptySlot(dc *gg.Context, x, y, w, h float64) {
+	dc.DrawRoundedRectangle(x, y, w, h, 8)
+	dc.SetColor(color.NRGBA{0, 0, 0, 90})
+	dc.FillPreserve()
+	dc.SetColor(color.NRGBA{255, 255, 255, 35})
+	dc.SetLineWidth(2)
+	dc.Stroke()
+}
+
+// pageOf clamps a 1-based page into range and returns it with the page
+// count and the [start, end) of its entries
+func pageOf(page, total, perPage int) (p, pages, start, end int) {
+	pages = max(1, (total+perPage-1)/perPage)
+	p = min(max(page, 1), pages)
+	start = (p - 1) * perPage
+	end = min(start+perPage, total)
+	return p, pages, start, end
+}
@@ -0,0 +1,54 @@
+package combat
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestPageOf(t *testing.T) {
+	tests := []struct {
+		page, total, perPage int
+		p, pages, start, end int
+	}{
+		{1, 0, 12, 1, 1, 0, 0},
+		{1, 5, 12, 1, 1, 0, 5},
+		{1, 12, 12, 1, 1, 0, 12},
+		{2, 13, 12, 2, 2, 12, 13},
+		{0, 30, 12, 1, 3, 0, 12},
+		{-4, 30, 12, 1, 3, 0, 12},
+		{3, 30, 12, 3, 3, 24, 30},
+		{9, 30, 12, 3, 3, 24, 30},
+	}
+	for _, tt := range tests {
+		p, pages, start, end := pageOf(tt.page, tt.total, tt.perPage)
+		if p != tt.p || pages != tt.pages || start != tt.start || end != tt.end {
+			t.Errorf("pageOf(%d, %d, %d) = %d, %d, %d, %d; want %d, %d, %d, %d",
+				tt.page, tt.total, tt.perPage, p, pages, start, end, tt.p, tt.pages, tt.start, tt.end)
+		}
+	}
+}
+
+func TestItemIconImage(t *testing.T) {
+	assets := t.TempDir()
+	writePNG(t, filepath.Join(assets, "rpgasset", "icons", "gem.png"), 12, 12)
+	writePNG(t, filepath.Join(assets, "rpgasset", "top.png"), 8, 8)
+	writePNG(t, filepath.Join(assets, "secret.png"), 8, 8)
+
+	tests := []struct {
+		icon  string
+		found bool
+	}{
+		{"icons/gem.png", true},
+		{"/icons/gem.png", true},
+		{"icons/../top.png", true},
+		{"../secret.png", false},
+		{"../../secret.png", false},
+		{"icons/missing.png", false},
+		{"", false},
+	}
+	for _, tt := range tests {
+		if img := itemIconImage(tt.icon, assets); (img != nil) != tt.found {
+			t.Errorf("itemIconImage(%q) = %v; want found %v", tt.icon, img != nil, tt.found)
+		}
+	}
+}
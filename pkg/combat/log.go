@@ -0,0 +1,199 @@
+package combat
+
+import (
+	"bytes"
+	"encoding/json"
+	"image"
+	"image/color"
+	"math"
+	"strings"
+	"unicode"
+
+	"image-service/pkg/utils"
+
+	"github.com/fogleman/gg"
+)
+
+// LogSegment is a run of log text. Kind ("damage", "heal", "crit", "miss")
+// colors it from the theme; Color is an explicit hex override.
+type LogSegment struct {
+	Text  string `json:"text"`
+	Kind  string `json:"kind"`
+	Color string `json:"color"`
+}
+
+// LogLine is one combat log entry. The bot may send a plain string, a single
+// segment or a list of segments.
+type LogLine []LogSegment
+
+// UnmarshalJSON accepts a string, a segment object or an array of segments
+func (l *LogLine) UnmarshalJSON(data []byte) error {
+	data = bytes.TrimSpace(data)
+	switch {
+	case bytes.HasPrefix(data, []byte(`"`)):
+		var text string
+		if err := json.Unmarshal(data, &text); err != nil {
+			return err
+		}
+		*l = LogLine{{Text: text}}
+	case bytes.HasPrefix(data, []byte("[")):
+		var segs []LogSegment
+		if err := json.Unmarshal(data, &segs); err != nil {
+			return err
+		}
+		*l = segs
+	case bytes.Equal(data, []byte("null")):
+		*l = nil
+	default:
+		var seg LogSegment
+		if err := json.Unmarshal(data, &seg); err != nil {
+			return err
+		}
+		*l = LogLine{seg}
+	}
+	return nil
+}
+
+// logRun is a piece of a wrapped row drawn in one color
+type logRun struct {
+	text  string
+	color color.Color
+}
+
+// logPanel renders the most recent log lines onto the theme's log panel.
+// Returns nil when there's no log or the theme doesn't place one.
+func (sc *combatScene) logPanel() (image.Image, Box) {
+	style := sc.theme.Log
+	box := style.Box
+	if sc.duel {
+		box = style.duelBox()
+	}
+	lines := sc.req.Log
+	if style.Lines <= 0 || len(lines) == 0 || box.W <= 0 || box.H <= 0 ||
+		(style.Show == "battle" && sc.duel) || (style.Show == "duel" && !sc.duel) {
+		return nil, box
+	}
+	if len(lines) > style.Lines {
+		lines = lines[len(lines)-style.Lines:]
+	}
+
+	pad := float64(style.Padding)
+	width, height := float64(box.W)-pad*2, float64(box.H)-pad*2
+	size := math.Max(12, style.Size)
+	minSize := math.Max(12, style.MinSize)
+	if minSize > size {
+		minSize = size
+	}
+
+	panel := style.Panel
+	panel.Box = box
+	dc := gg.NewContext(box.W, box.H)
+	if bg := sc.panelImage(panel, 1); bg != nil {
+		utils.Blit(dc, bg, 0, 0)
+	}
+
+	// Shrink the font until every wrapped row fits, then keep the newest rows
+	var rows [][]logRun
+	for {
+		face, err := sc.face(size)
+		if err != nil {
+			return nil, box
+		}
+		dc.SetFontFace(face)
+		rows = sc.wrapLog(dc, lines, width)
+		if float64(len(rows))*size*1.2 <= height || size <= minSize {
+			break
+		}
+		size = math.Max(minSize, size-1)
+	}
+	lineH := size * 1.2
+	if fit := int(height / lineH); len(rows) > fit {
+		rows = rows[len(rows)-max(fit, 0):]
+	}
+
+	for i, row := range rows {
+		x := pad
+		y := pad + float64(i)*lineH + size*0.9
+		for _, run := range row {
+			dc.SetColor(color.RGBA{0, 0, 0, 180})
+			dc.DrawString(run.text, x+1, y+1)
+			dc.SetColor(run.color)
+			dc.DrawString(run.text, x, y)
+			w, _ := dc.MeasureString(run.text)
+			x += w
+		}
+	}
+	return dc.Image(), box
+}
+
+// logColor is a segment's explicit color, else its kind's theme color
+func (sc *combatScene) logColor(seg LogSegment) color.Color {
+	style := sc.theme.Log
+	if strings.HasPrefix(seg.Color, "#") {
+		return utils.ParseHexColor(seg.Color)
+	}
+	kinds := map[string]string{
+		"damage": style.Damage, "heal": style.Heal, "crit": style.Crit, "miss": style.Miss,
+	}
+	return themeColor(kinds[strings.ToLower(seg.Kind)], themeColor(style.Color, color.White))
+}
+
+// wrapLog word-wraps each line to width, keeping segment colors. A word too
+// wide for a row on its own is cut with "...".
+func (sc *combatScene) wrapLog(dc *gg.Context, lines []LogLine, width float64) [][]logRun {
+	measure := func(text string) float64 {
+		w, _ := dc.MeasureString(text)
+		return w
+	}
+	var rows [][]logRun
+	for _, line := range lines {
+		var row []logRun
+		rowW := 0.0
+		add := func(text string, c color.Color) {
+			if n := len(row); n > 0 && row[n-1].color == c {
+				row[n-1].text += text
+			} else {
+				row = append(row, logRun{text, c})
+			}
+			rowW += measure(text)
+		}
+		for _, seg := range line {
+			c := sc.logColor(seg)
+			for _, word := range splitWords(seg.Text) {
+				w := measure(word)
+				if rowW+w > width && len(row) > 0 {
+					rows = append(rows, row)
+					row, rowW = nil, 0
+				}
+				if len(row) == 0 {
+					word = strings.TrimLeftFunc(word, unicode.IsSpace)
+					if word == "" {
+						continue
+					}
+					word = truncateText(dc, word, width)
+				}
+				add(word, c)
+			}
+		}
+		if len(row) > 0 {
+			rows = append(rows, row)
+		}
+	}
+	return rows
+}
+
+// splitWords splits text into words, each carrying the spaces before it
+func splitWords(text string) []string {
+	var words []string
+	start := 0
+	for i, r := range text {
+		if unicode.IsSpace(r) && i > start && !unicode.IsSpace(rune(text[i-1])) {
+			words = append(words, text[start:i])
+			start = i
+		}
+	}
+	if start < len(text) {
+		words = append(words, text[start:])
+	}
+	return words
+}
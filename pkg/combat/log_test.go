@@ -0,0 +1,96 @@
+package combat
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/fogleman/gg"
+)
+
+func TestLogLineJSON(t *testing.T) {
+	tests := []struct {
+		data string
+		want LogLine
+		ok   bool
+	}{
+		{`"Ann hits Bat"`, LogLine{{Text: "Ann hits Bat"}}, true},
+		{`{"text": "12", "kind": "damage"}`, LogLine{{Text: "12", Kind: "damage"}}, true},
+		{` [{"text": "Ann hits "}, {"text": "12", "color": "#ff0000"}]`, LogLine{{Text: "Ann hits "}, {Text: "12", Color: "#ff0000"}}, true},
+		{`[]`, LogLine{}, true},
+		{`null`, nil, true},
+		{`12`, nil, false},
+		{`["Ann"]`, nil, false},
+	}
+	for _, tt := range tests {
+		var l LogLine
+		err := json.Unmarshal([]byte(tt.data), &l)
+		if (err == nil) != tt.ok {
+			t.Errorf("%s: error %v; want ok %v", tt.data, err, tt.ok)
+			continue
+		}
+		if tt.ok && !reflect.DeepEqual(l, tt.want) {
+			t.Errorf("%s: %+v; want %+v", tt.data, l, tt.want)
+		}
+	}
+}
+
+func TestSplitWords(t *testing.T) {
+	tests := []struct {
+		text string
+		want []string
+	}{
+		{"Ann hits Bat", []string{"Ann", " hits", " Bat"}},
+		{"  two  spaces ", []string{"  two", "  spaces", " "}},
+		{"one", []string{"one"}},
+		{"", nil},
+	}
+	for _, tt := range tests {
+		if got := splitWords(tt.text); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("splitWords(%q) = %q; want %q", tt.text, got, tt.want)
+		}
+	}
+}
+
+func TestWrapLog(t *testing.T) {
+	th := &Theme{}
+	th.Log.Color, th.Log.Damage = "#ffffff", "#ff0000"
+	sc := &combatScene{theme: th}
+	names := map[any]string{
+		sc.logColor(LogSegment{}):               "",
+		sc.logColor(LogSegment{Kind: "damage"}): "red:",
+	}
+
+	// The default face is 7 px per character, so 70 px holds 10
+	dc := gg.NewContext(10, 10)
+	tests := []struct {
+		name  string
+		lines []LogLine
+		width float64
+		want  string // Rows split by |, runs by +
+	}{
+		{"fits", []LogLine{{{Text: "Ann hits"}}}, 70, "Ann hits"},
+		{"wraps", []LogLine{{{Text: "Ann hits Bat"}}}, 70, "Ann hits|Bat"},
+		{"one row per line", []LogLine{{{Text: "Ann"}}, {{Text: "Bo"}}}, 70, "Ann|Bo"},
+		{"segments", []LogLine{{{Text: "Bat takes "}, {Text: "12", Kind: "DAMAGE"}}}, 100, "Bat takes +red:12"},
+		{"segment wraps", []LogLine{{{Text: "Bat takes "}, {Text: "12 damage", Kind: "damage"}}}, 70, "Bat takes |red:12 damage"},
+		{"same color joins", []LogLine{{{Text: "Ann "}, {Text: "hits"}}}, 70, "Ann hits"},
+		{"long word", []LogLine{{{Text: "Supercalifragilistic"}}}, 70, "Superca..."},
+		{"long word later", []LogLine{{{Text: "Ann Supercalifragilistic"}}}, 70, "Ann|Superca..."},
+		{"blank", []LogLine{{{Text: "   "}}}, 70, ""},
+	}
+	for _, tt := range tests {
+		var rows []string
+		for _, row := range sc.wrapLog(dc, tt.lines, tt.width) {
+			var runs []string
+			for _, r := range row {
+				runs = append(runs, names[r.color]+r.text)
+			}
+			rows = append(rows, strings.Join(runs, "+"))
+		}
+		if got := strings.Join(rows, "|"); got != tt.want {
+			t.Errorf("%s: %q; want %q", tt.name, got, tt.want)
+		}
+	}
+}
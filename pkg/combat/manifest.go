@@ -0,0 +1,402 @@
+package combat
+
+import (
+	"bytes"
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SpriteMeta describes one sprite file. Empty fields inherit from the group.
+type SpriteMeta struct {
+	File        string   `json:"file"`
+	DisplayName string   `json:"displayName,omitempty"`
+	Facing      string   `json:"facing,omitempty"` // "left", "right" or "front"
+	Tags        []string `json:"tags,omitempty"`
+
+	// Anchors place cosmetic layers on this sprite, keyed by slot, and "face"
+	// centers the HUD portrait. Missing ones are guessed from the art.
+	Anchors map[string]LayerAnchor `json:"anchors,omitempty"`
+}
+
+// LayerAnchor is where a cosmetic slot attaches to a sprite, as fractions of
+// the sprite's width and height. Z overrides the cosmetic's draw order.
+type LayerAnchor struct {
+	X float64 `json:"x"`
+	Y float64 `json:"y"`
+	Z *int    `json:"z,omitempty"`
+}
+
+// UnmarshalJSON accepts either a bare filename or a full sprite object
+func (s *SpriteMeta) UnmarshalJSON(data []byte) error {
+	if bytes.HasPrefix(bytes.TrimSpace(data), []byte(`"`)) {
+		return json.Unmarshal(data, &s.File)
+	}
+	type plain SpriteMeta
+	return json.Unmarshal(data, (*plain)(s))
+}
+
+// SpriteGroup is a class, enemy group or boss tier. An alias reuses another
+// group's sprites in the same section under its own name and tags.
+type SpriteGroup struct {
+	DisplayName string       `json:"displayName,omitempty"`
+	Facing      string       `json:"facing,omitempty"`
+	Tags        []string     `json:"tags,omitempty"`
+	AliasOf     string       `json:"aliasOf,omitempty"`
+	Sprites     []SpriteMeta `json:"sprites,omitempty"`
+
+	Anchors map[string]LayerAnchor `json:"anchors,omitempty"`
+
+	// Classes only: the class this one is promoted from and the level it
+	// takes, which /api/combat/class-tree draws as the promotion tree
+	EvolvesFrom string `json:"evolvesFrom,omitempty"`
+	EvolveLevel int    `json:"evolveLevel,omitempty"`
+}
+
+// Cosmetic is an equippable layer drawn over a character sprite. File is
+// relative to the rpgasset folder, so a pet can reuse an enemy sprite.
+type Cosmetic struct {
+	DisplayName string       `json:"displayName,omitempty"`
+	Slot        string       `json:"slot"` // weapon, frame or pet
+	File        string       `json:"file"`
+	Facing      string       `json:"facing,omitempty"` // Mirrored to match the character when it differs
+	Pivot       *LayerAnchor `json:"pivot,omitempty"`  // Point on the layer that sits on the sprite's anchor
+	Scale       float64      `json:"scale,omitempty"`  // Width as a fraction of the character's art width
+	Z           *int         `json:"z,omitempty"`      // Draw order; the base sprite is 0 and negative draws behind
+}
+
+// Manifest is the sprite table loaded from assets/rpgasset/manifest.json.
+// Environments are biomes; their tags name the enemy elements they suit.
+type Manifest struct {
+	Version      int                     `json:"version"`
+	Characters   map[string]*SpriteGroup `json:"characters"`
+	Enemies      map[string]*SpriteGroup `json:"enemies"`
+	Bosses       map[string]*SpriteGroup `json:"bosses"`
+	Environments map[string]*SpriteGroup `json:"environments"`
+	Cosmetics    map[string]*Cosmetic    `json:"cosmetics"`
+}
+
+// Groups the renderer falls back to, which every manifest must define
+var (
+	requiredCharacters = []string{"FIGHTER"}
+	requiredEnemies    = []string{"FIRE_LOW", "WATER_LOW", "EARTH_MID", "ICE_MID", "FIRE_HIGH", "WATER_HIGH", "EARTH_HIGH", "MUTATED", "HYBRID", "FIRE_ELITE"}
+	requiredBosses     = []string{"MID_BOSSES", "HIGH_BOSSES", "CALAMITY"}
+)
+
+var (
+	manifestMu      sync.RWMutex
+	currentManifest = &Manifest{}
+	manifestPath    string
+	manifestModTime time.Time
+)
+
+// Sprites returns the active manifest. It is replaced, never mutated, on reload.
+func Sprites() *Manifest {
+	manifestMu.RLock()
+	defer manifestMu.RUnlock()
+	return currentManifest
+}
+
+// LoadManifest parses and validates the manifest at path and makes it active.
+// On error the previously loaded manifest stays in place.
+func LoadManifest(path string) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	m := &Manifest{}
+	if err := json.Unmarshal(data, m); err != nil {
+		return fmt.Errorf("parse %s: %w", path, err)
+	}
+	if err := m.resolve(filepath.Dir(path)); err != nil {
+		return fmt.Errorf("validate %s: %w", path, err)
+	}
+
+	manifestMu.Lock()
+	currentManifest = m
+	manifestPath = path
+	manifestModTime = info.ModTime()
+	manifestMu.Unlock()
+	clearSpriteCaches()
+
+	log.Printf("🗂️ Loaded sprite manifest: %d classes, %d enemy groups, %d boss tiers, %d biomes",
+		len(m.Characters), len(m.Enemies), len(m.Bosses), len(m.Environments))
+	return nil
+}
+
+// WatchManifest polls the loaded manifest file and reloads it when it changes
+func WatchManifest(interval time.Duration) {
+	var lastTried time.Time
+	for range time.Tick(interval) {
+		manifestMu.RLock()
+		path, mod := manifestPath, manifestModTime
+		manifestMu.RUnlock()
+		if path == "" {
+			continue
+		}
+
+		info, err := os.Stat(path)
+		if err != nil || !info.ModTime().After(mod) || info.ModTime().Equal(lastTried) {
+			continue
+		}
+		lastTried = info.ModTime()
+		if err := LoadManifest(path); err != nil {
+			log.Printf("⚠️ Sprite manifest reload failed, keeping previous: %v", err)
+		}
+	}
+}
+
+// ReloadManifest is the admin endpoint that re-reads the manifest from disk.
+// Callers must send ADMIN_TOKEN in X-Admin-Token; without one set, nobody can.
+func ReloadManifest(c *gin.Context) {
+	token := os.Getenv("ADMIN_TOKEN")
+	if token == "" || subtle.ConstantTimeCompare([]byte(c.GetHeader("X-Admin-Token")), []byte(token)) != 1 {
+		c.JSON(401, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	manifestMu.RLock()
+	path := manifestPath
+	manifestMu.RUnlock()
+	if path == "" {
+		c.JSON(500, gin.H{"error": "No manifest loaded"})
+		return
+	}
+
+	if err := LoadManifest(path); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+
+	m := Sprites()
+	c.JSON(200, gin.H{
+		"status":     "reloaded",
+		"characters": len(m.Characters),
+		"enemies":    len(m.Enemies),
+		"bosses":     len(m.Bosses),
+		"biomes":     len(m.Environments),
+	})
+}
+
+// resolve expands aliases, fills per-sprite defaults from their group and
+// checks every referenced file exists under baseDir
+func (m *Manifest) resolve(baseDir string) error {
+	sections := []struct {
+		name     string
+		groups   map[string]*SpriteGroup
+		dir      string
+		required []string
+	}{
+		{"characters", m.Characters, "characters", requiredCharacters},
+		{"enemies", m.Enemies, "enemies", requiredEnemies},
+		{"bosses", m.Bosses, "enemies", requiredBosses},
+		{"environments", m.Environments, "environment", nil},
+	}
+
+	// Null entries can't be checked any further
+	var problems []string
+	for _, sec := range sections {
+		for _, key := range sortedGroupKeys(sec.groups) {
+			if sec.groups[key] == nil {
+				problems = append(problems, fmt.Sprintf("%s.%s: is null", sec.name, key))
+			}
+		}
+	}
+	for _, key := range sortedGroupKeys(m.Cosmetics) {
+		if m.Cosmetics[key] == nil {
+			problems = append(problems, fmt.Sprintf("cosmetics.%s: is null", key))
+		}
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf("%d problem(s):\n  %s", len(problems), strings.Join(problems, "\n  "))
+	}
+
+	for _, sec := range sections {
+		for _, key := range sec.required {
+			if _, ok := sec.groups[key]; !ok {
+				problems = append(problems, fmt.Sprintf("%s: missing required group %s", sec.name, key))
+			}
+		}
+
+		for _, key := range sortedGroupKeys(sec.groups) {
+			g := sec.groups[key]
+			if key != strings.ToUpper(key) {
+				problems = append(problems, fmt.Sprintf("%s.%s: keys must be upper case", sec.name, key))
+			}
+
+			if g.AliasOf != "" {
+				target, ok := sec.groups[g.AliasOf]
+				if !ok || target.AliasOf != "" {
+					problems = append(problems, fmt.Sprintf("%s.%s: aliasOf %q is not a sprite group", sec.name, key, g.AliasOf))
+					continue
+				}
+				if len(g.Sprites) > 0 {
+					problems = append(problems, fmt.Sprintf("%s.%s: an alias can't list its own sprites", sec.name, key))
+				}
+				continue
+			}
+
+			if len(g.Sprites) == 0 {
+				problems = append(problems, fmt.Sprintf("%s.%s: no sprites", sec.name, key))
+			}
+			problems = append(problems, checkAnchors(fmt.Sprintf("%s.%s", sec.name, key), g.Anchors)...)
+			for i := range g.Sprites {
+				s := &g.Sprites[i]
+				if s.DisplayName == "" {
+					s.DisplayName = g.DisplayName
+				}
+				if s.Facing == "" {
+					s.Facing = g.Facing
+				}
+				if s.Tags == nil {
+					s.Tags = g.Tags
+				}
+				problems = append(problems, checkAnchors(fmt.Sprintf("%s.%s: sprite %d", sec.name, key, i), s.Anchors)...)
+				for slot, a := range g.Anchors {
+					if _, ok := s.Anchors[slot]; !ok {
+						if s.Anchors == nil {
+							s.Anchors = make(map[string]LayerAnchor)
+						}
+						s.Anchors[slot] = a
+					}
+				}
+
+				switch s.Facing {
+				case "", "left", "right", "front":
+				default:
+					problems = append(problems, fmt.Sprintf("%s.%s: invalid facing %q", sec.name, key, s.Facing))
+				}
+				if s.File == "" {
+					problems = append(problems, fmt.Sprintf("%s.%s: sprite %d has no file", sec.name, key, i))
+				} else if !fileExists(filepath.Join(baseDir, sec.dir, s.File)) {
+					problems = append(problems, fmt.Sprintf("%s.%s: %s not found", sec.name, key, s.File))
+				}
+			}
+		}
+
+		// Aliases share the target's files but keep their own name and tags
+		for _, g := range sec.groups {
+			if g.AliasOf == "" {
+				continue
+			}
+			target, ok := sec.groups[g.AliasOf]
+			if !ok {
+				continue
+			}
+			for _, s := range target.Sprites {
+				if g.DisplayName != "" {
+					s.DisplayName = g.DisplayName
+				}
+				if g.Tags != nil {
+					s.Tags = g.Tags
+				}
+				g.Sprites = append(g.Sprites, s)
+			}
+			if g.Facing == "" {
+				g.Facing = target.Facing
+			}
+		}
+	}
+
+	problems = append(problems, checkEvolutions(m.Characters)...)
+
+	for _, key := range sortedGroupKeys(m.Cosmetics) {
+		c := m.Cosmetics[key]
+		name := "cosmetics." + key
+		if key != strings.ToUpper(key) {
+			problems = append(problems, name+": keys must be upper case")
+		}
+		if _, ok := cosmeticSlots[c.Slot]; !ok || c.Slot == "aura" {
+			problems = append(problems, fmt.Sprintf("%s: invalid slot %q", name, c.Slot))
+		}
+		switch c.Facing {
+		case "", "left", "right", "front":
+		default:
+			problems = append(problems, fmt.Sprintf("%s: invalid facing %q", name, c.Facing))
+		}
+		if c.File == "" {
+			problems = append(problems, name+": no file")
+		} else if !fileExists(filepath.Join(baseDir, c.File)) {
+			problems = append(problems, fmt.Sprintf("%s: %s not found", name, c.File))
+		}
+		if c.Scale < 0 {
+			problems = append(problems, name+": scale can't be negative")
+		}
+	}
+
+	if len(problems) > 0 {
+		return fmt.Errorf("%d problem(s):\n  %s", len(problems), strings.Join(problems, "\n  "))
+	}
+	return nil
+}
+
+// checkEvolutions reports promotions from unknown classes and loops
+func checkEvolutions(classes map[string]*SpriteGroup) []string {
+	var problems []string
+	for _, key := range sortedGroupKeys(classes) {
+		g := classes[key]
+		if g.EvolvesFrom == "" {
+			if g.EvolveLevel != 0 {
+				problems = append(problems, fmt.Sprintf("characters.%s: evolveLevel without evolvesFrom", key))
+			}
+			continue
+		}
+		if _, ok := classes[g.EvolvesFrom]; !ok {
+			problems = append(problems, fmt.Sprintf("characters.%s: evolvesFrom %q is not a class", key, g.EvolvesFrom))
+			continue
+		}
+		if g.EvolveLevel < 0 {
+			problems = append(problems, fmt.Sprintf("characters.%s: evolveLevel can't be negative", key))
+		}
+		seen := map[string]bool{key: true}
+		for from := g.EvolvesFrom; from != ""; from = classes[from].EvolvesFrom {
+			if seen[from] {
+				problems = append(problems, fmt.Sprintf("characters.%s: evolvesFrom loops back through %s", key, from))
+				break
+			}
+			seen[from] = true
+			if _, ok := classes[from]; !ok {
+				break
+			}
+		}
+	}
+	return problems
+}
+
+// checkAnchors reports anchors for unknown slots or outside the sprite
+func checkAnchors(name string, anchors map[string]LayerAnchor) []string {
+	var problems []string
+	for _, slot := range sortedGroupKeys(anchors) {
+		a := anchors[slot]
+		if _, ok := cosmeticSlots[slot]; !ok && slot != "face" {
+			problems = append(problems, fmt.Sprintf("%s: unknown anchor %q", name, slot))
+		}
+		if a.X < 0 || a.X > 1 || a.Y < 0 || a.Y > 1 {
+			problems = append(problems, fmt.Sprintf("%s: anchor %q must be within 0-1", name, slot))
+		}
+	}
+	return problems
+}
+
+func sortedGroupKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
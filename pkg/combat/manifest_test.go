@@ -0,0 +1,136 @@
+package combat
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// testManifest is the smallest manifest that resolves: every required group
+// with one sprite, and the sprite files in a temporary rpgasset folder
+func testManifest(t *testing.T) (*Manifest, string) {
+	t.Helper()
+	base := t.TempDir()
+	for _, f := range []string{"characters/fighter.png", "characters/mage.png", "enemies/slime.png"} {
+		path := filepath.Join(base, f)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, nil, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	group := func(file string) *SpriteGroup {
+		return &SpriteGroup{Facing: "left", Sprites: []SpriteMeta{{File: file}}}
+	}
+	m := &Manifest{
+		Characters: map[string]*SpriteGroup{"FIGHTER": group("fighter.png"), "MAGE": group("mage.png")},
+		Enemies:    map[string]*SpriteGroup{},
+		Bosses:     map[string]*SpriteGroup{},
+	}
+	for _, key := range requiredEnemies {
+		m.Enemies[key] = group("slime.png")
+	}
+	for _, key := range requiredBosses {
+		m.Bosses[key] = group("slime.png")
+	}
+	return m, base
+}
+
+func TestManifestAliases(t *testing.T) {
+	m, base := testManifest(t)
+	m.Characters["MAGE"].Tags = []string{"caster"}
+	m.Characters["WIZARD"] = &SpriteGroup{AliasOf: "MAGE", DisplayName: "Wizard"}
+	m.Characters["SORCERER"] = &SpriteGroup{AliasOf: "MAGE", Tags: []string{"dark"}}
+	if err := m.resolve(base); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		key         string
+		displayName string
+		tags        []string
+	}{
+		{"MAGE", "", []string{"caster"}},
+		{"WIZARD", "Wizard", []string{"caster"}},
+		{"SORCERER", "", []string{"dark"}},
+	}
+	for _, tt := range tests {
+		g := m.Characters[tt.key]
+		if len(g.Sprites) != 1 || g.Facing != "left" {
+			t.Errorf("%s: %d sprites facing %q; want the mage's one facing left", tt.key, len(g.Sprites), g.Facing)
+			continue
+		}
+		s := g.Sprites[0]
+		if s.File != "mage.png" || s.DisplayName != tt.displayName || strings.Join(s.Tags, ",") != strings.Join(tt.tags, ",") {
+			t.Errorf("%s: sprite %+v; want mage.png named %q tagged %v", tt.key, s, tt.displayName, tt.tags)
+		}
+	}
+	if len(m.Characters["MAGE"].Sprites) != 1 {
+		t.Errorf("aliases added sprites to their target: %+v", m.Characters["MAGE"].Sprites)
+	}
+}
+
+func TestManifestProblems(t *testing.T) {
+	tests := []struct {
+		name  string
+		edit  func(m *Manifest)
+		wants []string
+	}{
+		{"valid", func(m *Manifest) {}, nil},
+		{"null group", func(m *Manifest) { m.Enemies["SLIME"] = nil }, []string{"enemies.SLIME: is null"}},
+		{"null cosmetic", func(m *Manifest) { m.Cosmetics = map[string]*Cosmetic{"HAT": nil} }, []string{"cosmetics.HAT: is null"}},
+		{"missing required", func(m *Manifest) { delete(m.Bosses, "CALAMITY") }, []string{"missing required group CALAMITY"}},
+		{"alias of nothing", func(m *Manifest) {
+			m.Characters["WIZARD"] = &SpriteGroup{AliasOf: "SAGE"}
+		}, []string{`characters.WIZARD: aliasOf "SAGE" is not a sprite group`}},
+		{"alias of an alias", func(m *Manifest) {
+			m.Characters["WIZARD"] = &SpriteGroup{AliasOf: "MAGE"}
+			m.Characters["WARLOCK"] = &SpriteGroup{AliasOf: "WIZARD"}
+		}, []string{`characters.WARLOCK: aliasOf "WIZARD" is not a sprite group`}},
+		{"alias with sprites", func(m *Manifest) {
+			m.Characters["WIZARD"] = &SpriteGroup{AliasOf: "MAGE", Sprites: []SpriteMeta{{File: "mage.png"}}}
+		}, []string{"characters.WIZARD: an alias can't list its own sprites"}},
+		{"promotion", func(m *Manifest) {
+			m.Characters["MAGE"].EvolvesFrom, m.Characters["MAGE"].EvolveLevel = "FIGHTER", 10
+		}, nil},
+		{"promotion from nothing", func(m *Manifest) {
+			m.Characters["MAGE"].EvolvesFrom = "SAGE"
+		}, []string{`characters.MAGE: evolvesFrom "SAGE" is not a class`}},
+		{"negative level", func(m *Manifest) {
+			m.Characters["MAGE"].EvolvesFrom, m.Characters["MAGE"].EvolveLevel = "FIGHTER", -1
+		}, []string{"characters.MAGE: evolveLevel can't be negative"}},
+		{"level without a parent", func(m *Manifest) {
+			m.Characters["MAGE"].EvolveLevel = 10
+		}, []string{"characters.MAGE: evolveLevel without evolvesFrom"}},
+		{"promotion loop", func(m *Manifest) {
+			m.Characters["MAGE"].EvolvesFrom = "FIGHTER"
+			m.Characters["FIGHTER"].EvolvesFrom = "MAGE"
+		}, []string{
+			"characters.FIGHTER: evolvesFrom loops back through FIGHTER",
+			"characters.MAGE: evolvesFrom loops back through MAGE",
+		}},
+	}
+	for _, tt := range tests {
+		m, base := testManifest(t)
+		tt.edit(m)
+		err := m.resolve(base)
+		if tt.wants == nil {
+			if err != nil {
+				t.Errorf("%s: %v", tt.name, err)
+			}
+			continue
+		}
+		if err == nil {
+			t.Errorf("%s: resolved; want %q", tt.name, tt.wants)
+			continue
+		}
+		for _, want := range tt.wants {
+			if !strings.Contains(err.Error(), want) {
+				t.Errorf("%s: %v; want %q", tt.name, err, want)
+			}
+		}
+	}
+}
@@ -0,0 +1,341 @@
+package combat
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"math"
+	"strings"
+
+	"image-service/pkg/utils"
+
+	"github.com/disintegration/imaging"
+	"github.com/fogleman/gg"
+	"github.com/gin-gonic/gin"
+	"golang.org/x/image/font"
+)
+
+// ProfileRequest is a character sheet: the player as sent in a combat
+// request, plus progress, stats, gear and achievements
+type ProfileRequest struct {
+	Player
+	XP           int            `json:"xp"` // Progress into the current level
+	XPToNext     int            `json:"xpToNext"`
+	Stats        []ProfileStat  `json:"stats"`
+	Equipment    []EquippedItem `json:"equipment"`
+	Achievements []string       `json:"achievements"`
+
+	Theme      string `json:"theme"` // HUD theme the card and bars are drawn with
+	Background string `json:"background"`
+	Biome      string `json:"biome"`
+	Seed       Seed   `json:"seed"`
+}
+
+// ProfileStat is one stat bar. It fills against max, or against the
+// highest stat on the sheet when max is 0.
+type ProfileStat struct {
+	Name  string `json:"name"`
+	Value int    `json:"value"`
+	Max   int    `json:"max"`
+}
+
+// EquippedItem is one gear slot on the sheet
+type EquippedItem struct {
+	Slot   string `json:"slot"` // e.g. weapon, head, chest
+	Name   string `json:"name"`
+	Rarity string `json:"rarity"`
+}
+
+// Bar colors for common stats; others use statColor
+var statColors = map[string]string{
+	"str": "#E8574A", "strength": "#E8574A", "atk": "#E8574A", "attack": "#E8574A",
+	"def": "#E89B3A", "defense": "#E89B3A", "vit": "#E89B3A", "vitality": "#E89B3A",
+	"dex": "#5BCB6A", "dexterity": "#5BCB6A", "agi": "#5BCB6A", "agility": "#5BCB6A", "spd": "#5BCB6A", "speed": "#5BCB6A",
+	"int": "#5A9BFF", "intelligence": "#5A9BFF", "mag": "#5A9BFF", "magic": "#5A9BFF",
+	"wis": "#B68CFF", "wisdom": "#B68CFF", "spi": "#B68CFF", "spirit": "#B68CFF",
+	"luk": "#F5C542", "luck": "#F5C542", "cha": "#F5C542", "charisma": "#F5C542",
+}
+
+const (
+	statColor       = "#C8C8D8"
+	profileStatRows = 4 // Per column, two columns
+)
+
+func GenerateProfile(c *gin.Context) {
+	var req ProfileRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	if err := checkBackground(req.Background); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+
+	theme, err := ThemeFor(req.Theme, AspectLandscape, CANVAS_W, CANVAS_H, "assets")
+	if err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+
+	buf, err := utils.EncodeImageToBuffer(renderProfile(&req, theme, "assets"))
+	if err != nil {
+		c.JSON(500, gin.H{"error": "Failed to encode image"})
+		return
+	}
+
+	c.Data(200, "image/png", buf)
+}
+
+// renderProfile draws the character sheet: the full-body sprite on the
+// left; the theme's party card, XP, stats, gear and achievements on the right
+func renderProfile(req *ProfileRequest, theme *Theme, assetsPath string) image.Image {
+	dc := gg.NewContext(CANVAS_W, CANVAS_H)
+	sc := &combatScene{theme: theme, assetsPath: assetsPath}
+	p := req.Player
+	setFont := func(size float64) font.Face {
+		face, err := sc.face(size)
+		if err != nil {
+			return nil
+		}
+		dc.SetFontFace(face)
+		return face
+	}
+
+	drawBlurredArena(dc, &CombatRequest{Background: req.Background, Biome: req.Biome, Seed: req.Seed}, assetsPath)
+
+	// Name on the theme's banner, rank under it
+	banner := theme.Banner
+	if img := sc.panelImage(banner.Panel, 1); img != nil {
+		utils.Blit(dc, img, banner.X, banner.Y)
+	}
+	sc.drawText(dc, banner.Text, strings.ToUpper(p.Name), float64(banner.X), float64(banner.Y), 1)
+	rank := p.AdventurerRank
+	if rank == "" {
+		rank = "F"
+	}
+	if face := setFont(20); face != nil {
+		drawBadge(dc, face, rank+" RANK", 204, 100, victoryColor)
+	}
+
+	drawStandingCharacter(dc, p.Class, p.SpriteIndex, p.Cosmetics, classDisplayName(p.Class, p.SpriteIndex), setFont, image.Rect(24, 140, 384, 672), assetsPath)
+
+	// The party card as it appears in battle, without the portrait since the
+	// full sprite is right beside it
+	hp := p.CurrentHP
+	if hp == 0 && p.HP > 0 {
+		hp = p.HP
+	}
+	// A sheet without HP shows full bars rather than a KO card
+	maxHP := max(1, p.MaxHP)
+	if hp == 0 {
+		hp = maxHP
+	}
+	card := &sceneUnit{
+		name:      p.Name,
+		hp:        float64(hp),
+		maxHP:     float64(maxHP),
+		energy:    float64(p.Energy),
+		maxEnergy: float64(p.MaxEnergy),
+		level:     p.Level,
+		effects:   p.StatusEffects,
+	}
+	cardScale := math.Min(1, math.Min(410/float64(theme.Party.Card.W), 206/float64(theme.Party.Card.H)))
+	sc.drawPlayerHUD(dc, card, partySlot{x: 400, y: 102, scale: cardScale})
+
+	drawProfileXP(dc, req, setFont, image.Rect(820, 104, 1000, 300))
+	drawProfileStats(dc, req.Stats, setFont, image.Rect(400, 316, 1000, 484))
+	drawProfileGear(dc, req.Equipment, setFont, image.Rect(400, 500, 692, 672))
+	drawProfileAchievements(dc, req.Achievements, setFont, image.Rect(708, 500, 1000, 672))
+	return dc.Image()
+}
+
+// drawStandingCharacter draws a full-body character sprite, cosmetics
+// included, standing in r with a caption under it
+func drawStandingCharacter(dc *gg.Context, class string, index int, cos Cosmetics, caption string, setFont func(float64) font.Face, r image.Rectangle, assetsPath string) {
+	cx := float64(r.Min.X+r.Max.X) / 2
+	feet := float64(r.Max.Y) - 44
+	spriteH := feet - float64(r.Min.Y)
+
+	glowR := float64(r.Dx()) * 0.5
+	glow := gg.NewRadialGradient(cx, feet-spriteH/2, 0, cx, feet-spriteH/2, glowR)
+	glow.AddColorStop(0, color.NRGBA{255, 255, 255, 40})
+	glow.AddColorStop(1, color.NRGBA{255, 255, 255, 0})
+	dc.SetFillStyle(glow)
+	dc.DrawCircle(cx, feet-spriteH/2, glowR)
+	dc.Fill()
+
+	if sprite, err := loadCharacter(class, index, cos, assetsPath); err == nil {
+		if b := utils.OpaqueBounds(sprite, 16); !b.Empty() {
+			sprite = imaging.Crop(sprite, b)
+		}
+		img := imaging.Fit(sprite, int(float64(r.Dx())*0.9), int(spriteH), imaging.Lanczos)
+		utils.DrawShadow(dc, cx, feet-4, float64(img.Bounds().Dx())*0.45, 0.6)
+		utils.Blit(dc, img, int(cx)-img.Bounds().Dx()/2, int(feet)-img.Bounds().Dy())
+	}
+
+	if face := setFont(26); face != nil && caption != "" {
+		drawInk(dc, face, truncateText(dc, caption, float64(r.Dx())), cx, feet+26, 0.5, 0.5, color.RGBA{200, 200, 215, 255})
+	}
+}
+
+// drawProfileXP draws the level and the XP bar toward the next one
+func drawProfileXP(dc *gg.Context, req *ProfileRequest, setFont func(float64) font.Face, r image.Rectangle) {
+	drawPanel(dc, r)
+	cx := float64(r.Min.X+r.Max.X) / 2
+	if face := setFont(20); face != nil {
+		drawInk(dc, face, "LEVEL", cx, float64(r.Min.Y)+28, 0.5, 0.5, color.RGBA{170, 170, 185, 255})
+	}
+	if face := setFont(64); face != nil {
+		drawInk(dc, face, fmt.Sprintf("%d", max(1, req.Level)), cx, float64(r.Min.Y)+84, 0.5, 0.5, victoryColor)
+	}
+	if req.XPToNext <= 0 {
+		return
+	}
+
+	barW := float64(r.Dx()) - 32
+	barY := float64(r.Max.Y) - 58
+	drawFlatBar(dc, float64(r.Min.X)+16, barY, barW, 12, float64(req.XP), float64(req.XPToNext), "#78DCFF", "#000000A0", 6)
+	if face := setFont(17); face != nil {
+		drawInk(dc, face, fmt.Sprintf("%d / %d XP", req.XP, req.XPToNext), cx, barY+34, 0.5, 0.5, xpColor)
+	}
+}
+
+// drawProfileStats draws stats as labelled bars in two columns. Bars without
+// a max are measured against the highest stat.
+func drawProfileStats(dc *gg.Context, stats []ProfileStat, setFont func(float64) font.Face, r image.Rectangle) {
+	drawPanel(dc, r)
+	x0, y0 := float64(r.Min.X)+16, float64(r.Min.Y)+14
+	if face := setFont(24); face != nil {
+		drawInk(dc, face, "STATS", x0, y0, 0, 0, color.White)
+	}
+	if len(stats) == 0 {
+		if face := setFont(22); face != nil {
+			drawInk(dc, face, "No stats", float64(r.Min.X+r.Max.X)/2, float64(r.Min.Y+r.Max.Y)/2+10, 0.5, 0.5, color.RGBA{160, 160, 170, 255})
+		}
+		return
+	}
+
+	highest := 1
+	for _, s := range stats {
+		highest = max(highest, s.Value)
+	}
+	if len(stats) > profileStatRows*2 {
+		stats = stats[:profileStatRows*2]
+	}
+
+	colW := (float64(r.Dx()) - 48) / 2
+	rowH := (float64(r.Max.Y) - 12 - (y0 + 34)) / profileStatRows
+	for i, s := range stats {
+		x := x0 + float64(i/profileStatRows)*(colW+16)
+		y := y0 + 34 + float64(i%profileStatRows)*rowH + rowH/2
+		fill, limit := statBar(s, highest)
+
+		if face := setFont(18); face != nil {
+			drawInk(dc, face, truncateText(dc, strings.ToUpper(s.Name), 70), x, y, 0, 0.5, color.RGBA{200, 200, 215, 255})
+			drawInk(dc, face, fmt.Sprintf("%d", s.Value), x+colW, y, 1, 0.5, color.White)
+		}
+		drawFlatBar(dc, x+78, y-5, colW-130, 10, float64(s.Value), float64(limit), fill, "#000000A0", 5)
+	}
+}
+
+// statBar is a stat's bar color and the value that fills its bar
+func statBar(s ProfileStat, highest int) (string, int) {
+	fill, ok := statColors[strings.ToLower(strings.TrimSpace(s.Name))]
+	if !ok {
+		fill = statColor
+	}
+	if s.Max > 0 {
+		return fill, s.Max
+	}
+	return fill, highest
+}
+
+// drawProfileGear lists equipped items with their rarity gem, slot and name
+func drawProfileGear(dc *gg.Context, items []EquippedItem, setFont func(float64) font.Face, r image.Rectangle) {
+	rows := make([]profileRow, len(items))
+	for i, item := range items {
+		rows[i] = profileRow{strings.ToUpper(item.Slot), item.Name, rarityColor(item.Rarity)}
+	}
+	drawProfileList(dc, "EQUIPMENT", "Nothing equipped", rows, setFont, r, func(x, y float64, c color.RGBA) {
+		drawGem(dc, x, y, 10, c)
+	})
+}
+
+// drawProfileAchievements lists achievements with a star each
+func drawProfileAchievements(dc *gg.Context, achievements []string, setFont func(float64) font.Face, r image.Rectangle) {
+	rows := make([]profileRow, len(achievements))
+	for i, a := range achievements {
+		rows[i] = profileRow{"", a, victoryColor}
+	}
+	drawProfileList(dc, "ACHIEVEMENTS", "None yet", rows, setFont, r, func(x, y float64, c color.RGBA) {
+		drawStar(dc, x, y, 10, c)
+	})
+}
+
+// profileRow is one line of a sheet list: an optional small label, the text
+// and the icon's color
+type profileRow struct {
+	label, text string
+	c           color.RGBA
+}
+
+// drawProfileList draws a titled panel of icon rows. Rows that don't fit are
+// summarised as "+N more" on the last line.
+func drawProfileList(dc *gg.Context, title, empty string, rows []profileRow, setFont func(float64) font.Face, r image.Rectangle, icon func(x, y float64, c color.RGBA)) {
+	drawPanel(dc, r)
+	x0, y0 := float64(r.Min.X)+16, float64(r.Min.Y)+14
+	if face := setFont(24); face != nil {
+		drawInk(dc, face, title, x0, y0, 0, 0, color.White)
+	}
+	if len(rows) == 0 {
+		if face := setFont(20); face != nil {
+			drawInk(dc, face, empty, float64(r.Min.X+r.Max.X)/2, float64(r.Min.Y+r.Max.Y)/2+10, 0.5, 0.5, color.RGBA{160, 160, 170, 255})
+		}
+		return
+	}
+
+	const rowH = 28.0
+	top := y0 + 34
+	fit := int((float64(r.Max.Y) - 8 - top) / rowH)
+	shown, more := listFit(len(rows), fit)
+	rows = rows[:shown]
+	textW := float64(r.Dx()) - 56
+	for i, row := range rows {
+		y := top + float64(i)*rowH + rowH/2
+		icon(x0+10, y, row.c)
+		x := x0 + 28
+		if face := setFont(14); face != nil && row.label != "" {
+			drawInk(dc, face, row.label, x, y, 0, 0.5, color.RGBA{150, 150, 165, 255})
+			w, _ := dc.MeasureString(row.label)
+			x += w + 8
+		}
+		if face := setFont(18); face != nil {
+			drawInk(dc, face, truncateText(dc, row.text, textW-(x-x0-28)), x, y, 0, 0.5, color.White)
+		}
+	}
+	if more > 0 {
+		if face := setFont(18); face != nil {
+			y := top + float64(len(rows))*rowH + rowH/2
+			drawInk(dc, face, fmt.Sprintf("+%d more", more), x0+28, y, 0, 0.5, color.RGBA{170, 170, 185, 255})
+		}
+	}
+}
+
+// listFit is how many of n rows a list with room for fit shows, and how
+// many the "+N more" line stands for
+func listFit(n, fit int) (shown, more int) {
+	if n <= fit {
+		return n, 0
+	}
+	shown = max(0, fit-1)
+	return shown, n - shown
+}
+
+// classDisplayName is the manifest's name for a class, or the class as sent
+func classDisplayName(class string, index int) string {
+	if name := CharacterSprite(class, index).DisplayName; name != "" {
+		return name
+	}
+	return class
+}
@@ -0,0 +1,71 @@
+package combat
+
+import "testing"
+
+func TestStatBar(t *testing.T) {
+	tests := []struct {
+		stat  ProfileStat
+		fill  string
+		limit int
+	}{
+		{ProfileStat{Name: "STR", Value: 12, Max: 20}, statColors["str"], 20},
+		{ProfileStat{Name: " Intelligence ", Value: 30}, statColors["int"], 40},
+		{ProfileStat{Name: "luck", Value: 5, Max: -1}, statColors["luck"], 40},
+		{ProfileStat{Name: "Charm", Value: 8}, statColor, 40},
+		{ProfileStat{Value: 8, Max: 10}, statColor, 10},
+	}
+	for _, tt := range tests {
+		fill, limit := statBar(tt.stat, 40)
+		if fill != tt.fill || limit != tt.limit {
+			t.Errorf("statBar(%+v) = %s, %d; want %s, %d", tt.stat, fill, limit, tt.fill, tt.limit)
+		}
+	}
+}
+
+func TestListFit(t *testing.T) {
+	tests := []struct {
+		n, fit      int
+		shown, more int
+	}{
+		{0, 5, 0, 0},
+		{3, 5, 3, 0},
+		{5, 5, 5, 0},
+		{6, 5, 4, 2},
+		{20, 5, 4, 16},
+		{2, 1, 0, 2},
+		{2, 0, 0, 2},
+	}
+	for _, tt := range tests {
+		shown, more := listFit(tt.n, tt.fit)
+		if shown != tt.shown || more != tt.more {
+			t.Errorf("listFit(%d, %d) = %d, %d; want %d, %d", tt.n, tt.fit, shown, more, tt.shown, tt.more)
+		}
+		if tt.more > 0 && shown+more != tt.n {
+			t.Errorf("listFit(%d, %d) shows %d and counts %d more", tt.n, tt.fit, shown, more)
+		}
+	}
+}
+
+func TestClassDisplayName(t *testing.T) {
+	useManifest(t, &Manifest{Characters: map[string]*SpriteGroup{
+		"FIGHTER": {Sprites: []SpriteMeta{{File: "f0.png", DisplayName: "Fighter"}}},
+		"MAGE":    {Sprites: []SpriteMeta{{File: "m0.png", DisplayName: "Mage"}, {File: "m1.png", DisplayName: "Archmage"}}},
+		"MONK":    spriteGroup("monk0.png"),
+	}})
+	tests := []struct {
+		class string
+		index int
+		want  string
+	}{
+		{"MAGE", 0, "Mage"},
+		{"MAGE", 1, "Archmage"},
+		{"MAGE", 3, "Archmage"},
+		{"MONK", 0, "MONK"},
+		{"NINJA", 0, "Fighter"}, // Drawn as the fighter, so named like one
+	}
+	for _, tt := range tests {
+		if got := classDisplayName(tt.class, tt.index); got != tt.want {
+			t.Errorf("classDisplayName(%q, %d) = %q; want %q", tt.class, tt.index, got, tt.want)
+		}
+	}
+}
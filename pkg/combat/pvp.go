@@ -0,0 +1,118 @@
+package combat
+
+import (
+	"image"
+	"math"
+	"strings"
+
+	"github.com/disintegration/imaging"
+)
+
+const (
+	TeamA = "A"
+	TeamB = "B"
+)
+
+// team returns the player's PvP side, defaulting to team A
+func (p Player) team() string {
+	if strings.EqualFold(strings.TrimSpace(p.Team), TeamB) {
+		return TeamB
+	}
+	return TeamA
+}
+
+// isDuel reports whether this is a PvP match with players on both teams.
+// PvP requests without team B keep the legacy monster-opponent layout.
+func (req *CombatRequest) isDuel() bool {
+	if req.CombatType != "PVP" {
+		return false
+	}
+	for _, p := range req.Players {
+		if p.team() == TeamB {
+			return true
+		}
+	}
+	return false
+}
+
+// teamIndexes returns the player indexes on each side, in request order
+func (req *CombatRequest) teamIndexes() (a, b []int) {
+	for i, p := range req.Players {
+		if p.team() == TeamB {
+			b = append(b, i)
+		} else {
+			a = append(a, i)
+		}
+	}
+	return a, b
+}
+
+// layoutDuel assigns HUD slots to each team's zone; team B's cards fill
+// theirs from the right
+func (sc *combatScene) layoutDuel() {
+	duel := sc.theme.Duel
+	sc.party = make([]partySlot, len(sc.req.Players))
+	a, b := sc.req.teamIndexes()
+	for _, team := range []struct {
+		members []int
+		zone    image.Rectangle
+		mirror  bool
+	}{{a, duel.ZoneA.rect(), false}, {b, duel.ZoneB.rect(), true}} {
+		slots := sc.theme.partyLayout(len(team.members), team.zone, team.mirror)
+		for i, idx := range team.members {
+			sc.party[idx] = slots[i]
+		}
+	}
+}
+
+// placeDuelist sizes and positions a player's full-body sprite on its team's
+// half of the battlefield. Members are staggered back from the front line in
+// two depth rows, and team B is mirrored so both sides face each other.
+func (sc *combatScene) placeDuelist(u *sceneUnit, sprite image.Image) {
+	p := sc.req.Players[u.ref.index]
+	a, b := sc.req.teamIndexes()
+	members := a
+	if p.team() == TeamB {
+		members = b
+	}
+	slot := 0
+	for i, idx := range members {
+		if idx == u.ref.index {
+			slot = i
+		}
+	}
+
+	duel := sc.theme.Duel
+	n := len(members)
+	w := duel.SpriteWidth
+	if n > 3 {
+		w = duel.CrowdWidth
+	}
+	step := w * 0.8
+	if n > 1 {
+		step = math.Min(step, (duel.Front-duel.Back-w)/float64(n-1))
+	}
+
+	u.sprite = imaging.Resize(sprite, int(w), 0, imaging.Lanczos)
+	h := float64(u.sprite.Bounds().Dy())
+	u.x = duel.Front - w - float64(slot)*step
+	u.y = duel.Ground - h
+	if slot%2 == 1 {
+		u.y -= 40 // Back row
+	}
+	// Team A faces right and team B faces left, whichever way the art was drawn
+	facesLeft := CharacterSprite(p.Class, p.SpriteIndex).Facing == "left"
+	if p.team() == TeamB {
+		u.x = float64(sc.theme.Width) - u.x - w
+	}
+	if (p.team() == TeamB) != facesLeft {
+		u.sprite = imaging.FlipH(u.sprite)
+	}
+
+	u.shadow = w * 0.5
+	u.hpBar = true
+	u.nameplate = true
+	if p.CurrentHP <= 0 {
+		u.fade = 1
+	}
+}
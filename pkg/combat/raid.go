@@ -0,0 +1,387 @@
+package combat
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"math"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"image-service/pkg/utils"
+
+	"github.com/disintegration/imaging"
+	"github.com/fogleman/gg"
+	"github.com/gin-gonic/gin"
+	"golang.org/x/image/font"
+)
+
+// RaidRequest is a world boss event: the boss's HP across its phases and the
+// damage every participant has dealt
+type RaidRequest struct {
+	Boss         Enemy             `json:"boss"`         // CALAMITY tier unless it names a tier or level
+	Title        string            `json:"title"`        // Event name over the HP bar; the boss's name by default
+	Phases       []int             `json:"phases"`       // HP percentages where a new phase starts
+	Segments     int               `json:"segments"`     // HP bar segments, 10 by default
+	Participants []RaidParticipant `json:"participants"` // In any order; ranked by damage
+	Total        int               `json:"total"`        // Participant count for the footer when not all are sent
+	Top          int               `json:"top"`          // Rows to show at most; as many as fit by default
+
+	Background string `json:"background"`
+	Biome      string `json:"biome"`
+	Seed       Seed   `json:"seed"`
+}
+
+// RaidParticipant is one player's contribution to a raid
+type RaidParticipant struct {
+	Name        string `json:"name"`
+	Class       string `json:"class"`
+	SpriteIndex int    `json:"spriteIndex"`
+	Damage      int    `json:"damage"`
+}
+
+const (
+	raidSegments = 10
+	raidMinRow   = 24.0 // Rows shrink to this before the table cuts off
+	raidMaxRow   = 44.0
+	raidOneCol   = 10 // Participants that fit in one column before splitting
+)
+
+var (
+	raidHPColor = color.RGBA{205, 40, 45, 255}
+	raidMedals  = []color.RGBA{{255, 205, 70, 255}, {215, 225, 235, 255}, {205, 125, 60, 255}}
+)
+
+func GenerateRaid(c *gin.Context) {
+	var req RaidRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	if err := req.validate(); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+
+	img, spriteFile := renderRaid(&req, "assets")
+	c.Header("X-Enemy-Sprites", spriteFile)
+	buf, err := utils.EncodeImageToBuffer(img)
+	if err != nil {
+		c.JSON(500, gin.H{"error": "Failed to encode image"})
+		return
+	}
+
+	c.Data(200, "image/png", buf)
+}
+
+// validate checks that phases fall inside the HP bar and the background
+func (req *RaidRequest) validate() error {
+	for _, p := range req.Phases {
+		if p <= 0 || p >= 100 {
+			return fmt.Errorf("phase %d must be between 1 and 99", p)
+		}
+	}
+	return checkBackground(req.Background)
+}
+
+// renderRaid draws the title and HP bar across the top, the boss on the left
+// and the damage table on the right. It returns the image and the boss's
+// sprite file.
+func renderRaid(req *RaidRequest, assetsPath string) (image.Image, string) {
+	dc := gg.NewContext(CANVAS_W, CANVAS_H)
+	setFont := cardFont(dc)
+
+	boss := req.Boss
+	boss.IsBoss = true
+	if boss.Tier == "" && boss.Level == 0 {
+		boss.Tier = "CALAMITY"
+	}
+	drawBlurredArena(dc, &CombatRequest{Background: req.Background, Biome: req.Biome, Seed: req.Seed, Enemies: []Enemy{boss}}, assetsPath)
+	accent := bossTierStyles[bossTier(boss)].c
+
+	title := req.Title
+	if title == "" {
+		title = boss.Name
+	}
+	if face := setFont(50); face != nil && title != "" {
+		drawInkOutlined(dc, face, truncateText(dc, strings.ToUpper(title), CANVAS_W-80), CANVAS_W/2, 36, 0.5, 0.5, color.White, color.Black, 2)
+	}
+	drawRaidHPBar(dc, req, boss, setFont, image.Rect(30, 82, CANVAS_W-30, 124))
+
+	spritePath := SelectEnemySprite(boss, 0, boss.Level, assetsPath)
+	drawRaidBoss(dc, boss, spritePath, accent, setFont, image.Rect(20, 168, 396, 640))
+
+	ranked := append([]RaidParticipant(nil), req.Participants...)
+	sort.SliceStable(ranked, func(i, j int) bool { return ranked[i].Damage > ranked[j].Damage })
+	drawRaidTable(dc, req, ranked, setFont, image.Rect(408, 168, CANVAS_W-20, 640), assetsPath)
+
+	total := max(req.Total, len(req.Participants))
+	dealt := 0
+	for _, p := range req.Participants {
+		dealt += p.Damage
+	}
+	if face := setFont(22); face != nil {
+		drawInk(dc, face, fmt.Sprintf("Participants: %d", total), 30, 664, 0, 0.5, mutedColor)
+		drawInk(dc, face, "Total damage: "+groupDigits(dealt), CANVAS_W-30, 664, 1, 0.5, mutedColor)
+	}
+	return dc.Image(), filepath.Base(spritePath)
+}
+
+// drawRaidHPBar draws the boss's HP as one long bar cut into segments, with
+// a marker where each phase starts. Markers the boss is already past are dim.
+func drawRaidHPBar(dc *gg.Context, req *RaidRequest, boss Enemy, setFont func(float64) font.Face, r image.Rectangle) {
+	x, y, w, h := float64(r.Min.X), float64(r.Min.Y), float64(r.Dx()), float64(r.Dy())
+	maxHP := max(1, boss.MaxHP)
+	hp := max(0, min(boss.CurrentHP, maxHP))
+	if boss.MaxHP <= 0 {
+		hp = maxHP
+	}
+	frac := float64(hp) / float64(maxHP)
+
+	dc.DrawRoundedRectangle(x-4, y-4, w+8, h+8, 8)
+	dc.SetColor(color.NRGBA{0, 0, 0, 200})
+	dc.Fill()
+	dc.DrawRectangle(x, y, w, h)
+	dc.SetColor(color.RGBA{45, 12, 14, 255})
+	dc.Fill()
+	if frac > 0 {
+		dc.DrawRectangle(x, y, w*frac, h)
+		dc.SetColor(raidHPColor)
+		dc.Fill()
+		dc.DrawRectangle(x, y, w*frac, h*0.35)
+		dc.SetColor(color.NRGBA{255, 255, 255, 45})
+		dc.Fill()
+	}
+
+	segments := req.Segments
+	if segments <= 0 {
+		segments = raidSegments
+	}
+	segments = min(segments, 100)
+	dc.SetColor(color.NRGBA{0, 0, 0, 170})
+	dc.SetLineWidth(2)
+	for i := 1; i < segments; i++ {
+		sx := x + w*float64(i)/float64(segments)
+		dc.DrawLine(sx, y, sx, y+h)
+		dc.Stroke()
+	}
+
+	phases := append([]int(nil), req.Phases...)
+	sort.Sort(sort.Reverse(sort.IntSlice(phases)))
+	phase := 1
+	for i, p := range phases {
+		mc := color.RGBA{255, 215, 90, 255}
+		if frac*100 <= float64(p) {
+			mc = color.RGBA{120, 110, 90, 255}
+			phase = i + 2
+		}
+		mx := x + w*float64(p)/100
+		dc.DrawLine(mx, y-8, mx, y+h+8)
+		dc.SetColor(color.Black)
+		dc.SetLineWidth(6)
+		dc.StrokePreserve()
+		dc.SetColor(mc)
+		dc.SetLineWidth(3)
+		dc.Stroke()
+		if face := setFont(14); face != nil {
+			drawBadge(dc, face, fmt.Sprintf("P%d", i+2), mx, y+h+8, mc)
+		}
+	}
+
+	if face := setFont(20); face != nil {
+		text := fmt.Sprintf("%s / %s  (%.1f%%)", groupDigits(hp), groupDigits(maxHP), frac*100)
+		drawInkOutlined(dc, face, text, x+w/2, y+h/2, 0.5, 0.5, color.White, color.Black, 2)
+		if len(phases) > 0 {
+			drawInkOutlined(dc, face, fmt.Sprintf("PHASE %d", phase), x+w-12, y+h/2, 1, 0.5, color.White, color.Black, 2)
+		}
+	}
+}
+
+// drawRaidBoss draws the boss as large as fits in r, greyed with a stamp
+// once it's down
+func drawRaidBoss(dc *gg.Context, boss Enemy, spritePath string, accent color.RGBA, setFont func(float64) font.Face, r image.Rectangle) {
+	cx, cy := float64(r.Min.X+r.Max.X)/2, float64(r.Min.Y+r.Max.Y)/2
+	glow := gg.NewRadialGradient(cx, cy, 0, cx, cy, float64(r.Dx())*0.6)
+	glow.AddColorStop(0, withAlpha(color.NRGBA(accent), 110))
+	glow.AddColorStop(1, withAlpha(color.NRGBA(accent), 0))
+	dc.SetFillStyle(glow)
+	dc.DrawCircle(cx, cy, float64(r.Dx())*0.6)
+	dc.Fill()
+
+	sprite, err := loadEnemy(boss, spritePath)
+	if err != nil {
+		return
+	}
+	if b := utils.OpaqueBounds(sprite, 16); !b.Empty() {
+		sprite = imaging.Crop(sprite, b)
+	}
+	sb := sprite.Bounds()
+	scale := math.Min(float64(r.Dx())/float64(sb.Dx()), float64(r.Dy()-20)/float64(sb.Dy()))
+	img := imaging.Resize(sprite, max(1, int(float64(sb.Dx())*scale)), 0, imaging.Lanczos)
+	ib := img.Bounds()
+	feet := float64(r.Max.Y) - 10
+	utils.DrawShadow(dc, cx, feet-4, float64(ib.Dx())*0.4, 0.7)
+	defeated := boss.MaxHP > 0 && boss.CurrentHP <= 0
+	if defeated {
+		utils.DrawImageAlpha(dc, imaging.Grayscale(img), int(cx)-ib.Dx()/2, int(feet)-ib.Dy(), 0.6)
+	} else {
+		utils.Blit(dc, img, int(cx)-ib.Dx()/2, int(feet)-ib.Dy())
+	}
+
+	if face := setFont(30); face != nil && defeated {
+		drawBadge(dc, face, "DEFEATED", cx, cy-20, victoryColor)
+	}
+}
+
+// drawRaidTable ranks participants by damage with their class portrait and
+// share of the total. Past raidOneCol the table splits in two columns, rows
+// shrink to fit, and whoever still doesn't fit is summed up in a last row.
+func drawRaidTable(dc *gg.Context, req *RaidRequest, ranked []RaidParticipant, setFont func(float64) font.Face, r image.Rectangle, assetsPath string) {
+	drawPanel(dc, r)
+	x0, y0 := float64(r.Min.X)+16, float64(r.Min.Y)+14
+	if face := setFont(24); face != nil {
+		drawInk(dc, face, "TOP DAMAGE", x0, y0, 0, 0, color.White)
+	}
+	if len(ranked) == 0 {
+		if face := setFont(22); face != nil {
+			drawInk(dc, face, "No damage yet", float64(r.Min.X+r.Max.X)/2, float64(r.Min.Y+r.Max.Y)/2, 0.5, 0.5, mutedColor)
+		}
+		return
+	}
+
+	dealt := 0
+	for _, p := range ranked {
+		dealt += max(0, p.Damage)
+	}
+	top := y0 + 40
+	shown, cols, rows, rowH := raidLayout(len(ranked), req.Top, float64(r.Max.Y)-12-top)
+	more := len(ranked) - shown
+	colW := (float64(r.Dx()) - 32 - 16*float64(cols-1)) / float64(cols)
+
+	fontSize := math.Max(13, math.Min(20, rowH*0.45))
+	for i := 0; i < shown; i++ {
+		x := x0 + float64(i/rows)*(colW+16)
+		y := top + float64(i%rows)*rowH
+		drawRaidRow(dc, i+1, ranked[i], dealt, x, y, colW, rowH, fontSize, setFont, assetsPath)
+	}
+	if face := setFont(fontSize); face != nil && more > 0 {
+		rest := 0
+		for _, p := range ranked[shown:] {
+			rest += p.Damage
+		}
+		x := x0 + float64(shown/rows)*(colW+16)
+		y := top + float64(shown%rows)*rowH
+		drawInk(dc, face, fmt.Sprintf("+%d more", more), x+6, y+rowH/2, 0, 0.5, mutedColor)
+		drawInk(dc, face, groupDigits(rest), x+colW-6, y+rowH/2, 1, 0.5, mutedColor)
+	}
+}
+
+// raidLayout fits n ranked rows, at most top of them when top is set, into
+// avail pixels of height. It returns the rows shown, the columns and rows
+// of the grid and the row height; when rows are cut, the grid keeps a slot
+// for the "+N more" row.
+func raidLayout(n, top int, avail float64) (shown, cols, rows int, rowH float64) {
+	shown = n
+	if top > 0 {
+		shown = min(shown, top)
+	}
+	slots := shown
+	if shown < n {
+		slots++
+	}
+	cols = 1
+	if slots > raidOneCol {
+		cols = 2
+	}
+	rows = max(1, (slots+cols-1)/cols)
+	rowH = math.Min(raidMaxRow, avail/float64(rows))
+	if rowH < raidMinRow {
+		rowH = raidMinRow
+		rows = int(avail / rowH)
+		shown = rows*cols - 1
+	}
+	return shown, cols, rows, rowH
+}
+
+// drawRaidRow draws one ranked participant: rank (a medal for the top
+// three), portrait, name and damage, with a bar for their share underneath
+func drawRaidRow(dc *gg.Context, rank int, p RaidParticipant, dealt int, x, y, w, h, fontSize float64, setFont func(float64) font.Face, assetsPath string) {
+	if rank%2 == 1 {
+		dc.DrawRoundedRectangle(x, y+1, w, h-2, 6)
+		dc.SetColor(color.NRGBA{255, 255, 255, 14})
+		dc.Fill()
+	}
+
+	cy := y + h/2
+	rankW := fontSize * 1.9
+	if rank <= len(raidMedals) {
+		dc.DrawCircle(x+rankW/2, cy, h*0.34)
+		dc.SetColor(raidMedals[rank-1])
+		dc.Fill()
+	}
+	if face := setFont(fontSize); face != nil {
+		rc := color.Color(color.White)
+		if rank <= len(raidMedals) {
+			rc = color.RGBA{20, 14, 0, 255}
+		}
+		dc.SetColor(rc)
+		ox, oy := inkOrigin(face, fmt.Sprintf("%d", rank), x+rankW/2, cy, 0.5, 0.5)
+		dc.DrawString(fmt.Sprintf("%d", rank), ox, oy)
+	}
+
+	icon := int(h - 6)
+	ix := x + rankW + 6
+	class := strings.ToUpper(strings.TrimSpace(p.Class))
+	if sprite, err := loadCharacter(class, p.SpriteIndex, Cosmetics{}, assetsPath); err == nil && icon > 4 {
+		dc.DrawRoundedRectangle(ix, y+3, float64(icon), float64(icon), 4)
+		dc.SetColor(color.NRGBA{0, 0, 0, 120})
+		dc.Fill()
+		utils.Blit(dc, characterPortrait(sprite, class, p.SpriteIndex, icon, icon, assetsPath), int(ix), int(y+3))
+	}
+
+	tx := ix + float64(icon) + 8
+	share := 0.0
+	if dealt > 0 {
+		share = float64(max(0, p.Damage)) / float64(dealt)
+	}
+	face := setFont(fontSize)
+	if face == nil {
+		return
+	}
+	dmg := groupDigits(p.Damage)
+	dmgW, _ := dc.MeasureString(dmg)
+	nameY, barY, barH := y+h*0.4, y+h*0.8, math.Max(3, h*0.12)
+	drawInk(dc, face, truncateText(dc, p.Name, x+w-tx-dmgW-18), tx, nameY, 0, 0.5, color.White)
+	drawInk(dc, face, dmg, x+w-6, nameY, 1, 0.5, victoryColor)
+
+	// Short rows only have room for the bar; taller ones add the percentage
+	barW := x + w - 6 - tx
+	if h >= 34 {
+		if small := setFont(math.Max(12, fontSize*0.75)); small != nil {
+			pct := fmt.Sprintf("%.1f%%", share*100)
+			pw, _ := dc.MeasureString(pct)
+			barW -= pw + 8
+			drawInk(dc, small, pct, x+w-6, barY, 1, 0.5, mutedColor)
+		}
+	}
+	drawFlatBar(dc, tx, barY-barH/2, barW, barH, share, 1, "#FF8A3D", "#000000A0", barH/2)
+}
+
+// groupDigits formats n with thousands separators
+func groupDigits(n int) string {
+	s := fmt.Sprintf("%d", n)
+	neg := strings.HasPrefix(s, "-")
+	s = strings.TrimPrefix(s, "-")
+	var b strings.Builder
+	for i, d := range s {
+		if i > 0 && (len(s)-i)%3 == 0 {
+			b.WriteByte(',')
+		}
+		b.WriteRune(d)
+	}
+	if neg {
+		return "-" + b.String()
+	}
+	return b.String()
+}
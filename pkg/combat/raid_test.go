@@ -0,0 +1,76 @@
+package combat
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRaidValidate(t *testing.T) {
+	tests := []struct {
+		name string
+		req  RaidRequest
+		err  string // "" for a valid request
+	}{
+		{"no phases", RaidRequest{}, ""},
+		{"phases", RaidRequest{Phases: []int{75, 50, 25}}, ""},
+		{"edges", RaidRequest{Phases: []int{1, 99}}, ""},
+		{"zero", RaidRequest{Phases: []int{50, 0}}, "phase 0 must be between 1 and 99"},
+		{"full", RaidRequest{Phases: []int{100}}, "phase 100 must be between 1 and 99"},
+		{"negative", RaidRequest{Phases: []int{-5}}, "phase -5"},
+		{"background", RaidRequest{Background: "env1.png"}, ""},
+		{"background path", RaidRequest{Background: "/etc/passwd"}, "must be a filename"},
+	}
+	for _, tt := range tests {
+		err := tt.req.validate()
+		switch {
+		case tt.err == "" && err != nil:
+			t.Errorf("%s: %v; want valid", tt.name, err)
+		case tt.err != "" && (err == nil || !strings.Contains(err.Error(), tt.err)):
+			t.Errorf("%s: %v; want %q", tt.name, err, tt.err)
+		}
+	}
+}
+
+func TestRaidLayout(t *testing.T) {
+	// The table's height in renderRaid
+	const avail = 406.0
+	for n := 1; n <= 30; n++ {
+		shown, cols, rows, rowH := raidLayout(n, 0, avail)
+		if shown != n {
+			t.Errorf("%d participants: %d shown; want all", n, shown)
+		}
+		if want := 1 + min(n/(raidOneCol+1), 1); cols != want {
+			t.Errorf("%d participants: %d columns; want %d", n, cols, want)
+		}
+		if rows*cols < n || float64(rows)*rowH > avail || rowH < raidMinRow || rowH > raidMaxRow {
+			t.Errorf("%d participants: %d x %d rows of %.1f px don't fit", n, cols, rows, rowH)
+		}
+	}
+
+	tests := []struct {
+		n, top int
+		shown  int
+		cols   int
+	}{
+		{40, 0, 31, 2},
+		{100, 0, 31, 2},
+		{20, 10, 10, 2},
+		{20, 5, 5, 1},
+		{12, 12, 12, 2},
+		{5, 20, 5, 1},
+	}
+	for _, tt := range tests {
+		shown, cols, rows, rowH := raidLayout(tt.n, tt.top, avail)
+		if shown != tt.shown || cols != tt.cols {
+			t.Errorf("raidLayout(%d, %d) shows %d in %d columns; want %d in %d", tt.n, tt.top, shown, cols, tt.shown, tt.cols)
+		}
+		// Cut tables need a slot for the "+N more" row
+		slots := shown
+		if shown < tt.n {
+			slots++
+		}
+		if rows*cols < slots || float64(rows)*rowH > avail {
+			t.Errorf("raidLayout(%d, %d): %d slots in %d x %d rows of %.1f px", tt.n, tt.top, slots, cols, rows, rowH)
+		}
+	}
+}
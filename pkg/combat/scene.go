@@ -0,0 +1,497 @@
+package combat
+
+import (
+	"image"
+	"image/color"
+	"math"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"image-service/pkg/utils"
+
+	"github.com/disintegration/imaging"
+	"github.com/fogleman/gg"
+	"golang.org/x/image/font"
+)
+
+var (
+	deadTint = color.RGBA{255, 0, 0, 100}
+	hitTint  = color.RGBA{255, 255, 255, 220}
+	healTint = color.RGBA{80, 255, 120, 160}
+)
+
+// sceneUnit is a player or enemy resolved for drawing. The dx/dy, flash and
+// fade fields are per-frame animation state and stay zero for static renders.
+type sceneUnit struct {
+	ref        unitRef
+	name       string
+	sprite     image.Image // nil when the unit isn't drawn on the battlefield
+	x, y       float64
+	hp, maxHP  float64
+	energy     float64
+	maxEnergy  float64
+	level      int
+	spriteFile string // Chosen sprite file, reported back to the client
+	nameplate  bool   // Draw name and HP bar above the sprite
+	hpBar      bool
+
+	portrait   image.Image // HUD portrait crop, players only
+	portraitKO image.Image
+
+	dx, dy    float64
+	flash     float64 // 0-1 hit flash strength
+	flashTint color.RGBA
+	fade      float64 // 0-1 death fade, 1 = fully red-tinted
+	label     string  // Text drawn above the sprite (skill name, MISS)
+	shadow    float64 // Ground shadow radius, 0 = 40% of sprite width
+	scale     float64 // Formation scale for the HP bar and status row, 0 = 1
+	feetOrder bool    // Depth-sort by the sprite's bottom edge rather than its top
+
+	effects []StatusEffect
+	floater floater
+
+	tinted map[color.RGBA]image.Image
+}
+
+// tint returns the sprite blended with c, cached per unit
+func (u *sceneUnit) tint(c color.RGBA) image.Image {
+	if u.tinted == nil {
+		u.tinted = make(map[color.RGBA]image.Image)
+	}
+	if img, ok := u.tinted[c]; ok {
+		return img
+	}
+	img := utils.TintImage(u.sprite, c)
+	u.tinted[c] = img
+	return img
+}
+
+func (u *sceneUnit) hpPercent() float64 {
+	if u.maxHP <= 0 {
+		return 0
+	}
+	return u.hp / u.maxHP
+}
+
+// uiScale is the size of the unit's HP bar and status row relative to the theme
+func (u *sceneUnit) uiScale() float64 {
+	if u.scale <= 0 {
+		return 1
+	}
+	return u.scale
+}
+
+// depth orders battlefield sprites back to front. Hand-placed units sort by
+// their top edge; solved formations by where they stand.
+func (u *sceneUnit) depth() float64 {
+	if u.feetOrder {
+		return u.y + float64(u.sprite.Bounds().Dy())
+	}
+	return u.y
+}
+
+// unitAnchor is where overlays (numbers, skill effects) attach to a unit
+type unitAnchor struct {
+	x, y  float64
+	h     float64 // Height of the sprite or portrait the point sits on
+	scale float64 // Overlay scale, below 1 on shrunken party cards
+}
+
+// anchor returns the point fy (0 top, 1 bottom) down the unit's battlefield
+// sprite, or on its HUD portrait when it has none. ok is false when the unit
+// isn't drawn anywhere.
+func (sc *combatScene) anchor(u *sceneUnit, fy float64) (unitAnchor, bool) {
+	switch {
+	case u.sprite != nil:
+		b := u.sprite.Bounds()
+		return unitAnchor{u.x + u.dx + float64(b.Dx())/2, u.y + u.dy + float64(b.Dy())*fy, float64(b.Dy()), 1}, true
+	case u.portrait != nil && u.ref.side == sidePlayer:
+		slot := sc.party[u.ref.index]
+		party := sc.theme.Party
+		pb := u.portrait.Bounds()
+		bottom := float64(slot.y + scaled(party.Portrait.Bottom, slot.scale))
+		return unitAnchor{
+			x:     float64(slot.x+scaled(party.Portrait.X, slot.scale)) + float64(pb.Dx())/2,
+			y:     bottom - float64(pb.Dy())*(1-fy),
+			h:     float64(pb.Dy()),
+			scale: math.Max(0.6, slot.scale),
+		}, true
+	}
+	return unitAnchor{}, false
+}
+
+type hudImage struct {
+	img  image.Image
+	x, y int
+}
+
+// combatScene holds everything loaded and resized for one combat render so
+// that it can be drawn repeatedly (once for a PNG, once per animation frame).
+type combatScene struct {
+	req            *CombatRequest
+	theme          *Theme
+	assetsPath     string
+	background     image.Image  // Canvas-sized with the dark overlay applied
+	backgroundFile string       // Chosen environment, reported back to the client
+	enemies        []*sceneUnit // Index matches req.Enemies
+	players        []*sceneUnit // Index matches req.Players
+	hud            []hudImage
+	party          []partySlot // Index matches req.Players
+	partyUIs       map[float64]partyUI
+	faces          map[float64]font.Face // Theme font by size, reused across frames
+	timeline       []timelineTurn
+	effects        []*sceneEffect
+	weather        *weatherLayer
+	clock          float64     // Seconds into the animation the weather is drawn at
+	fxLayer        *gg.Context // Scratch canvas the effects are drawn on
+	duel           bool        // PvP with players on both teams
+	hideFloaters   bool        // Animations show the numbers once the turn has played
+	overflow       int         // Enemies left out of the formation
+	banner         string
+}
+
+func (sc *combatScene) uiPath(f string) string {
+	return filepath.Join(sc.assetsPath, "rpgasset", "ui", f)
+}
+
+// face returns the theme font at size
+func (sc *combatScene) face(size float64) (font.Face, error) {
+	if face, ok := sc.faces[size]; ok {
+		return face, nil
+	}
+	face, err := utils.LoadFont(sc.uiPath(sc.theme.Font), size)
+	if err != nil {
+		return nil, err
+	}
+	if sc.faces == nil {
+		sc.faces = make(map[float64]font.Face)
+	}
+	sc.faces[size] = face
+	return face, nil
+}
+
+func buildScene(req *CombatRequest, theme *Theme, assetsPath string) *combatScene {
+	sc := &combatScene{req: req, theme: theme, assetsPath: assetsPath, duel: req.isDuel()}
+	cw, ch := theme.Width, theme.Height
+
+	// 1. Background
+	bgPath := selectBackground(req, assetsPath)
+	sc.backgroundFile = filepath.Base(bgPath)
+	bg := gg.NewContext(cw, ch)
+	bgImg, err := utils.LoadImage(bgPath)
+	if bgPath != "" && err == nil {
+		bg.DrawImage(gradeArena(imaging.Fill(bgImg, cw, ch, imaging.Center, imaging.Lanczos), req.TimeOfDay), 0, 0)
+	} else {
+		bg.SetColor(themeColor(theme.Backdrop, color.RGBA{26, 26, 26, 255}))
+		bg.Clear()
+	}
+	drawAtmosphere(bg, req.TimeOfDay, req.Weather)
+	sc.weather = newWeather(req.Weather, req.Seed, cw, ch)
+
+	// Dark overlay so the UI reads over any art
+	if theme.Dim != "" {
+		bg.SetColor(utils.ParseHexColor(theme.Dim))
+		bg.DrawRectangle(0, 0, float64(cw), float64(ch))
+		bg.Fill()
+	}
+
+	// Enemies killed during this turn's actions must be on screen for the animation
+	killed := make(map[unitRef]bool)
+	for _, a := range req.Actions {
+		if ref, ok := req.resolveUnit(a.Target); ok && a.Killed {
+			killed[ref] = true
+		}
+	}
+
+	// 2. Mobs / Enemies
+	layout := theme.Enemies
+
+	// Determine avg level for sprite selection
+	avgLevel := 1
+	if len(req.Players) > 0 {
+		sum := 0
+		for _, p := range req.Players {
+			sum += p.Level
+		}
+		avgLevel = sum / len(req.Players)
+	}
+
+	var formation []formationUnit
+	raw := make(map[int]image.Image)
+	paths := make(map[int]string)
+	for i, enemy := range req.Enemies {
+		u := &sceneUnit{
+			ref:     unitRef{sideEnemy, i},
+			name:    enemy.Name,
+			hp:      float64(enemy.CurrentHP),
+			maxHP:   float64(enemy.MaxHP),
+			effects: enemy.StatusEffects,
+			floater: floater{enemy.LastDamage, enemy.LastHeal, enemy.Crit},
+		}
+		sc.enemies = append(sc.enemies, u)
+
+		// Duels draw the opposing team instead of monsters
+		if sc.duel || (enemy.CurrentHP <= 0 && !enemy.JustDied && !killed[u.ref]) {
+			continue
+		}
+
+		spritePath := SelectEnemySprite(enemy, i, avgLevel, assetsPath)
+		eSprite, err := loadEnemy(enemy, spritePath)
+		if err != nil {
+			continue
+		}
+		raw[i] = eSprite
+		paths[i] = spritePath
+		formation = append(formation, newFormationUnit(i, spritePath, eSprite, enemy.IsBoss))
+	}
+
+	// Resize and place every enemy that made it into the formation
+	formation = sc.collapseOverflow(formation)
+	zone := layout.Zone
+	if sc.overflow > 0 {
+		reserve := int(overflowBadgeSize(theme)) + 18
+		zone.Y, zone.H = zone.Y+reserve, zone.H-reserve
+	}
+	slots, scale, solved := theme.enemyFormation(formation, zone)
+	for n, f := range formation {
+		u := sc.enemies[f.index]
+		u.sprite = imaging.Resize(raw[f.index], int(slots[n].w), 0, imaging.Lanczos)
+		u.spriteFile = filepath.Base(paths[f.index])
+		u.x, u.y = slots[n].x, slots[n].y
+		u.hpBar = true
+		u.scale = scale
+		u.feetOrder = solved
+
+		// Tint Red if dead
+		if req.Enemies[f.index].CurrentHP <= 0 {
+			u.fade = 1
+		}
+	}
+
+	// 3. UI Base Layer
+	for _, p := range append(append([]Panel{}, theme.Panels...), theme.Banner.Panel) {
+		if (p.Show == "battle" && sc.duel) || (p.Show == "duel" && !sc.duel) {
+			continue
+		}
+		if img := sc.panelImage(p, 1); img != nil {
+			sc.hud = append(sc.hud, hudImage{img, p.X, p.Y})
+		}
+	}
+	if img, box := sc.logPanel(); img != nil {
+		sc.hud = append(sc.hud, hudImage{img, box.X, box.Y})
+	}
+
+	// 4. Players - one HUD card each, plus the leader's battlefield sprite
+	if sc.duel {
+		sc.layoutDuel()
+	} else {
+		sc.party = theme.partyLayout(len(req.Players), theme.Party.Zone.rect(), false)
+	}
+	for i, p := range req.Players {
+		u := &sceneUnit{
+			ref:       unitRef{sidePlayer, i},
+			name:      p.Name,
+			hp:        float64(p.CurrentHP),
+			maxHP:     float64(p.MaxHP),
+			energy:    float64(p.Energy),
+			maxEnergy: float64(p.MaxEnergy),
+			level:     p.Level,
+			effects:   p.StatusEffects,
+			floater:   floater{p.LastDamage, p.LastHeal, p.Crit},
+		}
+		sc.players = append(sc.players, u)
+
+		pSprite, err := loadCharacter(p.Class, p.SpriteIndex, p.Cosmetics, assetsPath)
+		if err != nil {
+			continue
+		}
+
+		// 5. HUD portrait (head and shoulders)
+		portrait := theme.Party.Portrait
+		s := sc.party[i].scale
+		u.portrait = characterPortrait(pSprite, p.Class, p.SpriteIndex, scaled(portrait.Width, s), scaled(portrait.Height, s), assetsPath)
+		u.portraitKO = utils.TintImage(u.portrait, deadTint)
+
+		if sc.duel {
+			sc.placeDuelist(u, pSprite)
+			continue
+		}
+
+		// 6. Second Sprite (Small full-body on battlefield) - PvE only
+		if i == 0 && req.CombatType != "PVP" {
+			u.sprite = imaging.Resize(pSprite, int(theme.Leader.Width), 0, imaging.Lanczos)
+			u.x, u.y = theme.Leader.X, theme.Leader.Y
+			u.shadow = theme.Leader.Shadow
+			if p.CurrentHP <= 0 {
+				u.fade = 1
+			}
+		}
+	}
+
+	sc.buildTimeline(avgLevel)
+	sc.buildEffects()
+
+	// Shadows stay on the ground while sprites animate, so bake them in once
+	for _, units := range [][]*sceneUnit{sc.enemies, sc.players} {
+		for _, u := range units {
+			if u.sprite == nil {
+				continue
+			}
+			b := u.sprite.Bounds()
+			w, h := float64(b.Dx()), float64(b.Dy())
+			if u.shadow > 0 {
+				utils.DrawShadow(bg, u.x+w/2, u.y+h, u.shadow, 0.6)
+			} else {
+				utils.DrawShadow(bg, u.x+w/2, u.y+h-10, w*0.4, 0.6)
+			}
+		}
+	}
+	sc.background = bg.Image()
+
+	// 7. Banner Text
+	if req.Rank != "" || len(req.Players) > 0 {
+		text := req.Rank
+		if text == "" && len(req.Players) > 0 {
+			text = req.Players[0].AdventurerRank
+		}
+		if text == "" {
+			text = "F"
+		}
+		sc.banner = text + " RANK"
+
+		if req.CombatType == "PVP" {
+			sc.banner = "PVP MATCH"
+		}
+	}
+
+	return sc
+}
+
+// enemySpriteHeader lists the sprite file drawn for each enemy in request
+// order, empty for enemies that weren't drawn
+func (sc *combatScene) enemySpriteHeader() string {
+	files := make([]string, len(sc.enemies))
+	for i, u := range sc.enemies {
+		files[i] = u.spriteFile
+	}
+	return strings.Join(files, ",")
+}
+
+// render draws the scene onto a fresh canvas
+func (sc *combatScene) render() image.Image {
+	dc := gg.NewContext(sc.theme.Width, sc.theme.Height)
+	sc.draw(dc)
+	return dc.Image()
+}
+
+// draw paints the full scene using each unit's current animation state
+func (sc *combatScene) draw(dc *gg.Context) {
+	utils.Blit(dc, sc.background, 0, 0)
+
+	// Battlefield units, sorted by Y (Painter's Algorithm)
+	var units []*sceneUnit
+	for _, u := range append(append([]*sceneUnit{}, sc.enemies...), sc.players...) {
+		if u.sprite != nil {
+			units = append(units, u)
+		}
+	}
+	sort.SliceStable(units, func(i, j int) bool {
+		return units[i].depth() < units[j].depth()
+	})
+	// Solved formations overlap rows, so their bars go on top of every sprite
+	for _, u := range units {
+		sc.drawSprite(dc, u)
+		if !u.feetOrder {
+			sc.drawUnitUI(dc, u)
+		}
+	}
+	for _, u := range units {
+		if u.feetOrder {
+			sc.drawUnitUI(dc, u)
+		}
+	}
+
+	sc.drawOverflowBadge(dc)
+	if sc.weather != nil {
+		sc.weather.draw(dc, sc.clock)
+	}
+
+	// UI Base Layer
+	for _, h := range sc.hud {
+		utils.Blit(dc, h.img, h.x, h.y)
+	}
+
+	// Party HUD
+	for i, p := range sc.players {
+		sc.drawPlayerHUD(dc, p, sc.party[i])
+	}
+
+	// Banner Text (Overlaid ON the banner)
+	if sc.banner != "" {
+		banner := sc.theme.Banner
+		sc.drawText(dc, banner.Text, sc.banner, float64(banner.X), float64(banner.Y), 1)
+	}
+
+	sc.drawEffects(dc)
+	if !sc.hideFloaters {
+		sc.drawFloaters(dc)
+	}
+	sc.drawTimeline(dc)
+}
+
+// drawSprite draws a battlefield sprite with its animation state
+func (sc *combatScene) drawSprite(dc *gg.Context, u *sceneUnit) {
+	x, y := u.x+u.dx, u.y+u.dy
+	utils.DrawImageAlpha(dc, u.sprite, int(x), int(y), 1-u.fade)
+	if u.fade > 0 {
+		utils.DrawImageAlpha(dc, u.tint(deadTint), int(x), int(y), u.fade)
+	}
+	if u.flash > 0 {
+		utils.DrawImageAlpha(dc, u.tint(u.flashTint), int(x), int(y), u.flash)
+	}
+}
+
+// drawUnitUI draws, when enabled, the HP bar, status row and name plate above
+// a battlefield sprite
+func (sc *combatScene) drawUnitUI(dc *gg.Context, u *sceneUnit) {
+	style := sc.theme.Units
+	w := float64(u.sprite.Bounds().Dx())
+	x, y := u.x+u.dx, u.y+u.dy
+
+	// HP bar above the head: a UI image stretched to the remaining HP, or flat
+	bar := style.HPBar
+	s := u.uiScale()
+	if u.hpBar && u.hpPercent() > 0 {
+		bw, bh := float64(bar.W)*s, float64(bar.H)*s
+		bx := x + (w-bw)/2
+		by := y + float64(bar.OffsetY)*s
+		if bar.Image != "" {
+			hpBarImg, err := utils.LoadImage(sc.uiPath(bar.Image))
+			if err == nil {
+				currentBarW := int(bw * u.hpPercent())
+				if currentBarW < 1 {
+					currentBarW = 1
+				}
+				hpBarImg = imaging.Resize(hpBarImg, currentBarW, max(1, int(bh)), imaging.NearestNeighbor)
+				dc.DrawImage(hpBarImg, int(bx), int(by))
+			}
+		} else {
+			drawFlatBar(dc, bx, by, bw, bh, u.hp, u.maxHP, bar.Fill, bar.Back, bar.Radius*s)
+		}
+	}
+	if u.hpBar && u.hp > 0 {
+		sc.drawStatusRow(dc, style.Status, u.effects, x+w/2, y, s)
+	}
+
+	cx := x + w/2
+	raise := 0.0
+	if u.nameplate && u.name != "" {
+		sc.drawText(dc, style.Name, u.name, cx, y, 1)
+		raise = style.LabelRaise
+	}
+
+	if u.label != "" {
+		sc.drawText(dc, style.Label, u.label, cx, y-raise, 1)
+	}
+}
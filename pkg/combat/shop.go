@@ -0,0 +1,200 @@
+package combat
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"strings"
+
+	"image-service/pkg/utils"
+
+	"github.com/fogleman/gg"
+	"github.com/gin-gonic/gin"
+	"golang.org/x/image/font"
+)
+
+// ShopRequest is a merchant's window: their wares, one page at a time, and
+// the gold the player has to spend
+type ShopRequest struct {
+	Name        string     `json:"name"`     // Shop title
+	Greeting    string     `json:"greeting"` // Shown in the merchant's speech bubble
+	Merchant    string     `json:"merchant"` // Character class drawn as the shopkeeper, MERCHANT by default
+	SpriteIndex int        `json:"spriteIndex"`
+	Items       []ShopItem `json:"items"`
+	Gold        int        `json:"gold"`
+	Page        int        `json:"page"` // 1-based
+
+	Background string `json:"background"`
+	Biome      string `json:"biome"`
+	Seed       Seed   `json:"seed"`
+}
+
+// ShopItem is one ware. Stock is how many are left; omitted means unlimited
+// and 0 is sold out.
+type ShopItem struct {
+	Item
+	Price int  `json:"price"`
+	Stock *int `json:"stock"`
+}
+
+const (
+	shopCols = 3
+	shopRows = 4
+)
+
+var (
+	unaffordableColor = color.RGBA{255, 110, 100, 255}
+	mutedColor        = color.RGBA{170, 170, 185, 255}
+)
+
+func GenerateShop(c *gin.Context) {
+	var req ShopRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	if err := checkBackground(req.Background); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+
+	buf, err := utils.EncodeImageToBuffer(renderShop(&req, "assets"))
+	if err != nil {
+		c.JSON(500, gin.H{"error": "Failed to encode image"})
+		return
+	}
+
+	c.Data(200, "image/png", buf)
+}
+
+// renderShop draws the shopkeeper and gold on the left and the page of wares
+// on the right
+func renderShop(req *ShopRequest, assetsPath string) image.Image {
+	dc := gg.NewContext(CANVAS_W, CANVAS_H)
+	setFont := cardFont(dc)
+
+	drawBlurredArena(dc, &CombatRequest{Background: req.Background, Biome: req.Biome, Seed: req.Seed}, assetsPath)
+
+	merchant := strings.ToUpper(strings.TrimSpace(req.Merchant))
+	if merchant == "" {
+		merchant = "MERCHANT"
+	}
+	title := req.Name
+	if title == "" {
+		title = classDisplayName(merchant, req.SpriteIndex)
+	}
+	if face := setFont(64); face != nil {
+		drawInk(dc, face, truncateText(dc, strings.ToUpper(title), CANVAS_W-80), CANVAS_W/2, 46, 0.5, 0.5, victoryColor)
+	}
+
+	drawStandingCharacter(dc, merchant, req.SpriteIndex, Cosmetics{}, "", setFont, image.Rect(24, 200, 300, 620), assetsPath)
+	if req.Greeting != "" {
+		drawSpeechBubble(dc, req.Greeting, setFont, image.Rect(24, 96, 300, 196))
+	}
+	drawGoldBalance(dc, req.Gold, setFont, image.Rect(24, 604, 300, 672))
+	drawShopGrid(dc, req, setFont, image.Rect(316, 96, 1000, 672), assetsPath)
+	return dc.Image()
+}
+
+// drawShopGrid draws one page of wares: icon, name, price and stock. Prices
+// the player can't afford are red and sold out wares are dimmed.
+func drawShopGrid(dc *gg.Context, req *ShopRequest, setFont func(float64) font.Face, r image.Rectangle, assetsPath string) {
+	drawPanel(dc, r)
+	x0, y0 := float64(r.Min.X)+16, float64(r.Min.Y)+14
+	if face := setFont(24); face != nil {
+		drawInk(dc, face, "WARES", x0, y0, 0, 0, color.White)
+	}
+	if len(req.Items) == 0 {
+		if face := setFont(22); face != nil {
+			drawInk(dc, face, "Sold out", float64(r.Min.X+r.Max.X)/2, float64(r.Min.Y+r.Max.Y)/2, 0.5, 0.5, mutedColor)
+		}
+		return
+	}
+
+	page, pages, start, end := pageOf(req.Page, len(req.Items), shopCols*shopRows)
+	if face := setFont(18); face != nil && pages > 1 {
+		drawInk(dc, face, fmt.Sprintf("Page %d / %d", page, pages), float64(r.Max.X)-16, y0+10, 1, 0.5, mutedColor)
+	}
+
+	gap := 10.0
+	top := y0 + 36
+	cellW := (float64(r.Dx()) - 32 - gap*(shopCols-1)) / shopCols
+	cellH := (float64(r.Max.Y) - 14 - top - gap*(shopRows-1)) / shopRows
+	for i, item := range req.Items[start:end] {
+		x := x0 + float64(i%shopCols)*(cellW+gap)
+		y := top + float64(i/shopCols)*(cellH+gap)
+		rc := rarityColor(item.Rarity)
+		soldOut := item.Stock != nil && *item.Stock <= 0
+
+		drawItemSlot(dc, x, y, cellW, cellH, rc)
+		if face := setFont(18); face != nil {
+			drawInk(dc, face, truncateText(dc, item.Name, cellW-20), x+10, y+18, 0, 0.5, color.White)
+		}
+		icon := cellH - 44
+		drawItemIcon(dc, item.Item, x+14+icon/2, y+34+icon/2, icon, assetsPath)
+
+		tx := x + icon + 26
+		priceColor := color.Color(victoryColor)
+		if item.Price > req.Gold {
+			priceColor = unaffordableColor
+		}
+		drawCoin(dc, tx+7, y+34+icon/2, 7)
+		if face := setFont(18); face != nil {
+			drawInk(dc, face, fmt.Sprintf("%d", item.Price), tx+20, y+34+icon/2, 0, 0.5, priceColor)
+		}
+		if face := setFont(14); face != nil && item.Stock != nil && !soldOut {
+			drawInk(dc, face, fmt.Sprintf("x%d", *item.Stock), x+cellW-10, y+34+icon/2, 1, 0.5, mutedColor)
+		}
+
+		if soldOut {
+			dc.DrawRoundedRectangle(x, y, cellW, cellH, 8)
+			dc.SetColor(color.NRGBA{0, 0, 0, 150})
+			dc.Fill()
+			if face := setFont(24); face != nil {
+				drawInk(dc, face, "SOLD OUT", x+cellW/2, y+cellH/2, 0.5, 0.5, defeatColor)
+			}
+		}
+	}
+}
+
+// drawSpeechBubble draws text in a rounded bubble with a tail pointing down
+// at whoever is under r
+func drawSpeechBubble(dc *gg.Context, text string, setFont func(float64) font.Face, r image.Rectangle) {
+	x, y := float64(r.Min.X), float64(r.Min.Y)
+	w, h := float64(r.Dx()), float64(r.Dy())-14
+	dc.DrawRoundedRectangle(x, y, w, h, 14)
+	cx := x + w/2
+	dc.MoveTo(cx-12, y+h)
+	dc.LineTo(cx, y+h+14)
+	dc.LineTo(cx+12, y+h)
+	dc.SetColor(color.NRGBA{245, 238, 220, 235})
+	dc.Fill()
+
+	if setFont(18) == nil {
+		return
+	}
+	lines := dc.WordWrap(text, w-24)
+	if len(lines) > 3 {
+		lines = lines[:3]
+		lines[2] = truncateText(dc, lines[2]+"...", w-24)
+	}
+	lh := 23.0
+	ty := y + h/2 - lh*float64(len(lines)-1)/2
+	dc.SetColor(color.RGBA{40, 30, 20, 255})
+	for i, line := range lines {
+		dc.DrawStringAnchored(line, cx, ty+float64(i)*lh, 0.5, 0.35)
+	}
+}
+
+// drawGoldBalance draws the player's gold in a small panel
+func drawGoldBalance(dc *gg.Context, gold int, setFont func(float64) font.Face, r image.Rectangle) {
+	drawPanel(dc, r)
+	cy := float64(r.Min.Y+r.Max.Y) / 2
+	drawCoin(dc, float64(r.Min.X)+30, cy, 12)
+	if face := setFont(20); face != nil {
+		drawInk(dc, face, "Gold", float64(r.Min.X)+52, cy, 0, 0.5, mutedColor)
+	}
+	if face := setFont(30); face != nil {
+		drawInk(dc, face, fmt.Sprintf("%d", gold), float64(r.Max.X)-18, cy, 1, 0.5, victoryColor)
+	}
+}
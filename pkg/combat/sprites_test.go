@@ -0,0 +1,99 @@
+package combat
+
+import (
+	"math"
+	"path/filepath"
+	"testing"
+)
+
+// useManifest makes m the active manifest for the rest of the test
+func useManifest(t *testing.T, m *Manifest) {
+	t.Helper()
+	manifestMu.Lock()
+	prev := currentManifest
+	currentManifest = m
+	manifestMu.Unlock()
+	t.Cleanup(func() {
+		manifestMu.Lock()
+		currentManifest = prev
+		manifestMu.Unlock()
+	})
+}
+
+func spriteGroup(files ...string) *SpriteGroup {
+	g := &SpriteGroup{}
+	for _, f := range files {
+		g.Sprites = append(g.Sprites, SpriteMeta{File: f})
+	}
+	return g
+}
+
+func TestSelectEnemySprite(t *testing.T) {
+	useManifest(t, &Manifest{
+		Enemies: map[string]*SpriteGroup{
+			"SLIME":      spriteGroup("slime0.png", "slime1.png", "slime2.png"),
+			"FIRE_LOW":   spriteGroup("fire0.png", "fire1.png"),
+			"FIRE_HIGH":  spriteGroup("firehigh0.png"),
+			"WATER_LOW":  spriteGroup("water0.png", "water1.png", "water2.png"),
+			"EARTH_MID":  spriteGroup("earth0.png", "earth1.png"),
+			"ICE_MID":    spriteGroup("ice0.png"),
+			"FIRE_ELITE": spriteGroup("elite0.png"),
+		},
+		Bosses: map[string]*SpriteGroup{
+			"MID_BOSSES": spriteGroup("boss0.png", "boss1.png"),
+		},
+	})
+	tests := []struct {
+		name  string
+		enemy Enemy
+		index int
+		party int
+		want  string
+	}{
+		{"key", Enemy{SpriteKey: "slime"}, 1, 1, "slime1.png"},
+		{"key with index", Enemy{SpriteKey: "SLIME", SpriteIndex: 2}, 1, 1, "slime2.png"},
+		{"element", Enemy{Element: "fire"}, 1, 1, "fire1.png"},
+		{"element and tier", Enemy{Element: "fire", Tier: "high"}, 0, 1, "firehigh0.png"},
+		{"nearest tier", Enemy{Element: "water", Tier: "elite"}, 0, 1, "water0.png"},
+		{"element with index", Enemy{Element: "fire", SpriteIndex: 2}, 1, 1, "fire0.png"},
+		{"tier", Enemy{Tier: "mid", SpriteIndex: 2}, 0, 1, "ice0.png"},
+		{"boss tier", Enemy{IsBoss: true, Tier: "mid"}, 3, 1, "boss1.png"},
+		{"enemy level", Enemy{Level: 15}, 1, 1, "water1.png"},
+		{"party level", Enemy{}, 1, 25, "earth1.png"},
+		{"level with index", Enemy{Level: 15, SpriteIndex: 2}, 0, 1, "water2.png"},
+		{"boss level with index", Enemy{IsBoss: true, Level: 40, SpriteIndex: 1}, 0, 1, "boss1.png"},
+	}
+	for _, tt := range tests {
+		got := filepath.Base(SelectEnemySprite(tt.enemy, tt.index, tt.party, t.TempDir()))
+		if got != tt.want {
+			t.Errorf("%s: %s; want %s", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestCharacterSprite(t *testing.T) {
+	useManifest(t, &Manifest{
+		Characters: map[string]*SpriteGroup{
+			"FIGHTER": spriteGroup("fighter0.png", "fighter1.png", "fighter2.png"),
+			"MAGE":    spriteGroup("mage0.png", "mage1.png"),
+		},
+	})
+	tests := []struct {
+		class string
+		index int
+		want  string
+	}{
+		{"MAGE", 0, "mage0.png"},
+		{"MAGE", 3, "mage1.png"},
+		{"MAGE", -1, "mage1.png"},
+		{"MAGE", math.MinInt, "mage0.png"},
+		{"FIGHTER", math.MinInt, "fighter1.png"},
+		{"FIGHTER", math.MaxInt, "fighter1.png"},
+		{"ROGUE", 2, "fighter2.png"},
+	}
+	for _, tt := range tests {
+		if got := CharacterSprite(tt.class, tt.index).File; got != tt.want {
+			t.Errorf("CharacterSprite(%s, %d) = %s; want %s", tt.class, tt.index, got, tt.want)
+		}
+	}
+}
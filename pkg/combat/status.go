@@ -0,0 +1,397 @@
+package combat
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"math"
+	"path/filepath"
+	"strings"
+	"sync"
+	"unicode"
+
+	"image-service/pkg/utils"
+
+	"github.com/disintegration/imaging"
+	"github.com/fogleman/gg"
+)
+
+// statusAliases maps the names the bot uses onto the built-in icon set
+var statusAliases = map[string]string{
+	"poisoned": "poison", "toxic": "poison",
+	"burning": "burn", "burned": "burn", "ignite": "burn",
+	"stunned": "stun", "paralyze": "stun", "paralyzed": "stun",
+	"frozen": "freeze", "frost": "freeze", "chill": "freeze",
+	"shielded": "shield", "barrier": "shield", "ward": "shield",
+	"bleeding":     "bleed",
+	"regeneration": "regen", "regenerate": "regen",
+	"asleep": "sleep", "silenced": "silence",
+	"haste": "speed_up", "slow": "speed_down", "slowed": "speed_down",
+	"atk_up": "attack_up", "atk_down": "attack_down", "strength": "attack_up", "weaken": "attack_down",
+	"def_up": "defense_up", "def_down": "defense_down", "armor_break": "defense_down", "vulnerable": "defense_down",
+	"spd_up": "speed_up", "spd_down": "speed_down",
+	"mag_up": "magic_up", "mag_down": "magic_down",
+}
+
+// statKinds colors the stat buff/debuff arrows (<stat>_up, <stat>_down)
+var statKinds = map[string]color.RGBA{
+	"attack":  {192, 57, 43, 255},
+	"defense": {46, 111, 216, 255},
+	"speed":   {47, 163, 90, 255},
+	"magic":   {142, 68, 173, 255},
+}
+
+// statusGlyph is a built-in icon: a tile color and a white symbol drawn in a
+// 100x100 box. gg doesn't scale line widths, so draw gets the px per unit.
+type statusGlyph struct {
+	bg     color.RGBA
+	draw   func(dc *gg.Context, k float64)
+	debuff bool
+}
+
+var statusGlyphs = map[string]statusGlyph{
+	"poison":  {color.RGBA{62, 155, 58, 255}, drawDrop, true},
+	"bleed":   {color.RGBA{176, 32, 42, 255}, drawDrop, true},
+	"burn":    {color.RGBA{224, 102, 27, 255}, drawFlame, true},
+	"stun":    {color.RGBA{212, 160, 23, 255}, func(dc *gg.Context, _ float64) { drawStarShape(dc, 50, 52, 38, 16, 5) }, true},
+	"freeze":  {color.RGBA{56, 168, 216, 255}, drawSnowflake, true},
+	"sleep":   {color.RGBA{91, 95, 199, 255}, drawZ, true},
+	"silence": {color.RGBA{122, 122, 140, 255}, drawSilence, true},
+	"shield":  {color.RGBA{47, 111, 208, 255}, drawShieldShape, false},
+	"regen":   {color.RGBA{47, 163, 90, 255}, drawPlus, false},
+}
+
+// normalizeStatus folds spelling variants ("Atk Up", "atk-up") onto one key
+func normalizeStatus(kind string) string {
+	k := strings.ToLower(strings.TrimSpace(kind))
+	k = strings.NewReplacer(" ", "_", "-", "_").Replace(k)
+	if alias, ok := statusAliases[k]; ok {
+		return alias
+	}
+	return k
+}
+
+var statusIcons sync.Map // "font|kind@size" -> image.Image
+
+// statusIcon returns the icon for a status effect at size px. A PNG in
+// rpgasset/ui/status/<kind>.png replaces the built-in drawing. Only kinds
+// with an icon and ASCII letter tiles are cached, so made-up kinds can't
+// grow the cache.
+func (sc *combatScene) statusIcon(kind string, size int) image.Image {
+	kind = normalizeStatus(kind)
+	custom := ""
+	if kind != "" && !strings.ContainsAny(kind, `/\.`) {
+		custom = filepath.Join(sc.assetsPath, "rpgasset", "ui", "status", kind+".png")
+	}
+	name := kind
+	if !hasStatusGlyph(kind) && (custom == "" || !fileExists(custom)) {
+		custom = ""
+		letter := statusLetter(kind)
+		if len(letter) != 1 || !unicode.In(rune(letter[0]), unicode.Upper, unicode.Digit) {
+			return sc.drawStatusIcon(kind, size)
+		}
+		name = "letter:" + letter
+	}
+
+	key := fmt.Sprintf("%s|%s@%d", sc.theme.Font, name, size)
+	if img, ok := statusIcons.Load(key); ok {
+		return img.(image.Image)
+	}
+	var img image.Image
+	if custom != "" {
+		if src, err := utils.LoadImage(custom); err == nil {
+			img = imaging.Fit(src, size, size, imaging.Lanczos)
+		}
+	}
+	if img == nil {
+		img = sc.drawStatusIcon(kind, size)
+	}
+	statusIcons.Store(key, img)
+	return img
+}
+
+// hasStatusGlyph reports whether kind has a built-in icon
+func hasStatusGlyph(kind string) bool {
+	if _, ok := statusGlyphs[kind]; ok {
+		return true
+	}
+	stat, dir, isStat := strings.Cut(kind, "_")
+	_, known := statKinds[stat]
+	return isStat && known && (dir == "up" || dir == "down")
+}
+
+// statusLetter is the letter shown for kinds without an icon
+func statusLetter(kind string) string {
+	if kind == "" {
+		return ""
+	}
+	return strings.ToUpper(string([]rune(kind)[0]))
+}
+
+// drawStatusIcon draws a built-in icon. Stat changes get an arrow in the
+// stat's color; unknown effects show their first letter.
+func (sc *combatScene) drawStatusIcon(kind string, size int) image.Image {
+	dc := gg.NewContext(size, size)
+	k := float64(size) / 100
+	dc.Scale(k, k)
+
+	glyph, ok := statusGlyphs[kind]
+	stat, dir, isStat := strings.Cut(kind, "_")
+	if !ok && isStat && (dir == "up" || dir == "down") {
+		if bg, known := statKinds[stat]; known {
+			glyph, ok = statusGlyph{bg, func(dc *gg.Context, _ float64) { drawArrow(dc, dir == "up") }, dir == "down"}, true
+		}
+	}
+	if !ok {
+		glyph = statusGlyph{bg: color.RGBA{90, 90, 105, 255}}
+	}
+
+	// Tile: debuffs get a red rim, everything else a dark one
+	dc.DrawRoundedRectangle(3, 3, 94, 94, 20)
+	dc.SetColor(glyph.bg)
+	dc.FillPreserve()
+	rim := color.Color(color.NRGBA{0, 0, 0, 170})
+	if glyph.debuff {
+		rim = color.RGBA{120, 10, 10, 255}
+	}
+	dc.SetColor(rim)
+	dc.SetLineWidth(math.Max(1, 6*k))
+	dc.Stroke()
+
+	dc.SetColor(color.White)
+	if glyph.draw != nil {
+		glyph.draw(dc, k)
+	} else if face, err := sc.face(64 * k); err == nil && kind != "" {
+		dc.Identity()
+		dc.SetFontFace(face)
+		letter := statusLetter(kind)
+		ox, oy := inkOrigin(face, letter, float64(size)/2, float64(size)/2, 0.5, 0.5)
+		dc.DrawString(letter, ox, oy)
+	}
+	return dc.Image()
+}
+
+func drawDrop(dc *gg.Context, _ float64) {
+	dc.MoveTo(50, 12)
+	dc.LineTo(74, 56)
+	dc.LineTo(26, 56)
+	dc.ClosePath()
+	dc.Fill()
+	dc.DrawCircle(50, 62, 25)
+	dc.Fill()
+}
+
+func drawFlame(dc *gg.Context, _ float64) {
+	dc.MoveTo(50, 10)
+	dc.QuadraticTo(86, 46, 76, 70)
+	dc.QuadraticTo(68, 90, 50, 90)
+	dc.QuadraticTo(32, 90, 24, 70)
+	dc.QuadraticTo(16, 50, 36, 34)
+	dc.QuadraticTo(36, 52, 44, 56)
+	dc.QuadraticTo(38, 30, 50, 10)
+	dc.ClosePath()
+	dc.SetColor(color.RGBA{255, 224, 138, 255})
+	dc.Fill()
+	dc.DrawEllipse(50, 72, 12, 14)
+	dc.SetColor(color.White)
+	dc.Fill()
+}
+
+// drawStarShape fills an n-pointed star
+func drawStarShape(dc *gg.Context, cx, cy, outer, inner float64, n int) {
+	for i := 0; i < n*2; i++ {
+		r := outer
+		if i%2 == 1 {
+			r = inner
+		}
+		a := float64(i)*math.Pi/float64(n) - math.Pi/2
+		dc.LineTo(cx+math.Cos(a)*r, cy+math.Sin(a)*r)
+	}
+	dc.ClosePath()
+	dc.Fill()
+}
+
+func drawSnowflake(dc *gg.Context, k float64) {
+	dc.SetLineWidth(8 * k)
+	dc.SetLineCapRound()
+	for i := 0; i < 3; i++ {
+		a := float64(i) * math.Pi / 3
+		dx, dy := math.Cos(a)*36, math.Sin(a)*36
+		dc.DrawLine(50-dx, 50-dy, 50+dx, 50+dy)
+		for _, end := range []float64{1, -1} {
+			tx, ty := 50+dx*0.6*end, 50+dy*0.6*end
+			for _, side := range []float64{1, -1} {
+				b := a + side*math.Pi/4
+				if end < 0 {
+					b += math.Pi
+				}
+				dc.DrawLine(tx, ty, tx+math.Cos(b)*13, ty+math.Sin(b)*13)
+			}
+		}
+	}
+	dc.Stroke()
+}
+
+func drawZ(dc *gg.Context, k float64) {
+	dc.SetLineWidth(11 * k)
+	dc.SetLineJoinRound()
+	dc.SetLineCapRound()
+	dc.MoveTo(28, 26)
+	dc.LineTo(72, 26)
+	dc.LineTo(28, 74)
+	dc.LineTo(72, 74)
+	dc.Stroke()
+}
+
+func drawSilence(dc *gg.Context, k float64) {
+	dc.SetLineWidth(9 * k)
+	dc.DrawCircle(50, 50, 30)
+	dc.Stroke()
+	dc.DrawLine(29, 71, 71, 29)
+	dc.Stroke()
+}
+
+func drawShieldShape(dc *gg.Context, _ float64) {
+	dc.MoveTo(50, 12)
+	dc.LineTo(80, 24)
+	dc.LineTo(78, 50)
+	dc.QuadraticTo(72, 76, 50, 88)
+	dc.QuadraticTo(28, 76, 22, 50)
+	dc.LineTo(20, 24)
+	dc.ClosePath()
+	dc.Fill()
+}
+
+func drawPlus(dc *gg.Context, _ float64) {
+	dc.DrawRoundedRectangle(40, 18, 20, 64, 5)
+	dc.DrawRoundedRectangle(18, 40, 64, 20, 5)
+	dc.Fill()
+}
+
+func drawArrow(dc *gg.Context, up bool) {
+	pts := [][2]float64{{50, 12}, {84, 48}, {63, 48}, {63, 88}, {37, 88}, {37, 48}, {16, 48}}
+	for _, p := range pts {
+		y := p[1]
+		if !up {
+			y = 100 - y
+		}
+		dc.LineTo(p[0], y)
+	}
+	dc.ClosePath()
+	dc.Fill()
+}
+
+// drawStatusRow draws a unit's effects as icons with stack (top right) and
+// remaining turn (bottom right) counters. Effects past st.Max collapse into
+// a "+N" tile. (x, y) is the row's anchor point; s scales the style.
+func (sc *combatScene) drawStatusRow(dc *gg.Context, st StatusStyle, effects []StatusEffect, x, y, s float64) {
+	if st.Max <= 0 || st.Size <= 0 || len(effects) == 0 {
+		return
+	}
+	size := math.Max(14, st.Size*s)
+	gap := st.Gap * s
+	n := len(effects)
+	more := 0
+	if n > st.Max {
+		n = st.Max
+		more = len(effects) - (st.Max - 1)
+	}
+	total := float64(n)*size + float64(n-1)*gap
+	ix := x + st.X*s - st.AnchorX*total
+	iy := y + st.Y*s
+
+	face, err := sc.face(math.Max(10, size*0.5))
+	if err == nil {
+		dc.SetFontFace(face)
+	}
+	counter := func(text string, cx, cy float64, ay float64) {
+		if err == nil {
+			drawInkOutlined(dc, face, text, cx, cy, 1, ay, color.White, color.Black, 1.5)
+		}
+	}
+
+	for i := 0; i < n; i++ {
+		px := ix + float64(i)*(size+gap)
+		if more > 0 && i == n-1 {
+			dc.DrawRoundedRectangle(px, iy, size, size, size*0.2)
+			dc.SetColor(color.NRGBA{0, 0, 0, 170})
+			dc.Fill()
+			if err == nil {
+				drawInk(dc, face, fmt.Sprintf("+%d", more), px+size/2, iy+size/2, 0.5, 0.5, color.White)
+			}
+			break
+		}
+		e := effects[i]
+		utils.Blit(dc, sc.statusIcon(e.Type, int(size)), int(px), int(iy))
+		if e.Stacks > 1 {
+			counter(fmt.Sprintf("%d", e.Stacks), px+size+1, iy-1, 0)
+		}
+		if e.Turns > 0 {
+			counter(fmt.Sprintf("%d", e.Turns), px+size+1, iy+size+1, 1)
+		}
+	}
+}
+
+// floater is a damage or heal number drawn over a unit
+type floater struct {
+	damage, heal int
+	crit         bool
+}
+
+// drawFloaters draws each unit's last damage / heal over its battlefield
+// sprite, or over its HUD portrait when it has none
+func (sc *combatScene) drawFloaters(dc *gg.Context) {
+	style := sc.theme.Units.Float
+	if style.Size <= 0 {
+		return
+	}
+	for _, units := range [][]*sceneUnit{sc.enemies, sc.players} {
+		for _, u := range units {
+			f := u.floater
+			if f.damage <= 0 && f.heal <= 0 {
+				continue
+			}
+			fy := 0.3 // Above the chest on sprites, centered on portraits
+			if u.sprite == nil {
+				fy = 0.5
+			}
+			a, ok := sc.anchor(u, fy)
+			if !ok {
+				continue
+			}
+			sc.drawFloater(dc, style, f, a.x, a.y, a.scale)
+		}
+	}
+}
+
+func (sc *combatScene) drawFloater(dc *gg.Context, style FloatStyle, f floater, cx, cy, s float64) {
+	size := style.Size * s
+	if f.damage > 0 {
+		c := themeColor(style.Damage, color.RGBA{255, 75, 62, 255})
+		ds := size
+		if f.crit {
+			c = themeColor(style.Crit, color.RGBA{255, 197, 49, 255})
+			ds = size * 1.4
+			dc.SetColor(color.NRGBA{255, 120, 30, 150})
+			drawStarShape(dc, cx, cy, ds*0.95, ds*0.5, 10)
+		}
+		if face, err := sc.face(ds); err == nil {
+			dc.SetFontFace(face)
+			drawInkOutlined(dc, face, fmt.Sprintf("-%d", f.damage), cx, cy, 0.5, 0.5, c, color.RGBA{30, 0, 0, 255}, math.Max(2, ds/14))
+		}
+		if f.crit {
+			if face, err := sc.face(size * 0.5); err == nil {
+				dc.SetFontFace(face)
+				drawInkOutlined(dc, face, "CRIT!", cx, cy-ds*0.55, 0.5, 1, color.White, color.RGBA{30, 0, 0, 255}, 2)
+			}
+		}
+		cy += ds * 0.9
+	}
+	if f.heal > 0 {
+		if face, err := sc.face(size); err == nil {
+			dc.SetFontFace(face)
+			c := themeColor(style.Heal, color.RGBA{92, 224, 122, 255})
+			drawInkOutlined(dc, face, fmt.Sprintf("+%d", f.heal), cx, cy, 0.5, 0.5, c, color.RGBA{0, 30, 10, 255}, math.Max(2, size/14))
+		}
+	}
+}
@@ -0,0 +1,104 @@
+package combat
+
+import (
+	"image"
+	"image/png"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestNormalizeStatus(t *testing.T) {
+	tests := []struct {
+		kind string
+		want string
+	}{
+		{"poison", "poison"},
+		{" Poisoned ", "poison"},
+		{"Atk Up", "attack_up"},
+		{"def-down", "defense_down"},
+		{"armor_break", "defense_down"},
+		{"Magic Up", "magic_up"},
+		{"Doom", "doom"},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		if got := normalizeStatus(tt.kind); got != tt.want {
+			t.Errorf("normalizeStatus(%q) = %q; want %q", tt.kind, got, tt.want)
+		}
+	}
+}
+
+// writePNG writes a blank w x h PNG, making its folder
+func writePNG(t *testing.T, path string, w, h int) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if err := png.Encode(f, image.NewNRGBA(image.Rect(0, 0, w, h))); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestStatusIconCache(t *testing.T) {
+	assets := t.TempDir()
+	ui := filepath.Join(assets, "rpgasset", "ui")
+	writePNG(t, filepath.Join(ui, "status", "doom.png"), 5, 5)
+	writePNG(t, filepath.Join(ui, "secret.png"), 6, 6)
+
+	// The font is part of the key, so a unique one keeps this test's entries apart
+	th := &Theme{Font: t.Name() + ".ttf"}
+	sc := &combatScene{theme: th, assetsPath: assets}
+	cached := func() []string {
+		var keys []string
+		statusIcons.Range(func(k, _ any) bool {
+			if key := k.(string); strings.HasPrefix(key, th.Font+"|") {
+				keys = append(keys, strings.TrimPrefix(key, th.Font+"|"))
+			}
+			return true
+		})
+		return keys
+	}
+
+	tests := []struct {
+		kind string
+		size int // Icon size in px
+	}{
+		{"poison", 24},
+		{"Poisoned", 24},
+		{"atk up", 24},
+		{"doom", 5}, // ui/status/doom.png
+		{"../secret", 24},
+		{`..\secret`, 24},
+		{"zombie", 24},
+		{"zealous", 24},
+		{"ünknown", 24},
+		{"日本", 24},
+	}
+	for _, tt := range tests {
+		img := sc.statusIcon(tt.kind, 24)
+		if b := img.Bounds(); b.Dx() != tt.size || b.Dy() != tt.size {
+			t.Errorf("statusIcon(%q) is %v; want %d px", tt.kind, b, tt.size)
+		}
+	}
+	for i := 0; i < 100; i++ {
+		sc.statusIcon(strings.Repeat("x", i+1), 24)
+	}
+
+	want := map[string]bool{"poison@24": true, "attack_up@24": true, "doom@24": true, "letter:Z@24": true, "letter:X@24": true}
+	keys := cached()
+	for _, k := range keys {
+		if !want[k] {
+			t.Errorf("cached %q", k)
+		}
+	}
+	if len(keys) != len(want) {
+		t.Errorf("cached %v; want %d entries", keys, len(want))
+	}
+}
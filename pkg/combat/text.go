@@ -0,0 +1,42 @@
+package combat
+
+import (
+	"image/color"
+	"math"
+
+	"github.com/fogleman/gg"
+	"golang.org/x/image/font"
+)
+
+// inkOrigin returns where to draw text so its visible glyphs, rather than the
+// font's line box, are anchored at (x, y). For fantesy.ttf the line box sits
+// well below the requested point.
+func inkOrigin(face font.Face, text string, x, y, ax, ay float64) (float64, float64) {
+	b, _ := font.BoundString(face, text)
+	minX, minY := float64(b.Min.X)/64, float64(b.Min.Y)/64
+	maxX, maxY := float64(b.Max.X)/64, float64(b.Max.Y)/64
+	return x - minX - ax*(maxX-minX), y - minY - ay*(maxY-minY)
+}
+
+// drawInk draws ink-anchored text with a drop shadow. face must be the
+// context's current font face.
+func drawInk(dc *gg.Context, face font.Face, text string, x, y, ax, ay float64, c color.Color) {
+	ox, oy := inkOrigin(face, text, x, y, ax, ay)
+	dc.SetColor(color.RGBA{0, 0, 0, 200})
+	dc.DrawString(text, ox+2, oy+2)
+	dc.SetColor(c)
+	dc.DrawString(text, ox, oy)
+}
+
+// drawInkOutlined draws ink-anchored text with an outline of width w, for
+// numbers that have to read over busy sprites
+func drawInkOutlined(dc *gg.Context, face font.Face, text string, x, y, ax, ay float64, c, outline color.Color, w float64) {
+	ox, oy := inkOrigin(face, text, x, y, ax, ay)
+	dc.SetColor(outline)
+	for i := 0; i < 8; i++ {
+		a := float64(i) * math.Pi / 4
+		dc.DrawString(text, ox+math.Cos(a)*w, oy+math.Sin(a)*w)
+	}
+	dc.SetColor(c)
+	dc.DrawString(text, ox, oy)
+}
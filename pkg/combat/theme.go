@@ -0,0 +1,656 @@
+package combat
+
+import (
+	"encoding/json"
+	"fmt"
+	"image"
+	"image/color"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"image-service/pkg/utils"
+)
+
+// DefaultTheme is used when a request doesn't name one
+const DefaultTheme = "classic"
+
+// Aspect names. A theme's top-level layout is its landscape one; portrait and
+// square layouts are overrides listed under "aspects".
+const (
+	AspectLandscape = "landscape"
+	AspectPortrait  = "portrait"
+	AspectSquare    = "square"
+)
+
+// Largest canvas a request may ask for, per side
+const maxCanvasSide = 2048
+
+// Theme describes the combat screen so it can be reskinned without code
+// changes. Themes live in assets/rpgasset/themes/<name>.json; one may extend
+// another and only list what it changes. Coordinates are canvas pixels, except
+// inside the party card where they are relative to the card at scale 1.
+type Theme struct {
+	Name     string        `json:"-"`
+	Aspect   string        `json:"-"`
+	Extends  string        `json:"extends,omitempty"`
+	Width    int           `json:"width"`
+	Height   int           `json:"height"`
+	Font     string        `json:"font"`     // UI font in rpgasset/ui
+	Backdrop string        `json:"backdrop"` // Fill when no background loads
+	Dim      string        `json:"dim"`      // Overlay drawn over the background
+	Panels   []Panel       `json:"panels"`
+	Banner   BannerStyle   `json:"banner"`
+	Party    PartyStyle    `json:"party"`
+	Enemies  EnemyStyle    `json:"enemies"`
+	Leader   LeaderStyle   `json:"leader"`
+	Duel     DuelStyle     `json:"duel"`
+	Units    UnitStyle     `json:"units"`
+	Timeline TimelineStyle `json:"timeline"`
+	Log      LogStyle      `json:"log"`
+
+	aspects   []string // Layouts this theme (or one it extends) defines
+	cardScale float64  // Party card scale for a solo player, 0 = 1
+}
+
+// Box is a rectangle in canvas (or card) pixels
+type Box struct {
+	X int `json:"x"`
+	Y int `json:"y"`
+	W int `json:"w"`
+	H int `json:"h"`
+}
+
+func (b Box) rect() image.Rectangle {
+	return image.Rect(b.X, b.Y, b.X+b.W, b.Y+b.H)
+}
+
+// Panel is a static piece of UI: an image from rpgasset/ui stretched to the
+// box, or a flat rounded fill when no image is set
+type Panel struct {
+	Box
+	Image  string  `json:"image,omitempty"`
+	Fill   string  `json:"fill,omitempty"`
+	Stroke string  `json:"stroke,omitempty"`
+	Radius float64 `json:"radius,omitempty"`
+	Show   string  `json:"show,omitempty"` // "battle" (not a duel), "duel" or empty for always
+}
+
+// TextStyle places a line of text relative to its parent
+type TextStyle struct {
+	X       float64 `json:"x"`
+	Y       float64 `json:"y"`
+	Size    float64 `json:"size"`
+	Color   string  `json:"color"`
+	AnchorX float64 `json:"anchorX"`
+	AnchorY float64 `json:"anchorY"`
+	Shadow  bool    `json:"shadow"` // Dark drop shadow for text over art
+}
+
+// BarStyle draws a stat split over one or more segment boxes. Image bars pick
+// <image>1-5.png by how full the segment is; flat bars draw fill over back.
+type BarStyle struct {
+	Segments []Box   `json:"segments"`
+	Image    string  `json:"image,omitempty"`
+	Fill     string  `json:"fill,omitempty"`
+	Back     string  `json:"back,omitempty"`
+	Radius   float64 `json:"radius,omitempty"`
+}
+
+type BannerStyle struct {
+	Panel
+	Text TextStyle `json:"text"` // Relative to the banner box
+}
+
+type PartyStyle struct {
+	Zone     Box   `json:"zone"`     // Area the party grid fits into
+	Solo     Box   `json:"solo"`     // Full-size card position for a lone player (x, y)
+	Card     Panel `json:"card"`     // Card frame; w/h is the card size at scale 1
+	Headroom int   `json:"headroom"` // Space above the card the portrait pokes into
+	Portrait struct {
+		X      int `json:"x"`
+		Bottom int `json:"bottom"` // Portrait's bottom edge
+		Width  int `json:"width"`
+		Height int `json:"height"` // With width, the head-and-shoulders framing
+	} `json:"portrait"`
+	Icons  []Panel     `json:"icons"`
+	HP     BarStyle    `json:"hp"`
+	Energy BarStyle    `json:"energy"`
+	Name   TextStyle   `json:"name"`
+	Level  TextStyle   `json:"level"`
+	Status StatusStyle `json:"status"`
+	KO     struct {
+		Dim  string    `json:"dim"`
+		Text TextStyle `json:"text"`
+	} `json:"ko"`
+}
+
+type EnemyStyle struct {
+	X           float64 `json:"x"` // Front enemy's top-left
+	Y           float64 `json:"y"`
+	SpacingX    float64 `json:"spacingX"`
+	SpacingY    float64 `json:"spacingY"`
+	GroupShift  float64 `json:"groupShift"` // X offset for each further group of four
+	SpriteWidth float64 `json:"spriteWidth"`
+	BossScale   float64 `json:"bossScale"`
+	Zone        Box     `json:"zone"` // Area the formation must fit in; without one the slots above are used as-is
+	Max         int     `json:"max"`  // Enemies drawn before the rest collapse into a "+N more" badge, 0 = all
+}
+
+// LeaderStyle is the PvE party leader's battlefield sprite
+type LeaderStyle struct {
+	X      float64 `json:"x"`
+	Y      float64 `json:"y"`
+	Width  float64 `json:"width"`
+	Shadow float64 `json:"shadow"`
+}
+
+type DuelStyle struct {
+	ZoneA       Box     `json:"zoneA"` // HUD cards per team
+	ZoneB       Box     `json:"zoneB"`
+	Front       float64 `json:"front"` // X of team A's front line; team B mirrors it
+	Back        float64 `json:"back"`
+	Ground      float64 `json:"ground"` // Feet line of the front row
+	SpriteWidth float64 `json:"spriteWidth"`
+	CrowdWidth  float64 `json:"crowdWidth"` // Sprite width for teams of four or more
+}
+
+// UnitStyle is what's drawn above battlefield sprites. Text and bar offsets
+// are relative to the sprite's top centre.
+type UnitStyle struct {
+	HPBar struct {
+		W       int     `json:"w"`
+		H       int     `json:"h"`
+		OffsetY int     `json:"offsetY"`
+		Image   string  `json:"image,omitempty"`
+		Fill    string  `json:"fill,omitempty"`
+		Back    string  `json:"back,omitempty"`
+		Radius  float64 `json:"radius,omitempty"`
+	} `json:"hpBar"`
+	Name   TextStyle   `json:"name"`
+	Label  TextStyle   `json:"label"`
+	Status StatusStyle `json:"status"`
+	Float  FloatStyle  `json:"float"`
+	// LabelRaise lifts the label further when a name plate is drawn
+	LabelRaise float64 `json:"labelRaise"`
+}
+
+// StatusStyle is a row of status effect icons placed like a TextStyle: on
+// the party card, or relative to a battlefield sprite's top centre
+type StatusStyle struct {
+	X       float64 `json:"x"`
+	Y       float64 `json:"y"`
+	AnchorX float64 `json:"anchorX"`
+	Size    float64 `json:"size"`
+	Gap     float64 `json:"gap"`
+	Max     int     `json:"max"` // Icons before the rest collapse into "+N", 0 hides the row
+}
+
+// FloatStyle is the damage and heal numbers drawn over a unit that was hit
+type FloatStyle struct {
+	Size   float64 `json:"size"`
+	Damage string  `json:"damage"`
+	Heal   string  `json:"heal"`
+	Crit   string  `json:"crit"`
+}
+
+// TimelineStyle is the turn-order strip. Icons are laid out in a row inside
+// the box, aligned by AnchorX; the fill/stroke backing hugs the icons shown.
+type TimelineStyle struct {
+	Panel
+	AnchorX float64 `json:"anchorX"`
+	Icon    int     `json:"icon"` // Current actor's icon size; later turns are drawn smaller
+	Gap     int     `json:"gap"`
+	Count   int     `json:"count"`  // Turns shown, 0 hides the strip
+	Player  string  `json:"player"` // Icon border per side
+	Enemy   string  `json:"enemy"`
+	Current string  `json:"current"` // Current actor's border and marker
+}
+
+// LogStyle is the combat log panel. Text starts at Size and shrinks towards
+// MinSize until the wrapped lines fit; past that the oldest lines are cut.
+type LogStyle struct {
+	Panel
+	Duel    Box     `json:"duel"`  // Panel position in duels, unset = same box
+	Lines   int     `json:"lines"` // Most recent entries drawn, 0 hides the log
+	Padding int     `json:"padding"`
+	Size    float64 `json:"size"`
+	MinSize float64 `json:"minSize"`
+	Color   string  `json:"color"` // Plain text, and the per-kind segment colors
+	Damage  string  `json:"damage"`
+	Heal    string  `json:"heal"`
+	Crit    string  `json:"crit"`
+	Miss    string  `json:"miss"`
+}
+
+// cachedTheme is a resolved layout and the modification times of the files
+// it was built from: the theme and every theme it extends
+type cachedTheme struct {
+	theme *Theme
+	files map[string]time.Time
+}
+
+// fresh reports whether none of the theme's files changed on disk
+func (c cachedTheme) fresh() bool {
+	for path, modTime := range c.files {
+		info, err := os.Stat(path)
+		if err != nil || !info.ModTime().Equal(modTime) {
+			return false
+		}
+	}
+	return true
+}
+
+var (
+	themeMu    sync.Mutex
+	themeCache = make(map[string]cachedTheme)
+)
+
+func themesDir(assetsPath string) string {
+	return filepath.Join(assetsPath, "rpgasset", "themes")
+}
+
+// LoadTheme returns the named theme's landscape layout, re-reading its file
+// when it changed on disk
+func LoadTheme(name string, assetsPath string) (*Theme, error) {
+	return ThemeFor(name, "", 0, 0, assetsPath)
+}
+
+// ThemeFor returns the named theme laid out for an aspect and canvas size.
+// Without an aspect, the layout whose ratio is nearest width/height is used.
+// A size that differs from the layout's own rescales it, so small renders
+// are drawn small rather than downscaled.
+func ThemeFor(name, aspect string, width, height int, assetsPath string) (*Theme, error) {
+	name = strings.ToLower(strings.TrimSpace(name))
+	if name == "" {
+		name = DefaultTheme
+	}
+	aspect = strings.ToLower(strings.TrimSpace(aspect))
+	if width < 0 || height < 0 || width > maxCanvasSide || height > maxCanvasSide {
+		return nil, fmt.Errorf("width and height must be between 0 and %d", maxCanvasSide)
+	}
+
+	if aspect == "" && width > 0 && height > 0 {
+		base, err := loadTheme(name, AspectLandscape, assetsPath, nil)
+		if err != nil {
+			return nil, err
+		}
+		want := math.Log(float64(width) / float64(height))
+		best := math.Inf(1)
+		for _, a := range base.aspects {
+			t, err := loadTheme(name, a, assetsPath, nil)
+			if err != nil {
+				return nil, err
+			}
+			if d := math.Abs(math.Log(float64(t.Width)/float64(t.Height)) - want); d < best {
+				best, aspect = d, a
+			}
+		}
+	}
+	if aspect == "" {
+		aspect = AspectLandscape
+	}
+
+	t, err := loadTheme(name, aspect, assetsPath, nil)
+	if err != nil {
+		return nil, err
+	}
+	if !hasTag(t.aspects, aspect) {
+		return nil, fmt.Errorf("theme %s has no %s layout (has %s)", name, aspect, strings.Join(t.aspects, ", "))
+	}
+
+	// Fill a missing side from the layout's ratio
+	if width > 0 && height == 0 {
+		height = int(math.Round(float64(width) * float64(t.Height) / float64(t.Width)))
+	} else if height > 0 && width == 0 {
+		width = int(math.Round(float64(height) * float64(t.Width) / float64(t.Height)))
+	}
+	if width > 0 && (width != t.Width || height != t.Height) {
+		t = t.resized(width, height)
+	}
+	return t, nil
+}
+
+func loadTheme(name, aspect string, assetsPath string, seen []string) (*Theme, error) {
+	c, err := resolveTheme(name, aspect, assetsPath, seen)
+	return c.theme, err
+}
+
+// resolveTheme builds a theme's layout for an aspect, cached until the theme
+// or any theme it extends changes on disk
+func resolveTheme(name, aspect string, assetsPath string, seen []string) (cachedTheme, error) {
+	for _, s := range seen {
+		if s == name {
+			return cachedTheme{}, fmt.Errorf("theme %s: extends loop %s", name, strings.Join(append(seen, name), " -> "))
+		}
+	}
+	if strings.ContainsAny(name, `/\.`) {
+		return cachedTheme{}, fmt.Errorf("unknown theme %q", name)
+	}
+
+	path := filepath.Join(themesDir(assetsPath), name+".json")
+	key := path + "#" + aspect
+	themeMu.Lock()
+	cached, ok := themeCache[key]
+	themeMu.Unlock()
+	if ok && cached.fresh() {
+		return cached, nil
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return cachedTheme{}, fmt.Errorf("unknown theme %q", name)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return cachedTheme{}, err
+	}
+
+	var head struct {
+		Extends string                     `json:"extends"`
+		Aspects map[string]json.RawMessage `json:"aspects"`
+	}
+	if err := json.Unmarshal(data, &head); err != nil {
+		return cachedTheme{}, fmt.Errorf("theme %s: %w", name, err)
+	}
+
+	// Layers, each overriding the last: the base theme for this aspect, this
+	// file's landscape layout, then this file's override for the aspect.
+	// Fields present in a layer override; slices are replaced whole.
+	t := &Theme{}
+	files := map[string]time.Time{path: info.ModTime()}
+	if head.Extends != "" {
+		base, err := resolveTheme(strings.ToLower(head.Extends), aspect, assetsPath, append(seen, name))
+		if err != nil {
+			return cachedTheme{}, fmt.Errorf("theme %s: %w", name, err)
+		}
+		*t = base.theme.clone()
+		for f, m := range base.files {
+			files[f] = m
+		}
+	}
+	if err := json.Unmarshal(data, t); err != nil {
+		return cachedTheme{}, fmt.Errorf("theme %s: %w", name, err)
+	}
+	if raw, ok := head.Aspects[aspect]; ok && aspect != AspectLandscape {
+		if err := json.Unmarshal(raw, t); err != nil {
+			return cachedTheme{}, fmt.Errorf("theme %s (%s): %w", name, aspect, err)
+		}
+	}
+
+	t.Name, t.Aspect = name, aspect
+	if !hasTag(t.aspects, AspectLandscape) {
+		t.aspects = append(t.aspects, AspectLandscape)
+	}
+	for _, a := range sortedAspects(head.Aspects) {
+		if !hasTag(t.aspects, a) {
+			t.aspects = append(t.aspects, a)
+		}
+	}
+	if err := t.validate(assetsPath); err != nil {
+		return cachedTheme{}, fmt.Errorf("theme %s (%s): %w", name, aspect, err)
+	}
+
+	c := cachedTheme{t, files}
+	themeMu.Lock()
+	themeCache[key] = c
+	themeMu.Unlock()
+	return c, nil
+}
+
+func sortedAspects(m map[string]json.RawMessage) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, strings.ToLower(k))
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// clone deep-copies the slices so an extending theme can't modify its base
+func (t *Theme) clone() Theme {
+	c := *t
+	c.Panels = append([]Panel(nil), t.Panels...)
+	c.Party.Icons = append([]Panel(nil), t.Party.Icons...)
+	c.Party.HP.Segments = append([]Box(nil), t.Party.HP.Segments...)
+	c.Party.Energy.Segments = append([]Box(nil), t.Party.Energy.Segments...)
+	c.aspects = append([]string(nil), t.aspects...)
+	return c
+}
+
+// resized rescales the layout to a w x h canvas. Positions follow each axis;
+// sizes use the smaller factor so nothing is stretched.
+func (t *Theme) resized(w, h int) *Theme {
+	c := t.clone()
+	sx, sy := float64(w)/float64(t.Width), float64(h)/float64(t.Height)
+	s := math.Min(sx, sy)
+	px := func(v int) int { return int(math.Round(float64(v) * sx)) }
+	py := func(v int) int { return int(math.Round(float64(v) * sy)) }
+	sz := func(v int) int { return int(math.Round(float64(v) * s)) }
+	panel := func(p *Panel) {
+		p.X, p.Y, p.W, p.H = px(p.X), py(p.Y), sz(p.W), sz(p.H)
+		p.Radius *= s
+	}
+	text := func(st *TextStyle) {
+		st.X, st.Y, st.Size = st.X*s, st.Y*s, st.Size*s
+	}
+	zone := func(b *Box) {
+		b.X, b.Y, b.W, b.H = px(b.X), py(b.Y), px(b.W), py(b.H)
+	}
+
+	c.Width, c.Height = w, h
+	for i := range c.Panels {
+		panel(&c.Panels[i])
+	}
+	panel(&c.Banner.Panel)
+	text(&c.Banner.Text)
+
+	// Card contents stay in card units; the card itself is drawn smaller
+	zone(&c.Party.Zone)
+	c.Party.Solo.X, c.Party.Solo.Y = px(c.Party.Solo.X), py(c.Party.Solo.Y)
+	c.cardScale = t.soloScale() * s
+
+	e := &c.Enemies
+	e.X, e.Y = e.X*sx, e.Y*sy
+	e.SpacingX, e.SpacingY, e.GroupShift, e.SpriteWidth = e.SpacingX*s, e.SpacingY*s, e.GroupShift*s, e.SpriteWidth*s
+	zone(&e.Zone)
+
+	l := &c.Leader
+	l.X, l.Y, l.Width, l.Shadow = l.X*sx, l.Y*sy, l.Width*s, l.Shadow*s
+
+	d := &c.Duel
+	zone(&d.ZoneA)
+	zone(&d.ZoneB)
+	d.Front, d.Back, d.Ground = d.Front*sx, d.Back*sx, d.Ground*sy
+	d.SpriteWidth, d.CrowdWidth = d.SpriteWidth*s, d.CrowdWidth*s
+
+	u := &c.Units
+	u.HPBar.W, u.HPBar.H, u.HPBar.OffsetY = sz(u.HPBar.W), sz(u.HPBar.H), sz(u.HPBar.OffsetY)
+	u.HPBar.Radius *= s
+	text(&u.Name)
+	text(&u.Label)
+	u.LabelRaise *= s
+	u.Status.X, u.Status.Y, u.Status.Size, u.Status.Gap = u.Status.X*s, u.Status.Y*s, u.Status.Size*s, u.Status.Gap*s
+	u.Float.Size *= s
+
+	tl := &c.Timeline
+	panel(&tl.Panel)
+	tl.W = px(t.Timeline.W)
+	tl.Icon, tl.Gap = sz(tl.Icon), sz(tl.Gap)
+
+	lg := &c.Log
+	panel(&lg.Panel)
+	lg.W, lg.H = px(t.Log.W), py(t.Log.H)
+	zone(&lg.Duel)
+	lg.Padding = sz(lg.Padding)
+	lg.Size, lg.MinSize = lg.Size*s, lg.MinSize*s
+	return &c
+}
+
+// soloScale is the party card scale for a lone player, and the largest any
+// party card is drawn at
+func (t *Theme) soloScale() float64 {
+	if t.cardScale == 0 {
+		return 1
+	}
+	return t.cardScale
+}
+
+// Aspects lists the layouts the theme defines
+func (t *Theme) Aspects() []string {
+	return append([]string(nil), t.aspects...)
+}
+
+// validate checks sizes, colors and that every referenced UI file exists
+func (t *Theme) validate(assetsPath string) error {
+	var problems []string
+	if t.Width <= 0 || t.Height <= 0 {
+		problems = append(problems, fmt.Sprintf("invalid canvas size %dx%d", t.Width, t.Height))
+	}
+	if t.Party.Card.W <= 0 || t.Party.Card.H <= 0 {
+		problems = append(problems, "party card needs a w and h")
+	}
+	if t.Party.Portrait.Width <= 0 || t.Party.Portrait.Height <= 0 {
+		problems = append(problems, "party portrait needs a width and height")
+	}
+
+	ui := filepath.Join(assetsPath, "rpgasset", "ui")
+	file := func(what, f string) {
+		if f != "" && !fileExists(filepath.Join(ui, f)) {
+			problems = append(problems, fmt.Sprintf("%s: %s not found", what, f))
+		}
+	}
+	colour := func(what, c string) {
+		if c == "" {
+			return
+		}
+		if !strings.HasPrefix(c, "#") || (len(c) != 7 && len(c) != 9) {
+			problems = append(problems, fmt.Sprintf("%s: invalid color %q", what, c))
+		}
+	}
+	bar := func(what string, b BarStyle) {
+		for i := 1; b.Image != "" && i <= 5; i++ {
+			file(what, fmt.Sprintf("%s%d.png", b.Image, i))
+		}
+		colour(what, b.Fill)
+		colour(what, b.Back)
+	}
+	panel := func(what string, p Panel) {
+		file(what, p.Image)
+		colour(what, p.Fill)
+		colour(what, p.Stroke)
+		switch p.Show {
+		case "", "battle", "duel":
+		default:
+			problems = append(problems, fmt.Sprintf("%s: invalid show %q", what, p.Show))
+		}
+	}
+
+	file("font", t.Font)
+	colour("backdrop", t.Backdrop)
+	colour("dim", t.Dim)
+	for i, p := range t.Panels {
+		panel(fmt.Sprintf("panels[%d]", i), p)
+	}
+	panel("banner", t.Banner.Panel)
+	panel("party.card", t.Party.Card)
+	for i, p := range t.Party.Icons {
+		panel(fmt.Sprintf("party.icons[%d]", i), p)
+	}
+	bar("party.hp", t.Party.HP)
+	bar("party.energy", t.Party.Energy)
+	file("units.hpBar", t.Units.HPBar.Image)
+	panel("timeline", t.Timeline.Panel)
+	if t.Timeline.Count > 0 && t.Timeline.Icon <= 0 {
+		problems = append(problems, "timeline needs an icon size")
+	}
+	panel("log", t.Log.Panel)
+	if t.Log.Lines > 0 {
+		if t.Log.Size <= 0 || t.Log.W <= 0 || t.Log.H <= 0 {
+			problems = append(problems, "log needs a box and a text size")
+		}
+		problems = append(problems, t.logOverlaps()...)
+	}
+	for what, c := range map[string]string{
+		"banner.text": t.Banner.Text.Color, "party.name": t.Party.Name.Color,
+		"party.level": t.Party.Level.Color, "party.ko.dim": t.Party.KO.Dim,
+		"party.ko.text": t.Party.KO.Text.Color, "units.name": t.Units.Name.Color,
+		"units.label": t.Units.Label.Color, "units.hpBar.fill": t.Units.HPBar.Fill,
+		"units.hpBar.back": t.Units.HPBar.Back, "timeline.player": t.Timeline.Player,
+		"timeline.enemy": t.Timeline.Enemy, "timeline.current": t.Timeline.Current,
+		"log.color": t.Log.Color, "log.damage": t.Log.Damage, "log.heal": t.Log.Heal,
+		"log.crit": t.Log.Crit, "log.miss": t.Log.Miss, "units.float.damage": t.Units.Float.Damage,
+		"units.float.heal": t.Units.Float.Heal, "units.float.crit": t.Units.Float.Crit,
+	} {
+		colour(what, c)
+	}
+
+	if len(problems) > 0 {
+		sort.Strings(problems)
+		return fmt.Errorf("%s", strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+// logOverlaps reports HUD the log panel would cover, in battles and in duels
+func (t *Theme) logOverlaps() []string {
+	var problems []string
+	check := func(mode string, log Box, hud map[string]Box) {
+		for what, b := range hud {
+			if b.W > 0 && b.H > 0 && log.rect().Overlaps(b.rect()) {
+				problems = append(problems, fmt.Sprintf("log overlaps %s (%s)", what, mode))
+			}
+		}
+	}
+	tl := t.Timeline.Box
+	battle := map[string]Box{"party.zone": t.Party.Zone, "timeline": tl}
+	duel := map[string]Box{"duel.zoneA": t.Duel.ZoneA, "duel.zoneB": t.Duel.ZoneB, "timeline": tl}
+	for i, p := range t.Panels {
+		if p.Show != "duel" {
+			battle[fmt.Sprintf("panels[%d]", i)] = p.Box
+		}
+		if p.Show != "battle" {
+			duel[fmt.Sprintf("panels[%d]", i)] = p.Box
+		}
+	}
+	if t.Log.Show != "duel" {
+		check("battle", t.Log.Box, battle)
+	}
+	if t.Log.Show != "battle" {
+		check("duel", t.Log.duelBox(), duel)
+	}
+	return problems
+}
+
+// duelBox is where the log goes in duels
+func (l LogStyle) duelBox() Box {
+	if l.Duel.W > 0 && l.Duel.H > 0 {
+		return l.Duel
+	}
+	return l.Box
+}
+
+// ThemeNames lists the themes available under assetsPath
+func ThemeNames(assetsPath string) []string {
+	entries, _ := os.ReadDir(themesDir(assetsPath))
+	var names []string
+	for _, entry := range entries {
+		if !entry.IsDir() && filepath.Ext(entry.Name()) == ".json" {
+			names = append(names, strings.TrimSuffix(entry.Name(), ".json"))
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// themeColor parses a theme color, falling back when it's unset
+func themeColor(s string, fallback color.Color) color.Color {
+	if s == "" {
+		return fallback
+	}
+	return utils.ParseHexColor(s)
+}
@@ -0,0 +1,190 @@
+package combat
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+var testThemes = map[string]string{
+	"base": `{
+		"width": 1000, "height": 500, "backdrop": "#000000",
+		"panels": [{"x": 100, "y": 300, "w": 200, "h": 100, "fill": "#202020"}],
+		"party": {"card": {"w": 100, "h": 50}, "portrait": {"width": 40, "height": 40}},
+		"enemies": {"x": 600, "y": 100, "spriteWidth": 100, "zone": {"x": 500, "y": 50, "w": 400, "h": 300}},
+		"units": {"hpBar": {"w": 60, "h": 8, "offsetY": -12}},
+		"aspects": {"portrait": {"width": 500, "height": 1000}}
+	}`,
+	"child":  `{"extends": "base", "backdrop": "#112233", "enemies": {"spriteWidth": 150}}`,
+	"loop-a": `{"extends": "loop-b"}`,
+	"loop-b": `{"extends": "loop-a"}`,
+	"broken": `{"extends": "base", "panels": [{"x": 0, "y": 0, "w": 10, "h": 10, "show": "sideways"}]}`,
+}
+
+// themeAssets writes the test themes to a temporary assets folder
+func themeAssets(t *testing.T) string {
+	t.Helper()
+	assets := t.TempDir()
+	dir := themesDir(assets)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	for name, data := range testThemes {
+		if err := os.WriteFile(filepath.Join(dir, name+".json"), []byte(data), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return assets
+}
+
+func TestThemeExtends(t *testing.T) {
+	assets := themeAssets(t)
+	base, err := LoadTheme("base", assets)
+	if err != nil {
+		t.Fatal(err)
+	}
+	child, err := LoadTheme("CHILD", assets)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if child.Backdrop != "#112233" || base.Backdrop != "#000000" {
+		t.Errorf("backdrop %s over %s; want #112233 over #000000", child.Backdrop, base.Backdrop)
+	}
+	if child.Enemies.SpriteWidth != 150 || child.Enemies.Zone != base.Enemies.Zone {
+		t.Errorf("enemies %+v; want sprite width 150 and the base's zone %+v", child.Enemies, base.Enemies.Zone)
+	}
+	if len(child.Panels) != 1 || child.Panels[0] != base.Panels[0] {
+		t.Errorf("panels %+v; want the base's %+v", child.Panels, base.Panels)
+	}
+	if !hasTag(child.Aspects(), AspectPortrait) {
+		t.Errorf("aspects %v; want the base's portrait layout", child.Aspects())
+	}
+
+	portrait, err := ThemeFor("child", AspectPortrait, 0, 0, assets)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if portrait.Width != 500 || portrait.Height != 1000 || portrait.Backdrop != "#112233" {
+		t.Errorf("portrait layout %dx%d %s; want 500x1000 #112233", portrait.Width, portrait.Height, portrait.Backdrop)
+	}
+}
+
+func TestThemeErrors(t *testing.T) {
+	assets := themeAssets(t)
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"loop-a", "extends loop loop-a -> loop-b -> loop-a"},
+		{"missing", `unknown theme "missing"`},
+		{"../themes/base", "unknown theme"},
+		{`themes\base`, "unknown theme"},
+		{"base.json", "unknown theme"},
+		{"broken", `invalid show "sideways"`},
+	}
+	for _, tt := range tests {
+		_, err := LoadTheme(tt.name, assets)
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("LoadTheme(%q) = %v; want %q", tt.name, err, tt.want)
+		}
+	}
+}
+
+func TestThemeFor(t *testing.T) {
+	assets := themeAssets(t)
+	tests := []struct {
+		width, height int
+		aspect        string
+		w, h          int
+	}{
+		{0, 0, AspectLandscape, 1000, 500},
+		{500, 0, AspectLandscape, 500, 250},
+		{0, 250, AspectLandscape, 500, 250},
+		{400, 900, AspectPortrait, 400, 900},
+		{1200, 500, AspectLandscape, 1200, 500},
+	}
+	for _, tt := range tests {
+		th, err := ThemeFor("base", "", tt.width, tt.height, assets)
+		if err != nil {
+			t.Errorf("%dx%d: %v", tt.width, tt.height, err)
+			continue
+		}
+		if th.Aspect != tt.aspect || th.Width != tt.w || th.Height != tt.h {
+			t.Errorf("%dx%d: %s %dx%d; want %s %dx%d", tt.width, tt.height, th.Aspect, th.Width, th.Height, tt.aspect, tt.w, tt.h)
+		}
+	}
+}
+
+func TestThemeResized(t *testing.T) {
+	base, err := LoadTheme("base", themeAssets(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		w, h   int
+		panel  Box
+		zone   Box
+		sprite float64
+		hpBar  int
+	}{
+		{1000, 500, Box{100, 300, 200, 100}, Box{500, 50, 400, 300}, 100, 60},
+		{500, 250, Box{50, 150, 100, 50}, Box{250, 25, 200, 150}, 50, 30},
+		// Stretched wider: positions follow each axis, sizes the smaller one
+		{2000, 500, Box{200, 300, 200, 100}, Box{1000, 50, 800, 300}, 100, 60},
+		{1000, 1000, Box{100, 600, 200, 100}, Box{500, 100, 400, 600}, 100, 60},
+	}
+	for _, tt := range tests {
+		r := base.resized(tt.w, tt.h)
+		if r.Width != tt.w || r.Height != tt.h {
+			t.Errorf("%dx%d: canvas %dx%d", tt.w, tt.h, r.Width, r.Height)
+		}
+		if r.Panels[0].Box != tt.panel {
+			t.Errorf("%dx%d: panel %+v; want %+v", tt.w, tt.h, r.Panels[0].Box, tt.panel)
+		}
+		if r.Enemies.Zone != tt.zone {
+			t.Errorf("%dx%d: enemy zone %+v; want %+v", tt.w, tt.h, r.Enemies.Zone, tt.zone)
+		}
+		if r.Enemies.SpriteWidth != tt.sprite || r.Units.HPBar.W != tt.hpBar {
+			t.Errorf("%dx%d: sprite width %v, HP bar %d; want %v, %d", tt.w, tt.h, r.Enemies.SpriteWidth, r.Units.HPBar.W, tt.sprite, tt.hpBar)
+		}
+	}
+	if base.Panels[0].Box != (Box{100, 300, 200, 100}) || base.Width != 1000 {
+		t.Errorf("resizing changed the original: %+v", base.Panels[0].Box)
+	}
+}
+
+func TestThemeCache(t *testing.T) {
+	assets := themeAssets(t)
+	first, err := LoadTheme("child", assets)
+	if err != nil {
+		t.Fatal(err)
+	}
+	again, err := LoadTheme("child", assets)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again != first {
+		t.Error("an unchanged extended theme was resolved again")
+	}
+
+	// Editing the base re-resolves the themes that extend it
+	base := filepath.Join(themesDir(assets), "base.json")
+	data := strings.Replace(testThemes["base"], `"w": 100, "h": 50`, `"w": 120, "h": 50`, 1)
+	if err := os.WriteFile(base, []byte(data), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	later := time.Now().Add(time.Minute)
+	if err := os.Chtimes(base, later, later); err != nil {
+		t.Fatal(err)
+	}
+	edited, err := LoadTheme("child", assets)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if edited == first || edited.Party.Card.W != 120 {
+		t.Errorf("card width %d after editing the base; want 120", edited.Party.Card.W)
+	}
+}
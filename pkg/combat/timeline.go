@@ -0,0 +1,195 @@
+package combat
+
+import (
+	"image"
+	"image/color"
+	"math"
+
+	"image-service/pkg/utils"
+
+	"github.com/disintegration/imaging"
+	"github.com/fogleman/gg"
+)
+
+// Later turns are drawn at this fraction of the current actor's icon
+const timelineFollowScale = 0.8
+
+// Player turn icons are cropped to this square before they're tiled
+const timelineCrop = 128
+
+// timelineTurn is one icon in the turn-order strip
+type timelineTurn struct {
+	unit  *sceneUnit
+	round int // 0 for the turns the client sent, then one per repeat
+	icon  image.Image
+	grey  image.Image // Shown once the unit is down
+}
+
+// buildTimeline resolves req.TurnOrder into the turns shown, current actor
+// first. When the sent order is shorter than the strip it repeats with the
+// units still standing, since initiative carries over to the next round.
+func (sc *combatScene) buildTimeline(partyLevel int) {
+	style := sc.theme.Timeline
+	if style.Count <= 0 || style.Icon <= 0 || len(sc.req.TurnOrder) == 0 {
+		return
+	}
+
+	var order []*sceneUnit
+	for _, ref := range sc.req.TurnOrder {
+		if u := sc.unit(ref); u != nil {
+			order = append(order, u)
+		}
+	}
+	var units []*sceneUnit
+	var rounds []int
+	for i := 0; i < len(order) && len(units) < style.Count; i++ {
+		units, rounds = append(units, order[i]), append(rounds, 0)
+	}
+	for round := 1; len(units) < style.Count; round++ {
+		added := false
+		for _, u := range order {
+			if u.hp > 0 && len(units) < style.Count {
+				units, rounds = append(units, u), append(rounds, round)
+				added = true
+			}
+		}
+		if !added {
+			break
+		}
+	}
+
+	crops := make(map[*sceneUnit]image.Image)
+	for i, u := range units {
+		crop, ok := crops[u]
+		if !ok {
+			crop = sc.turnPortrait(u, partyLevel)
+			crops[u] = crop
+		}
+		size := style.Icon
+		if i > 0 {
+			size = int(math.Round(float64(size) * timelineFollowScale))
+		}
+		turn := timelineTurn{unit: u, round: rounds[i]}
+		turn.icon = timelineTile(crop, size, style.Radius)
+		turn.grey = imaging.AdjustBrightness(imaging.Grayscale(turn.icon), -20)
+		sc.timeline = append(sc.timeline, turn)
+	}
+}
+
+// turnPortrait is a player's head-and-shoulders crop, cosmetics included like
+// the HUD's, or an enemy's sprite trimmed to its opaque area; nil when the
+// sprite can't be loaded
+func (sc *combatScene) turnPortrait(u *sceneUnit, partyLevel int) image.Image {
+	if u.ref.side == sidePlayer {
+		p := sc.req.Players[u.ref.index]
+		sprite, err := loadCharacter(p.Class, p.SpriteIndex, p.Cosmetics, sc.assetsPath)
+		if err != nil {
+			return nil
+		}
+		return characterPortrait(sprite, p.Class, p.SpriteIndex, timelineCrop, timelineCrop, sc.assetsPath)
+	}
+
+	path := SelectEnemySprite(sc.req.Enemies[u.ref.index], u.ref.index, partyLevel, sc.assetsPath)
+	sprite, err := utils.LoadImage(path)
+	if err != nil {
+		return nil
+	}
+	if b := utils.OpaqueBounds(sprite, 16); !b.Empty() {
+		sprite = imaging.Crop(sprite, b)
+	}
+	return sprite
+}
+
+// timelineTile is the top of the sprite cropped square on a dark rounded tile
+func timelineTile(sprite image.Image, size int, radius float64) image.Image {
+	dc := gg.NewContext(size, size)
+	dc.DrawRoundedRectangle(0, 0, float64(size), float64(size), radius)
+	dc.Clip()
+	dc.SetColor(color.RGBA{20, 20, 30, 255})
+	dc.Clear()
+	if sprite != nil {
+		dc.DrawImage(imaging.Fill(sprite, size, size, imaging.Top, imaging.Lanczos), 0, 0)
+	}
+	return dc.Image()
+}
+
+// drawTimeline draws the turn-order strip. Units that fall during an
+// animated turn grey out on the frame their HP reaches zero.
+func (sc *combatScene) drawTimeline(dc *gg.Context) {
+	if len(sc.timeline) == 0 {
+		return
+	}
+	style := sc.theme.Timeline
+	gap := float64(style.Gap)
+	newRound := func(i int) bool {
+		return i > 0 && sc.timeline[i].round != sc.timeline[i-1].round
+	}
+	total := gap
+	for i, t := range sc.timeline {
+		total += float64(t.icon.Bounds().Dx()) + gap
+		if newRound(i) {
+			total += gap
+		}
+	}
+	h := float64(style.H)
+	x := float64(style.X) + (float64(style.W)-total)*style.AnchorX
+	y := float64(style.Y)
+
+	if style.Fill != "" {
+		dc.DrawRoundedRectangle(x, y, total, h, style.Radius+gap)
+		dc.SetColor(utils.ParseHexColor(style.Fill))
+		dc.Fill()
+	}
+	if style.Stroke != "" {
+		dc.DrawRoundedRectangle(x, y, total, h, style.Radius+gap)
+		dc.SetColor(utils.ParseHexColor(style.Stroke))
+		dc.SetLineWidth(2)
+		dc.Stroke()
+	}
+
+	ix := x + gap
+	for i, t := range sc.timeline {
+		s := float64(t.icon.Bounds().Dx())
+		iy := y + (h-s)/2
+
+		// New round divider
+		if newRound(i) {
+			dc.SetColor(color.NRGBA{255, 255, 255, 140})
+			dc.SetLineWidth(2)
+			dc.DrawLine(ix, y+h*0.2, ix, y+h*0.8)
+			dc.Stroke()
+			ix += gap
+		}
+
+		img := t.icon
+		border := themeColor(style.Player, color.White)
+		if t.unit.ref.side == sideEnemy {
+			border = themeColor(style.Enemy, color.White)
+		}
+		if t.unit.hp <= 0 {
+			img = t.grey
+			border = color.RGBA{110, 110, 110, 255}
+		}
+		lw := 2.0
+		if i == 0 {
+			border = themeColor(style.Current, color.White)
+			lw = 3
+		}
+		utils.Blit(dc, img, int(ix), int(iy))
+		dc.DrawRoundedRectangle(float64(int(ix))+lw/2, float64(int(iy))+lw/2, s-lw, s-lw, style.Radius)
+		dc.SetColor(border)
+		dc.SetLineWidth(lw)
+		dc.Stroke()
+
+		// Marker under the current actor
+		if i == 0 {
+			mx, my := ix+s/2, y+h+2
+			dc.MoveTo(mx, my)
+			dc.LineTo(mx-7, my+9)
+			dc.LineTo(mx+7, my+9)
+			dc.ClosePath()
+			dc.Fill()
+		}
+		ix += s + gap
+	}
+}
@@ -0,0 +1,62 @@
+package combat
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestBuildTimeline(t *testing.T) {
+	useManifest(t, &Manifest{})
+	tests := []struct {
+		name  string
+		order []string
+		count int
+		dead  []string
+		want  string // name/round per turn
+	}{
+		{"sent order", []string{"ann", "bat", "bo"}, 3, nil, "Ann/0 Bat/0 Bo/0"},
+		{"cut to the strip", []string{"ann", "bat", "bo", "ogre"}, 2, nil, "Ann/0 Bat/0"},
+		{"repeats", []string{"ann", "bat"}, 5, nil, "Ann/0 Bat/0 Ann/1 Bat/1 Ann/2"},
+		{"the fallen don't repeat", []string{"ann", "bat", "bo"}, 6, []string{"bat"}, "Ann/0 Bat/0 Bo/0 Ann/1 Bo/1 Ann/2"},
+		{"everyone down", []string{"bat", "ogre"}, 5, []string{"bat", "ogre"}, "Bat/0 Ogre/0"},
+		{"unknown refs", []string{"dragon", "enemy:1", "player:9"}, 2, nil, "Ogre/0 Ogre/1"},
+		{"no order", nil, 4, nil, ""},
+		{"hidden strip", []string{"ann"}, 0, nil, ""},
+	}
+	for _, tt := range tests {
+		req := &CombatRequest{
+			Players:   []Player{{Name: "Ann"}, {Name: "Bo"}},
+			Enemies:   []Enemy{{Name: "Bat"}, {Name: "Ogre"}},
+			TurnOrder: tt.order,
+		}
+		th := &Theme{}
+		th.Timeline.Count, th.Timeline.Icon = tt.count, 40
+		sc := &combatScene{req: req, theme: th, assetsPath: t.TempDir()}
+		for i, p := range req.Players {
+			sc.players = append(sc.players, &sceneUnit{ref: unitRef{sidePlayer, i}, name: p.Name, hp: 10})
+		}
+		for i, e := range req.Enemies {
+			sc.enemies = append(sc.enemies, &sceneUnit{ref: unitRef{sideEnemy, i}, name: e.Name, hp: 10})
+		}
+		for _, ref := range tt.dead {
+			sc.unit(ref).hp = 0
+		}
+
+		sc.buildTimeline(1)
+		var got []string
+		for i, turn := range sc.timeline {
+			got = append(got, fmt.Sprintf("%s/%d", turn.unit.name, turn.round))
+			size := 40
+			if i > 0 {
+				size = 32
+			}
+			if b := turn.icon.Bounds(); b.Dx() != size || b.Dy() != size {
+				t.Errorf("%s: turn %d icon %v; want %d px", tt.name, i, b, size)
+			}
+		}
+		if s := strings.Join(got, " "); s != tt.want {
+			t.Errorf("%s: %q; want %q", tt.name, s, tt.want)
+		}
+	}
+}
@@ -5,13 +5,40 @@ type Player struct {
 	Name           string `json:"name"`
 	Class          string `json:"class"` // e.g. "FIGHTER"
 	Level          int    `json:"level"`
-	HP             int    `json:"hp"`        // Current HP
+	HP             int    `json:"hp"` // Current HP
 	MaxHP          int    `json:"maxHp"`
 	CurrentHP      int    `json:"currentHP"` // Redundant but often sent
 	Energy         int    `json:"energy"`
 	MaxEnergy      int    `json:"maxEnergy"`
 	AdventurerRank string `json:"adventurerRank"`
 	SpriteIndex    int    `json:"spriteIndex"`
+	Team           string `json:"team"` // PvP side: "A" (default) or "B"
+
+	StatusEffects []StatusEffect `json:"statusEffects"`
+	LastDamage    int            `json:"lastDamage"` // Optional floating numbers over the sprite
+	LastHeal      int            `json:"lastHeal"`
+	Crit          bool           `json:"crit"` // lastDamage was a critical hit
+
+	Cosmetics Cosmetics `json:"cosmetics"`
+}
+
+// Cosmetics are layers drawn over a player's sprite. Weapon, Pet and Frame
+// name manifest cosmetics; Frame also takes a color for the built-in title
+// halo, and Aura is a glow color. Colors are hex, gold, silver, bronze or an
+// element name.
+type Cosmetics struct {
+	Weapon string `json:"weapon"`
+	Aura   string `json:"aura"`
+	Frame  string `json:"frame"`
+	Pet    string `json:"pet"`
+}
+
+// StatusEffect is a buff or debuff on a unit, e.g. poison, stun, shield,
+// burn or attack_up
+type StatusEffect struct {
+	Type   string `json:"type"`
+	Stacks int    `json:"stacks"`
+	Turns  int    `json:"turns"` // Remaining turns, 0 when unknown or permanent
 }
 
 // Enemy represents an enemy in the combat scene
@@ -22,13 +49,49 @@ type Enemy struct {
 	IsBoss      bool   `json:"isBoss"`
 	JustDied    bool   `json:"justDied"`
 	SpriteIndex int    `json:"spriteIndex"`
+
+	// Optional explicit sprite selection; the party-level buckets are only used when absent
+	Level     int    `json:"level"`
+	Element   string `json:"element"`   // FIRE, WATER, EARTH, ICE
+	Tier      string `json:"tier"`      // LOW, MID, HIGH, ELITE (bosses: MID, HIGH, CALAMITY)
+	SpriteKey string `json:"spriteKey"` // Sprite group (e.g. "HYBRID") or an exact file from X-Enemy-Sprites
+	Variant   string `json:"variant"`   // Recolor: shiny, elite, corrupted or an element
+
+	StatusEffects []StatusEffect `json:"statusEffects"`
+	LastDamage    int            `json:"lastDamage"`
+	LastHeal      int            `json:"lastHeal"`
+	Crit          bool           `json:"crit"`
+}
+
+// CombatAction is one resolved step of a combat turn, used to animate the scene.
+// Attacker and Target are unit references: a unit name or "player:N" / "enemy:N".
+type CombatAction struct {
+	Attacker string `json:"attacker"`
+	Target   string `json:"target"`
+	Skill    string `json:"skill"`
+	Damage   int    `json:"damage"`
+	Healed   int    `json:"healed"`
+	Missed   bool   `json:"missed"`
+	Killed   bool   `json:"killed"`
 }
 
 // CombatRequest is the payload sent from Node.js
 type CombatRequest struct {
-	Players    []Player `json:"players"`
-	Enemies    []Enemy  `json:"enemies"`
-	CombatType string   `json:"combatType"` // "PVE" or "PVP"
-	Rank       string   `json:"rank"`
-	Background string   `json:"background"` // Filename only
+	Players    []Player       `json:"players"`
+	Enemies    []Enemy        `json:"enemies"`
+	CombatType string         `json:"combatType"` // "PVE" or "PVP"
+	Rank       string         `json:"rank"`
+	Background string         `json:"background"` // Filename only
+	Biome      string         `json:"biome"`      // FOREST, CAVE, VOLCANO, ICE, DESERT or VOID when no background is given
+	Seed       Seed           `json:"seed"`       // Optional: same seed, same background (e.g. a dungeon run id)
+	TimeOfDay  string         `json:"timeOfDay"`  // Optional grade: dawn, day, dusk or night
+	Weather    string         `json:"weather"`    // Optional overlay: rain, snow, fog, embers or sandstorm
+	Theme      string         `json:"theme"`      // HUD theme in rpgasset/themes, default "classic"
+	Aspect     string         `json:"aspect"`     // "landscape" (default), "portrait" or "square"
+	Width      int            `json:"width"`      // Optional output size; picks the nearest aspect when aspect is empty
+	Height     int            `json:"height"`
+	Actions    []CombatAction `json:"actions"`   // Optional: returns an animated GIF of the turn
+	TurnOrder  []string       `json:"turnOrder"` // Optional: upcoming turns as unit references, current actor first
+	Log        []LogLine      `json:"log"`       // Optional: recent combat log lines, oldest first
+	Effects    []SkillEffect  `json:"effects"`   // Optional: skill VFX drawn over the scene
 }
@@ -0,0 +1,209 @@
+package combat
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"math"
+	"strings"
+	"sync"
+
+	"image-service/pkg/utils"
+
+	"github.com/disintegration/imaging"
+)
+
+// variantStyle is how an enemy variant recolors its sprite. An element or
+// corrupted variant moves the sprite's dominant hue onto its own and pulls
+// the other hues in around it; the others shift every hue by a fixed turn.
+type variantStyle struct {
+	hue     float64 // Target hue in degrees, or -1 to shift by turn instead
+	turn    float64 // Degrees added to every hue when hue is -1
+	spread  float64 // How far other hues stay from the target, 0-1
+	sat     float64 // Saturation multiplier
+	tint    float64 // Minimum saturation, so grey art still takes the color
+	light   float64 // Lightness multiplier
+	glow    color.NRGBA
+	outline bool // A crisp rim in the glow color on top of the soft glow
+}
+
+var enemyVariants = map[string]variantStyle{
+	"shiny":     {-1, 150, 1, 1.25, 0, 1.08, color.NRGBA{255, 225, 110, 200}, false},
+	"elite":     {-1, 0, 1, 1.3, 0, 0.95, color.NRGBA{255, 70, 40, 230}, true},
+	"corrupted": {285, 0, 0.3, 0.65, 0.2, 0.72, color.NRGBA{170, 40, 255, 230}, true},
+}
+
+var variantSprites sync.Map // manifest, sprite path and variant -> image.Image
+
+// enemyVariant looks up a variant by name: shiny, elite, corrupted or an
+// element (as fx names them)
+func enemyVariant(name string) (variantStyle, bool) {
+	name = strings.ToLower(strings.TrimSpace(name))
+	if v, ok := enemyVariants[name]; ok {
+		return v, true
+	}
+	if alias, ok := fxElementAliases[name]; ok {
+		name = alias
+	}
+	p, ok := fxElements[name]
+	if !ok || name == "" {
+		return variantStyle{}, false
+	}
+	h, _, _ := rgbToHSL(p.glow)
+	return variantStyle{hue: h, spread: 0.3, sat: 1.1, tint: 0.25, light: 1, glow: withAlpha(p.glow, 170)}, true
+}
+
+// loadEnemy loads an enemy's sprite recolored for its variant. The result is
+// the size of the base sprite so it's placed exactly like it, and it only
+// depends on the art and the variant, so an enemy always looks the same.
+// Unknown variants draw the plain sprite.
+func loadEnemy(enemy Enemy, path string) (image.Image, error) {
+	base, err := utils.LoadImage(path)
+	if err != nil {
+		return nil, err
+	}
+	v, ok := enemyVariant(enemy.Variant)
+	if !ok {
+		return base, nil
+	}
+
+	key := fmt.Sprintf("%p|%s|%s", Sprites(), path, strings.ToLower(strings.TrimSpace(enemy.Variant)))
+	if img, ok := variantSprites.Load(key); ok {
+		return img.(image.Image), nil
+	}
+	img := recolorSprite(base, v)
+	variantSprites.Store(key, img)
+	return img, nil
+}
+
+// recolorSprite shifts the sprite's colors and draws it over its glow
+func recolorSprite(base image.Image, v variantStyle) image.Image {
+	src := imaging.Clone(base)
+	center, target := 0.0, v.turn
+	if v.hue >= 0 {
+		center, target = dominantHue(src), v.hue
+	}
+
+	out := image.NewNRGBA(src.Bounds())
+	draw.Draw(out, out.Bounds(), auraGlow(src, v.glow), out.Bounds().Min, draw.Over)
+	if v.outline {
+		draw.Draw(out, out.Bounds(), spriteRim(src, v.glow), out.Bounds().Min, draw.Over)
+	}
+
+	for i := 0; i < len(src.Pix); i += 4 {
+		if src.Pix[i+3] == 0 {
+			continue
+		}
+		h, s, l := rgbToHSL(color.NRGBA{src.Pix[i], src.Pix[i+1], src.Pix[i+2], 255})
+		d := math.Mod(h-center+540, 360) - 180
+		h = math.Mod(target+d*v.spread+360, 360)
+		s = math.Min(1, math.Max(s*v.sat, v.tint))
+		l = math.Min(1, l*v.light)
+		c := hslToRGB(h, s, l)
+		src.Pix[i], src.Pix[i+1], src.Pix[i+2] = c.R, c.G, c.B
+	}
+	draw.Draw(out, out.Bounds(), src, out.Bounds().Min, draw.Over)
+	return out
+}
+
+// dominantHue is the hue most of the sprite's colored pixels share, weighted
+// by saturation so outlines and highlights don't count
+func dominantHue(img *image.NRGBA) float64 {
+	var bins [36]float64
+	for i := 0; i < len(img.Pix); i += 4 {
+		a := float64(img.Pix[i+3]) / 255
+		if a < 0.5 {
+			continue
+		}
+		h, s, l := rgbToHSL(color.NRGBA{img.Pix[i], img.Pix[i+1], img.Pix[i+2], 255})
+		if s < 0.15 || l < 0.1 || l > 0.9 {
+			continue
+		}
+		bins[int(h/10)%36] += s * a
+	}
+	best := 0
+	for i := range bins {
+		if bins[i] > bins[best] {
+			best = i
+		}
+	}
+	return float64(best)*10 + 5
+}
+
+// spriteRim is a thin band around the sprite's silhouette in c, the size of
+// the sprite
+func spriteRim(img *image.NRGBA, c color.NRGBA) *image.NRGBA {
+	b := img.Bounds()
+	w, h := b.Dx(), b.Dy()
+	r := max(1, w/120)
+	opaque := func(x, y int) bool {
+		return x >= 0 && y >= 0 && x < w && y < h && img.Pix[y*img.Stride+x*4+3] >= 128
+	}
+
+	rim := image.NewNRGBA(b)
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			if opaque(x, y) {
+				continue
+			}
+		near:
+			for dy := -r; dy <= r; dy++ {
+				for dx := -r; dx <= r; dx++ {
+					if dx*dx+dy*dy <= r*r && opaque(x+dx, y+dy) {
+						i := y*rim.Stride + x*4
+						rim.Pix[i], rim.Pix[i+1], rim.Pix[i+2], rim.Pix[i+3] = c.R, c.G, c.B, 255
+						break near
+					}
+				}
+			}
+		}
+	}
+	return rim
+}
+
+// rgbToHSL converts c to hue (degrees), saturation and lightness (0-1)
+func rgbToHSL(c color.NRGBA) (float64, float64, float64) {
+	r, g, b := float64(c.R)/255, float64(c.G)/255, float64(c.B)/255
+	hi, lo := math.Max(r, math.Max(g, b)), math.Min(r, math.Min(g, b))
+	l := (hi + lo) / 2
+	if hi == lo {
+		return 0, 0, l
+	}
+	d := hi - lo
+	s := d / (1 - math.Abs(2*l-1))
+	var h float64
+	switch hi {
+	case r:
+		h = math.Mod((g-b)/d+6, 6)
+	case g:
+		h = (b-r)/d + 2
+	default:
+		h = (r-g)/d + 4
+	}
+	return h * 60, s, l
+}
+
+// hslToRGB is the inverse of rgbToHSL
+func hslToRGB(h, s, l float64) color.NRGBA {
+	c := (1 - math.Abs(2*l-1)) * s
+	x := c * (1 - math.Abs(math.Mod(h/60, 2)-1))
+	m := l - c/2
+	var r, g, b float64
+	switch int(h/60) % 6 {
+	case 0:
+		r, g = c, x
+	case 1:
+		r, g = x, c
+	case 2:
+		g, b = c, x
+	case 3:
+		g, b = x, c
+	case 4:
+		r, b = x, c
+	default:
+		r, b = c, x
+	}
+	to8 := func(v float64) uint8 { return uint8(math.Round(math.Max(0, math.Min(1, v+m)) * 255)) }
+	return color.NRGBA{to8(r), to8(g), to8(b), 255}
+}
@@ -0,0 +1,129 @@
+package combat
+
+import (
+	"image"
+	"image/color"
+	"math"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestEnemyVariant(t *testing.T) {
+	fireHue, _, _ := rgbToHSL(fxElements["fire"].glow)
+	tests := []struct {
+		name string
+		ok   bool
+		hue  float64 // -1 for variants that turn every hue
+	}{
+		{"shiny", true, -1},
+		{" Elite ", true, -1},
+		{"corrupted", true, 285},
+		{"fire", true, fireHue},
+		{"flame", true, fireHue},
+		{"", false, 0},
+		{"physical", false, 0},
+		{"golden", false, 0},
+	}
+	for _, tt := range tests {
+		v, ok := enemyVariant(tt.name)
+		if ok != tt.ok {
+			t.Errorf("enemyVariant(%q) ok = %v; want %v", tt.name, ok, tt.ok)
+			continue
+		}
+		if ok && v.hue != tt.hue {
+			t.Errorf("enemyVariant(%q) hue = %v; want %v", tt.name, v.hue, tt.hue)
+		}
+	}
+}
+
+func TestHSLRoundTrip(t *testing.T) {
+	for r := 0; r < 256; r += 15 {
+		for g := 0; g < 256; g += 15 {
+			for b := 0; b < 256; b += 15 {
+				c := color.NRGBA{uint8(r), uint8(g), uint8(b), 255}
+				back := hslToRGB(rgbToHSL(c))
+				if diff(c.R, back.R) > 1 || diff(c.G, back.G) > 1 || diff(c.B, back.B) > 1 {
+					t.Fatalf("%v came back as %v", c, back)
+				}
+			}
+		}
+	}
+}
+
+func diff(a, b uint8) int {
+	if a > b {
+		return int(a - b)
+	}
+	return int(b - a)
+}
+
+// variantSprite is a 40 x 40 sprite: a green body with a red band, a grey
+// outline and a transparent border
+func variantSprite() *image.NRGBA {
+	img := image.NewNRGBA(image.Rect(0, 0, 40, 40))
+	for y := 8; y < 32; y++ {
+		for x := 8; x < 32; x++ {
+			c := color.NRGBA{40, 200, 60, 255}
+			switch {
+			case x == 8 || y == 8 || x == 31 || y == 31:
+				c = color.NRGBA{60, 60, 60, 255}
+			case y > 26:
+				c = color.NRGBA{220, 30, 30, 255}
+			}
+			img.SetNRGBA(x, y, c)
+		}
+	}
+	return img
+}
+
+func TestDominantHue(t *testing.T) {
+	body, _, _ := rgbToHSL(color.NRGBA{40, 200, 60, 255})
+	if got := dominantHue(variantSprite()); math.Abs(got-body) > 5 {
+		t.Errorf("dominantHue = %v; want the body's %v", got, body)
+	}
+	if got := dominantHue(image.NewNRGBA(image.Rect(0, 0, 4, 4))); got != 5 {
+		t.Errorf("dominantHue of a blank sprite = %v; want 5", got)
+	}
+}
+
+func TestRecolorSprite(t *testing.T) {
+	for _, name := range []string{"shiny", "elite", "corrupted", "ice"} {
+		v, _ := enemyVariant(name)
+		a, b := recolorSprite(variantSprite(), v), recolorSprite(variantSprite(), v)
+		if !reflect.DeepEqual(a, b) {
+			t.Errorf("%s: recolors differ", name)
+		}
+		if a.Bounds() != variantSprite().Bounds() {
+			t.Errorf("%s: %v; want the sprite's bounds", name, a.Bounds())
+		}
+		if v.hue < 0 {
+			continue
+		}
+		// The body is the dominant hue, so it lands on the variant's
+		h, _, _ := rgbToHSL(color.NRGBAModel.Convert(a.At(20, 20)).(color.NRGBA))
+		if d := math.Abs(math.Mod(h-v.hue+540, 360) - 180); d > 3 {
+			t.Errorf("%s: body hue %v; want %v", name, h, v.hue)
+		}
+	}
+}
+
+func TestLoadEnemyVariant(t *testing.T) {
+	useManifest(t, &Manifest{})
+	path := filepath.Join(t.TempDir(), "slime.png")
+	writeSolidPNG(t, path, 20, 20, color.NRGBA{40, 200, 60, 255})
+
+	plain, err := loadEnemy(Enemy{}, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	unknown, _ := loadEnemy(Enemy{Variant: "golden"}, path)
+	if unknown != plain {
+		t.Error("unknown variant; want the plain sprite")
+	}
+	shiny, _ := loadEnemy(Enemy{Variant: "shiny"}, path)
+	again, _ := loadEnemy(Enemy{Variant: " SHINY "}, path)
+	if shiny == plain || again != shiny {
+		t.Error("shiny sprite; want one recolor, cached across spellings")
+	}
+}
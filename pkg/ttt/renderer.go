@@ -3,6 +3,7 @@ package ttt
 import (
 	"fmt"
 	"image/color"
+	"os"
 	"strings"
 
 	"image-service/pkg/utils"
@@ -27,6 +28,19 @@ var (
 	Highlight = utils.ParseHexColor("#F39C12")
 )
 
+// leaderboardBackground is optional art; without it the leaderboard uses a flat color
+func leaderboardBackground() string {
+	return utils.GetAssetPath("Ldatabase", "scores.png")
+}
+
+// CheckAssets reports optional game art that isn't shipped with the service
+func CheckAssets() error {
+	if _, err := os.Stat(leaderboardBackground()); err != nil {
+		return fmt.Errorf("leaderboard background not found, using flat color: %s", leaderboardBackground())
+	}
+	return nil
+}
+
 func RenderBoard(c *gin.Context) {
 	var req TTTRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -132,7 +146,7 @@ func RenderLeaderboard(c *gin.Context) {
 	dc := gg.NewContext(width, height)
 
 	// Background
-	bgPath := utils.GetAssetPath("Ldatabase", "scores.png")
+	bgPath := leaderboardBackground()
 	bgImg, err := utils.LoadImage(bgPath)
 	if err == nil {
 		bgImg = imaging.Fill(bgImg, width, height, imaging.Center, imaging.Lanczos)
@@ -6,10 +6,14 @@ import (
 	"image"
 	"image/color"
 	"image/draw"
+	"image/gif"
 	"image/png"
+	"log"
+	"math"
 	"net/http"
 	"os"
 	"path/filepath"
+	"sort"
 	"sync"
 
 	"github.com/disintegration/imaging"
@@ -22,8 +26,20 @@ import (
 var (
 	imageCache = make(map[string]image.Image)
 	mutex      sync.RWMutex
+
+	reportedAssets sync.Map // Paths already logged as missing or unreadable
+	fontCache      sync.Map // path -> *opentype.Font
 )
 
+// reportAsset logs a failed asset load once per path, so renderers that skip
+// a missing piece don't do it silently
+func reportAsset(path string, err error) error {
+	if _, seen := reportedAssets.LoadOrStore(path, true); !seen {
+		log.Printf("⚠️ Asset unavailable, drawing without it: %v", err)
+	}
+	return err
+}
+
 // LoadImage loads an image from disk or cache
 func LoadImage(path string) (image.Image, error) {
 	mutex.RLock()
@@ -35,12 +51,12 @@ func LoadImage(path string) (image.Image, error) {
 
 	// Check if file exists
 	if _, err := os.Stat(path); os.IsNotExist(err) {
-		return nil, fmt.Errorf("file not found: %s", path)
+		return nil, reportAsset(path, fmt.Errorf("file not found: %s", path))
 	}
 
 	img, err := imaging.Open(path)
 	if err != nil {
-		return nil, err
+		return nil, reportAsset(path, fmt.Errorf("%s: %w", path, err))
 	}
 
 	mutex.Lock()
@@ -65,28 +81,33 @@ func DownloadImage(url string) (image.Image, error) {
 	return img, nil
 }
 
-// LoadFont loads a TTF font
+// LoadFont loads a TTF font at size. The parsed font is cached per path; the
+// face is new on every call since faces aren't safe to share between renders.
 func LoadFont(path string, size float64) (font.Face, error) {
-	fontBytes, err := os.ReadFile(path)
-	if err != nil {
-		return nil, err
-	}
-
-	ft, err := opentype.Parse(fontBytes)
-	if err != nil {
-		return nil, err
+	ft, ok := fontCache.Load(path)
+	if !ok {
+		fontBytes, err := os.ReadFile(path)
+		if err != nil {
+			return nil, reportAsset(path, err)
+		}
+		parsed, err := opentype.Parse(fontBytes)
+		if err != nil {
+			return nil, reportAsset(path, fmt.Errorf("%s: %w", path, err))
+		}
+		ft, _ = fontCache.LoadOrStore(path, parsed)
 	}
 
-	return opentype.NewFace(ft, &opentype.FaceOptions{
+	return opentype.NewFace(ft.(*opentype.Font), &opentype.FaceOptions{
 		Size:    size,
 		DPI:     72,
 		Hinting: font.HintingFull,
 	})
 }
 
-// ParseHexColor converts hex string to color.RGBA
-func ParseHexColor(s string) color.RGBA {
-	c := color.RGBA{0, 0, 0, 255}
+// ParseHexColor converts a #RRGGBB or #RRGGBBAA hex string to color.NRGBA.
+// The alpha is straight, not premultiplied.
+func ParseHexColor(s string) color.NRGBA {
+	c := color.NRGBA{0, 0, 0, 255}
 	switch len(s) {
 	case 7:
 		fmt.Sscanf(s, "#%02x%02x%02x", &c.R, &c.G, &c.B)
@@ -160,6 +181,312 @@ func EncodeImageToBuffer(img image.Image) ([]byte, error) {
 	return buf.Bytes(), nil
 }
 
+// GIFBuilder quantizes frames as they are added so callers can reuse one canvas.
+// The palette is built from the first frame and every frame uses ordered
+// dithering, so unchanged pixels map to the same index and only the changed
+// region of each frame is stored.
+type GIFBuilder struct {
+	anim gif.GIF
+	prev *image.Paletted
+	pal  color.Palette
+	lut  []uint8
+}
+
+// NewGIFBuilder returns a builder for a looping GIF
+func NewGIFBuilder() *GIFBuilder {
+	return &GIFBuilder{anim: gif.GIF{LoopCount: 0}}
+}
+
+// AddFrame appends a frame shown for delay 100ths of a second
+func (b *GIFBuilder) AddFrame(img image.Image, delay int) {
+	rgba, ok := img.(*image.RGBA)
+	if !ok {
+		rgba = image.NewRGBA(img.Bounds())
+		draw.Draw(rgba, rgba.Bounds(), img, img.Bounds().Min, draw.Src)
+	}
+	if b.pal == nil {
+		b.pal = buildPalette(rgba)
+		b.lut = paletteLUT(b.pal)
+	}
+	frame := b.quantize(rgba)
+
+	if b.prev != nil {
+		changed := diffBounds(b.prev, frame)
+		if changed.Empty() {
+			// Nothing moved, extend the previous frame instead
+			b.anim.Delay[len(b.anim.Delay)-1] += delay
+			return
+		}
+		b.prev = frame
+		frame = frame.SubImage(changed).(*image.Paletted)
+	} else {
+		b.prev = frame
+	}
+
+	b.anim.Image = append(b.anim.Image, frame)
+	b.anim.Delay = append(b.anim.Delay, delay)
+	b.anim.Disposal = append(b.anim.Disposal, gif.DisposalNone)
+}
+
+// Bytes returns the encoded GIF
+func (b *GIFBuilder) Bytes() ([]byte, error) {
+	buf := new(bytes.Buffer)
+	if err := gif.EncodeAll(buf, &b.anim); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+var bayer4 = [4][4]int{{0, 8, 2, 10}, {12, 4, 14, 6}, {3, 11, 1, 9}, {15, 7, 13, 5}}
+
+// buildPalette picks the 192 most common 12-bit colors of img and pads with a
+// 4x4x4 color cube so flashes and tints that appear later still have a match
+func buildPalette(img *image.RGBA) color.Palette {
+	type bin struct{ r, g, b, n int }
+	bins := make([]bin, 1<<12)
+	for i := 0; i+3 < len(img.Pix); i += 4 {
+		r, g, b := int(img.Pix[i]), int(img.Pix[i+1]), int(img.Pix[i+2])
+		k := &bins[(r>>4)<<8|(g>>4)<<4|b>>4]
+		k.r, k.g, k.b, k.n = k.r+r, k.g+g, k.b+b, k.n+1
+	}
+	sort.Slice(bins, func(i, j int) bool { return bins[i].n > bins[j].n })
+
+	var pal color.Palette
+	for _, k := range bins[:192] {
+		if k.n == 0 {
+			break
+		}
+		pal = append(pal, color.RGBA{uint8(k.r / k.n), uint8(k.g / k.n), uint8(k.b / k.n), 255})
+	}
+	for r := 0; r < 4; r++ {
+		for g := 0; g < 4; g++ {
+			for b := 0; b < 4; b++ {
+				pal = append(pal, color.RGBA{uint8(r * 85), uint8(g * 85), uint8(b * 85), 255})
+			}
+		}
+	}
+	return pal
+}
+
+// paletteLUT maps every 15-bit color to its nearest palette index
+func paletteLUT(pal color.Palette) []uint8 {
+	lut := make([]uint8, 1<<15)
+	for i := range lut {
+		r, g, b := uint8(i>>10)<<3|4, uint8(i>>5&31)<<3|4, uint8(i&31)<<3|4
+		lut[i] = uint8(pal.Index(color.RGBA{r, g, b, 255}))
+	}
+	return lut
+}
+
+// quantize maps img onto the palette with a 4x4 Bayer dither
+func (b *GIFBuilder) quantize(img *image.RGBA) *image.Paletted {
+	bounds := img.Bounds()
+	dst := image.NewPaletted(bounds, b.pal)
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		src := img.Pix[img.PixOffset(bounds.Min.X, y):]
+		out := dst.Pix[dst.PixOffset(bounds.Min.X, y):]
+		for x := 0; x < bounds.Dx(); x++ {
+			d := bayer4[y&3][x&3] - 8
+			r := clampByte(int(src[x*4]) + d)
+			g := clampByte(int(src[x*4+1]) + d)
+			bl := clampByte(int(src[x*4+2]) + d)
+			out[x] = b.lut[int(r>>3)<<10|int(g>>3)<<5|int(bl>>3)]
+		}
+	}
+	return dst
+}
+
+// diffBounds returns the smallest rectangle containing every differing pixel
+func diffBounds(a, b *image.Paletted) image.Rectangle {
+	r := image.Rectangle{}
+	bounds := b.Bounds()
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		ra := a.Pix[a.PixOffset(bounds.Min.X, y):][:bounds.Dx()]
+		rb := b.Pix[b.PixOffset(bounds.Min.X, y):][:bounds.Dx()]
+		if bytes.Equal(ra, rb) {
+			continue
+		}
+		for x := range rb {
+			if ra[x] != rb[x] {
+				r = r.Union(image.Rect(bounds.Min.X+x, y, bounds.Min.X+x+1, y+1))
+			}
+		}
+	}
+	return r
+}
+
+func clampByte(v int) uint8 {
+	if v < 0 {
+		return 0
+	}
+	if v > 255 {
+		return 255
+	}
+	return uint8(v)
+}
+
+// Blit composites img at x, y without gg's bilinear transform. Use it for
+// pre-sized images drawn every animation frame.
+func Blit(dc *gg.Context, img image.Image, x, y int) {
+	DrawImageAlpha(dc, img, x, y, 1)
+}
+
+// DrawImageAlpha draws img onto the context at x, y with a uniform opacity (0-1)
+func DrawImageAlpha(dc *gg.Context, img image.Image, x, y int, alpha float64) {
+	if alpha <= 0 {
+		return
+	}
+	dst, ok := dc.Image().(draw.Image)
+	if !ok {
+		dc.DrawImage(img, x, y)
+		return
+	}
+	b := img.Bounds()
+	r := image.Rect(x, y, x+b.Dx(), y+b.Dy())
+	if alpha >= 1 {
+		draw.Draw(dst, r, img, b.Min, draw.Over)
+		return
+	}
+	mask := image.NewUniform(color.Alpha{uint8(alpha * 255)})
+	draw.DrawMask(dst, r, img, b.Min, mask, image.Point{}, draw.Over)
+}
+
+// AddBlend adds src's premultiplied color onto dst (additive blending), so
+// overlapping glows brighten towards white instead of covering each other.
+// dst keeps its own alpha.
+func AddBlend(dst, src *image.RGBA) {
+	r := dst.Bounds().Intersect(src.Bounds())
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		si := src.PixOffset(r.Min.X, y)
+		di := dst.PixOffset(r.Min.X, y)
+		for x := r.Min.X; x < r.Max.X; x, si, di = x+1, si+4, di+4 {
+			if src.Pix[si+3] == 0 {
+				continue
+			}
+			for c := 0; c < 3; c++ {
+				dst.Pix[di+c] = clampByte(int(dst.Pix[di+c]) + int(src.Pix[si+c]))
+			}
+		}
+	}
+}
+
+// OpaqueBounds returns the smallest rectangle holding every pixel with alpha
+// above threshold (0-255), or an empty rectangle for a fully transparent image
+func OpaqueBounds(img image.Image, threshold uint8) image.Rectangle {
+	b := img.Bounds()
+	minX, minY, maxX, maxY := b.Max.X, b.Max.Y, b.Min.X-1, b.Min.Y-1
+	t := uint32(threshold) * 0x101
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			if _, _, _, a := img.At(x, y).RGBA(); a > t {
+				if x < minX {
+					minX = x
+				}
+				if x > maxX {
+					maxX = x
+				}
+				if y < minY {
+					minY = y
+				}
+				maxY = y
+			}
+		}
+	}
+	if maxX < minX {
+		return image.Rectangle{}
+	}
+	return image.Rect(minX, minY, maxX+1, maxY+1)
+}
+
+// PortraitFrame finds a head-and-shoulders frame of the given aspect (width
+// over height) in a character sprite, sized from its opaque art. face is the
+// middle of the face in sprite pixels; without one the head is looked for at
+// the top of the art. The frame may reach past the sprite's edges.
+func PortraitFrame(sprite image.Image, aspect float64, face *image.Point) image.Rectangle {
+	art := OpaqueBounds(sprite, 16)
+	if art.Empty() {
+		art = sprite.Bounds()
+	}
+
+	// Each row's opaque pixel count and its longest opaque run
+	type row struct{ count, runStart, runLen int }
+	rows := make([]row, art.Dy())
+	widest := 0
+	for y := art.Min.Y; y < art.Max.Y; y++ {
+		r := &rows[y-art.Min.Y]
+		start := -1
+		for x := art.Min.X; x <= art.Max.X; x++ {
+			opaque := false
+			if x < art.Max.X {
+				_, _, _, a := sprite.At(x, y).RGBA()
+				opaque = a > 16*0x101
+			}
+			if opaque {
+				r.count++
+				if start < 0 {
+					start = x
+				}
+			} else if start >= 0 {
+				if x-start > r.runLen {
+					r.runStart, r.runLen = start, x-start
+				}
+				start = -1
+			}
+		}
+		widest = max(widest, r.count)
+	}
+
+	// The head is the first row of real width: a staff or orb held above it
+	// is much narrower than the shoulders below
+	top := 0
+	for i, r := range rows {
+		if r.count*100 >= widest*18 {
+			top = i
+			break
+		}
+	}
+	bodyH := float64(len(rows) - top)
+	h := bodyH * 0.3
+	w := h * aspect
+
+	var fx, fy float64
+	if face != nil {
+		fx, fy = float64(face.X), float64(face.Y)
+	} else {
+		// Middle of the longest run across the head's rows, so a weapon
+		// beside the head doesn't pull the frame off center
+		sum, n := 0.0, 0
+		for i := top + int(bodyH*0.03); i < min(len(rows), top+int(bodyH*0.15)); i++ {
+			if r := rows[i]; r.runLen > 0 {
+				sum += float64(r.runStart) + float64(r.runLen)/2
+				n++
+			}
+		}
+		fx = float64(art.Min.X+art.Max.X) / 2
+		if n > 0 {
+			fx = sum / float64(n)
+		}
+		fy = float64(art.Min.Y+top) + bodyH*0.09
+	}
+	x0, y0 := fx-w/2, fy-h*0.4
+	return image.Rect(int(math.Round(x0)), int(math.Round(y0)), int(math.Round(x0+w)), int(math.Round(y0+h)))
+}
+
+// CropPortrait scales frame out of the sprite to w x h. Parts of the frame
+// past the sprite's edges stay transparent.
+func CropPortrait(sprite image.Image, frame image.Rectangle, w, h int) image.Image {
+	dst := image.NewNRGBA(image.Rect(0, 0, w, h))
+	part := frame.Intersect(sprite.Bounds())
+	if part.Empty() || frame.Dx() == 0 || frame.Dy() == 0 {
+		return dst
+	}
+	sx, sy := float64(w)/float64(frame.Dx()), float64(h)/float64(frame.Dy())
+	img := imaging.Resize(imaging.Crop(sprite, part),
+		max(1, int(math.Round(float64(part.Dx())*sx))), max(1, int(math.Round(float64(part.Dy())*sy))), imaging.Lanczos)
+	at := image.Pt(int(math.Round(float64(part.Min.X-frame.Min.X)*sx)), int(math.Round(float64(part.Min.Y-frame.Min.Y)*sy)))
+	return imaging.Paste(dst, img, at)
+}
+
 // GetAssetPath helper to find assets relative to the binary
 func GetAssetPath(parts ...string) string {
 	// Assume "assets" folder is in CWD
@@ -0,0 +1,84 @@
+package utils
+
+import (
+	"image"
+	"image/color"
+	"testing"
+)
+
+// fill paints r opaque on img
+func fill(img *image.NRGBA, r image.Rectangle) {
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		for x := r.Min.X; x < r.Max.X; x++ {
+			img.SetNRGBA(x, y, color.NRGBA{200, 150, 100, 255})
+		}
+	}
+}
+
+func TestPortraitFrame(t *testing.T) {
+	// A 40 px wide body from y 40 down to the bottom of a 100 x 200 sprite
+	sprite := func(extra ...image.Rectangle) image.Image {
+		img := image.NewNRGBA(image.Rect(0, 0, 100, 200))
+		fill(img, image.Rect(30, 40, 70, 200))
+		for _, r := range extra {
+			fill(img, r)
+		}
+		return img
+	}
+	pt := func(x, y int) *image.Point { return &image.Point{x, y} }
+
+	tests := []struct {
+		name   string
+		sprite image.Image
+		aspect float64
+		face   *image.Point
+		want   image.Rectangle
+	}{
+		{"found head", sprite(), 1, nil, image.Rect(26, 35, 74, 83)},
+		{"staff above the head", sprite(image.Rect(48, 0, 50, 40)), 1, nil, image.Rect(26, 35, 74, 83)},
+		{"weapon beside the head", sprite(image.Rect(74, 40, 82, 120)), 1, nil, image.Rect(26, 35, 74, 83)},
+		{"face anchor", sprite(), 1, pt(60, 70), image.Rect(36, 51, 84, 99)},
+		{"face anchor, narrow", sprite(), 0.75, pt(60, 70), image.Rect(42, 51, 78, 99)},
+		{"face at the corner", sprite(), 1, pt(0, 0), image.Rect(-24, -19, 24, 29)},
+		{"blank sprite", image.NewNRGBA(image.Rect(0, 0, 100, 200)), 1, nil, image.Rect(20, -6, 80, 54)},
+	}
+	for _, tt := range tests {
+		if got := PortraitFrame(tt.sprite, tt.aspect, tt.face); got != tt.want {
+			t.Errorf("%s: %v; want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestCropPortrait(t *testing.T) {
+	img := image.NewNRGBA(image.Rect(0, 0, 20, 20))
+	fill(img, img.Bounds())
+
+	tests := []struct {
+		name  string
+		frame image.Rectangle
+		clear []image.Point // Points left transparent in the 40 x 40 portrait
+		solid []image.Point
+	}{
+		{"inside", image.Rect(0, 0, 20, 20), nil, []image.Point{{1, 1}, {38, 38}}},
+		{"past the left edge", image.Rect(-10, 0, 10, 20), []image.Point{{5, 20}}, []image.Point{{30, 20}}},
+		{"outside", image.Rect(30, 30, 40, 40), []image.Point{{20, 20}}, nil},
+		{"empty", image.Rectangle{}, []image.Point{{0, 0}}, nil},
+	}
+	for _, tt := range tests {
+		p := CropPortrait(img, tt.frame, 40, 40)
+		if b := p.Bounds(); b.Dx() != 40 || b.Dy() != 40 {
+			t.Errorf("%s: %v; want 40 x 40", tt.name, b)
+			continue
+		}
+		for _, at := range tt.clear {
+			if _, _, _, a := p.At(at.X, at.Y).RGBA(); a != 0 {
+				t.Errorf("%s: opaque at %v; want transparent", tt.name, at)
+			}
+		}
+		for _, at := range tt.solid {
+			if _, _, _, a := p.At(at.X, at.Y).RGBA(); a != 0xffff {
+				t.Errorf("%s: alpha %d at %v; want opaque", tt.name, a, at)
+			}
+		}
+	}
+}